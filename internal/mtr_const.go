@@ -31,6 +31,10 @@ const (
 	MsgTx   ID = 1202
 	MsgProc ID = 1203
 	MsgErr  ID = 1204
+
+	// Scraping by mtr-api
+	ScrapeOK  ID = 1301
+	ScrapeErr ID = 1302
 )
 
 var idColours = map[int]string{
@@ -53,6 +57,9 @@ var idColours = map[int]string{
 	1202: "#984ea3",
 	1203: "deepskyblue",
 	1204: "#e41a1c",
+
+	1301: "#4daf4a",
+	1302: "#e41a1c",
 }
 
 var idLabels = map[int]string{
@@ -75,6 +82,9 @@ var idLabels = map[int]string{
 	1202: "Msg Tx",
 	1203: "Msg Processed",
 	1204: "Msg Error",
+
+	1301: "Scrape OK",
+	1302: "Scrape Error",
 }
 
 func Colour(id int) string {
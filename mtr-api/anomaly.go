@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/GeoNet/mtr/ts"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// anomalyLimit is the robust z-score above which a value is anomalous.  Set with MTR_ANOMALY_LIMIT.
+var anomalyLimit = 3.5
+
+// baselineDays is the number of days of values used for baselines.  Set with MTR_BASELINE_DAYS.
+// It must be less than the retention for field.metric and data.latency (availabilityRetention).
+var baselineDays = 14
+
+// baselineMinValues is the fewest values for an hour of day to have a baseline.
+const baselineMinValues = 10
+
+// baselineMinDeviation is the smallest deviation used for scores as a fraction of the median.  It stops
+// small changes in flat, or nearly flat, metrics being scored as anomalous.  The deviation is also
+// at least one stored unit (values are stored as integers).
+const baselineMinDeviation = 0.005
+
+// anomalyColour is for anomalous values on plots.
+const anomalyColour = "darkorchid"
+
+func init() {
+	if s := os.Getenv("MTR_ANOMALY_LIMIT"); s != "" {
+		if l, err := strconv.ParseFloat(s, 64); err == nil && l > 0 {
+			anomalyLimit = l
+		} else {
+			log.Printf("ERROR: invalid MTR_ANOMALY_LIMIT %s", s)
+		}
+	}
+
+	if s := os.Getenv("MTR_BASELINE_DAYS"); s != "" {
+		if d, err := strconv.Atoi(s); err == nil && d > 0 && day*time.Duration(d) < availabilityRetention {
+			baselineDays = d
+		} else {
+			log.Printf("ERROR: invalid MTR_BASELINE_DAYS %s", s)
+		}
+	}
+}
+
+// baseline is the median, median absolute deviation, and mean absolute deviation from the
+// median of a metric for an hour of day.
+type baseline struct {
+	median, mad, meanAD float64
+}
+
+// score returns the robust (modified) z-score for v.  If more than half the values are the median,
+// so the mad is 0, the mean absolute deviation is used instead.  The deviation is at least
+// baselineMinDeviation of the median and one stored unit.
+func (b baseline) score(v float64) float64 {
+	var d float64
+
+	switch {
+	case b.mad > 0:
+		d = b.mad / 0.6745
+	default:
+		d = 1.253314 * b.meanAD
+	}
+
+	d = math.Max(d, math.Max(baselineMinDeviation*math.Abs(b.median), 1))
+
+	return (v - b.median) / d
+}
+
+// anomaly returns the score for v and if it is anomalous.  median, mad, and meanAD are NULL if
+// there is no baseline.
+func anomaly(v float64, median, mad, meanAD sql.NullFloat64) (float64, bool) {
+	if !median.Valid || !mad.Valid || !meanAD.Valid {
+		return 0, false
+	}
+
+	s := baseline{median: median.Float64, mad: mad.Float64, meanAD: meanAD.Float64}.score(v)
+
+	return s, math.Abs(s) > anomalyLimit
+}
+
+// baselineKind computes baselines for field metrics or data latencies.
+type baselineKind struct {
+	table  string // the baseline table e.g., field.metric_baseline
+	pkCol  string // the device or site pk column e.g., devicePK
+	values string // the table of values e.g., field.metric
+	value  string // the column for the value e.g., value
+}
+
+var fieldBaseline = baselineKind{table: "field.metric_baseline", pkCol: "devicePK", values: "field.metric", value: "value"}
+var dataBaseline = baselineKind{table: "data.latency_baseline", pkCol: "sitePK", values: "data.latency", value: "mean"}
+
+// hourSQL is the UTC hour of day for a time column.
+const hourSQL = `extract(hour from time AT TIME ZONE 'UTC')`
+
+// fieldBaselineSQL joins the baseline, as b, for the hour of day of field.metric_summary s.
+const fieldBaselineSQL = `LEFT OUTER JOIN field.metric_baseline b
+	ON (b.devicePK = s.devicePK AND b.typePK = s.typePK AND b.hour = extract(hour from s.time AT TIME ZONE 'UTC'))`
+
+// dataBaselineSQL joins the baseline, as b, for the hour of day of data.latency_summary s.
+const dataBaselineSQL = `LEFT OUTER JOIN data.latency_baseline b
+	ON (b.sitePK = s.sitePK AND b.typePK = s.typePK AND b.hour = extract(hour from s.time AT TIME ZONE 'UTC'))`
+
+// refresh replaces the baselines with the median and absolute deviations of the values by hour of day
+// over the last baselineDays.
+func (k baselineKind) refresh() error {
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = txn.Exec(`DELETE FROM ` + k.table); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	if _, err = txn.Exec(`WITH v AS (
+			SELECT `+k.pkCol+` AS pk, typePK, `+hourSQL+` AS hour, `+k.value+` AS value
+			FROM `+k.values+` WHERE time > now() - $1 * interval '1 day'
+		),
+		m AS (
+			SELECT pk, typePK, hour, percentile_cont(0.5) WITHIN GROUP (ORDER BY value) AS median
+			FROM v GROUP BY pk, typePK, hour HAVING count(*) >= $2
+		)
+		INSERT INTO `+k.table+`(`+k.pkCol+`, typePK, hour, median, mad, meanad)
+		SELECT pk, typePK, hour, median, percentile_cont(0.5) WITHIN GROUP (ORDER BY abs(value - median)), avg(abs(value - median))
+		FROM v JOIN m USING (pk, typePK, hour)
+		GROUP BY pk, typePK, hour, median`, baselineDays, baselineMinValues); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// load returns the baselines by hour of day for a metric.
+func (k baselineKind) load(pk, typePK int) (map[int]baseline, error) {
+	rows, err := dbR.Query(`SELECT hour, median, mad, meanad FROM `+k.table+` WHERE `+k.pkCol+` = $1 AND typePK = $2`, pk, typePK)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	b := make(map[int]baseline)
+
+	for rows.Next() {
+		var h int
+		var v baseline
+
+		if err = rows.Scan(&h, &v.median, &v.mad, &v.meanAD); err != nil {
+			return nil, err
+		}
+
+		b[h] = v
+	}
+
+	return b, rows.Err()
+}
+
+// anomalous returns true if v at t is anomalous against the baselines b.
+func anomalous(b map[int]baseline, t time.Time, v float64) bool {
+	x, ok := b[t.UTC().Hour()]
+
+	return ok && math.Abs(x.score(v)) > anomalyLimit
+}
+
+// markers returns the values for a metric since start that are anomalous, multiplied by scale, for
+// marking on a plot.  The baselines are for raw values so the raw values are scored, not the averages
+// that are plotted at lower resolutions.
+func (k baselineKind) markers(pk, typePK int, start time.Time, scale float64) (ts.Series, error) {
+	b, err := k.load(pk, typePK)
+	if err != nil || len(b) == 0 {
+		return ts.Series{Colour: anomalyColour}, err
+	}
+
+	rows, err := dbR.Query(`SELECT time, `+k.value+` FROM `+k.values+`
+		WHERE `+k.pkCol+` = $1 AND typePK = $2 AND time > $3 ORDER BY time ASC`, pk, typePK, start)
+	if err != nil {
+		return ts.Series{}, err
+	}
+	defer rows.Close()
+
+	var pts []ts.Point
+
+	for rows.Next() {
+		var t time.Time
+		var v float64
+
+		if err = rows.Scan(&t, &v); err != nil {
+			return ts.Series{}, err
+		}
+
+		pts = append(pts, ts.Point{DateTime: t, Value: v * scale})
+	}
+
+	if err = rows.Err(); err != nil {
+		return ts.Series{}, err
+	}
+
+	return anomalyMarkers(b, pts, scale), nil
+}
+
+// anomalyMarkers returns the points in pts that are anomalous against the baselines b.  The
+// values in pts are the stored values multiplied by scale.
+func anomalyMarkers(b map[int]baseline, pts []ts.Point, scale float64) ts.Series {
+	s := ts.Series{Colour: anomalyColour}
+
+	for _, p := range pts {
+		if anomalous(b, p.DateTime, p.Value/scale) {
+			s.Points = append(s.Points, p)
+		}
+	}
+
+	return s
+}
+
+func baselinesTimed() {
+	if err := refreshBaselines(); err != nil {
+		log.Println(err)
+	}
+
+	ticker := time.NewTicker(time.Hour).C
+	for {
+		select {
+		case <-ticker:
+			if err := refreshBaselines(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func refreshBaselines() error {
+	if err := fieldBaseline.refresh(); err != nil {
+		return err
+	}
+
+	return dataBaseline.refresh()
+}
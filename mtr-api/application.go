@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/GeoNet/weft"
 	"net/http"
 	"strconv"
@@ -30,6 +31,11 @@ type applicationType struct {
 func (a *application) loadPK(r *http.Request) *weft.Result {
 	a.applicationID = r.URL.Query().Get("applicationID")
 
+	return a.load()
+}
+
+// load finds (and possibly creates) the applicationPK for a.applicationID
+func (a *application) load() *weft.Result {
 	err := db.QueryRow(`SELECT applicationPK FROM app.application WHERE applicationID = $1`,
 		a.applicationID).Scan(&a.applicationPK)
 	switch err {
@@ -49,6 +55,23 @@ func (a *application) loadPK(r *http.Request) *weft.Result {
 	return &weft.StatusOK
 }
 
+// loadTx finds (and possibly creates) the applicationPK for a.applicationID using txn.
+// A unique violation would abort txn so the insert is skipped when the applicationID exists.
+func (a *application) loadTx(txn *sql.Tx) *weft.Result {
+	if _, err := txn.Exec(`INSERT INTO app.application(applicationID)
+		SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM app.application WHERE applicationID = $1)`,
+		a.applicationID); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if err := txn.QueryRow(`SELECT applicationPK FROM app.application WHERE applicationID = $1`,
+		a.applicationID).Scan(&a.applicationPK); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
 func (a *application) delete(r *http.Request) *weft.Result {
 	if res := weft.CheckQuery(r, []string{"applicationID"}, []string{}); !res.Ok {
 		return res
@@ -70,6 +93,11 @@ func (a *application) delete(r *http.Request) *weft.Result {
 func (i *applicationInstance) loadPK(r *http.Request) *weft.Result {
 	i.instanceID = r.URL.Query().Get("instanceID")
 
+	return i.load()
+}
+
+// load finds (and possibly creates) the instancePK for i.instanceID
+func (i *applicationInstance) load() *weft.Result {
 	err := db.QueryRow(`SELECT instancePK FROM app.instance WHERE instanceID = $1`,
 		i.instanceID).Scan(&i.instancePK)
 	switch err {
@@ -91,10 +119,32 @@ func (i *applicationInstance) loadPK(r *http.Request) *weft.Result {
 	return &weft.StatusOK
 }
 
+// loadTx finds (and possibly creates) the instancePK for i.instanceID using txn.
+// A unique violation would abort txn so the insert is skipped when the instanceID exists.
+func (i *applicationInstance) loadTx(txn *sql.Tx) *weft.Result {
+	if _, err := txn.Exec(`INSERT INTO app.instance(instanceID)
+		SELECT $1 WHERE NOT EXISTS (SELECT 1 FROM app.instance WHERE instanceID = $1)`,
+		i.instanceID); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if err := txn.QueryRow(`SELECT instancePK FROM app.instance WHERE instanceID = $1`,
+		i.instanceID).Scan(&i.instancePK); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
 // Find  (and possibly create) the sourcePK for the sourceID
 func (s *applicationSource) loadPK(r *http.Request) *weft.Result {
 	s.sourceID = r.URL.Query().Get("sourceID")
 
+	return s.load()
+}
+
+// load finds (and possibly creates) the sourcePK for s.sourceID
+func (s *applicationSource) load() *weft.Result {
 	err := db.QueryRow(`SELECT sourcePK FROM app.source WHERE sourceID = $1`,
 		s.sourceID).Scan(&s.sourcePK)
 
@@ -125,3 +175,26 @@ func (a *applicationType) loadPK(r *http.Request) *weft.Result {
 
 	return &weft.StatusOK
 }
+
+// appTypePK finds the app.type typePK for typeID which can be the
+// numeric type or the type name.  Results are cached in types.
+func appTypePK(typeID string, types map[string]int) (int, error) {
+	if pk, ok := types[typeID]; ok {
+		return pk, nil
+	}
+
+	var pk int
+
+	err := dbR.QueryRow(`SELECT typePK FROM app.type WHERE typeID = $1 OR typePK::text = $1`, typeID).Scan(&pk)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return 0, fmt.Errorf("unknown typeID %s", typeID)
+	default:
+		return 0, err
+	}
+
+	types[typeID] = pk
+
+	return pk, nil
+}
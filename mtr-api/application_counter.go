@@ -38,8 +38,14 @@ func (a *applicationCounter) save(r *http.Request) *weft.Result {
 		return res
 	}
 
+	return a.insert()
+}
+
+// insert saves the count, adding to any existing count for the same time.
+// The application PK must be loaded.
+func (a *applicationCounter) insert() *weft.Result {
 	// TODO convert to UPSERT
-	if _, err = db.Exec(`INSERT INTO app.counter(applicationPK, typePK, time, count) VALUES($1,$2,$3,$4)`,
+	if _, err := db.Exec(`INSERT INTO app.counter(applicationPK, typePK, time, count) VALUES($1,$2,$3,$4)`,
 		a.applicationPK, a.typePK, a.t, a.c); err != nil {
 		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
 			if _, err := db.Exec(`UPDATE app.counter set count = count + $4
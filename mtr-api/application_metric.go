@@ -43,7 +43,12 @@ func (a *applicationMetric) save(r *http.Request) *weft.Result {
 		return res
 	}
 
-	if _, err = db.Exec(`INSERT INTO app.metric (applicationPK, instancePK, typePK, time, value) VALUES($1,$2,$3,$4,$5)`,
+	return a.insert()
+}
+
+// insert saves the metric.  The application and instance PKs must be loaded.
+func (a *applicationMetric) insert() *weft.Result {
+	if _, err := db.Exec(`INSERT INTO app.metric (applicationPK, instancePK, typePK, time, value) VALUES($1,$2,$3,$4,$5)`,
 		a.applicationPK, a.instancePK, a.typePK, a.t, a.value); err != nil {
 		return weft.InternalServerError(err)
 	}
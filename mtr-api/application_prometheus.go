@@ -0,0 +1,327 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/GeoNet/mtr/internal"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Prometheus remote write support.  Prometheus (or anything else that speaks the
+remote write protocol) can be configured to send metrics to mtr with e.g.,
+
+    remote_write:
+      - url: https://mtr-api.geonet.org.nz/application/prometheus
+        basic_auth:
+          username: ...
+          password: ...
+
+Series are mapped onto app.metric using label rules.  The label rules are read from
+the env var MTR_PROMETHEUS_LABELS e.g.,
+
+    MTR_PROMETHEUS_LABELS=applicationID=job,instanceID=instance
+
+Series are mapped to app.type using the metric name (the __name__ label).  Extra
+name to typeID mappings can be added with the env var MTR_PROMETHEUS_TYPES e.g.,
+
+    MTR_PROMETHEUS_TYPES=process_resident_memory_bytes=1000,my_app_rx_total=1201
+
+Series that can't be mapped are skipped.
+*/
+
+// promLabels maps applicationID and instanceID to the Prometheus label to read them from.
+var promLabels = map[string]string{
+	"applicationID": "job",
+	"instanceID":    "instance",
+}
+
+// promTypes maps Prometheus metric names to app.type.
+// These are the metrics from the Prometheus Go client that match the mtrapp runtime metrics.
+var promTypes = map[string]internal.ID{
+	"go_memstats_sys_bytes":        internal.MemSys,
+	"go_memstats_heap_alloc_bytes": internal.MemHeapAlloc,
+	"go_memstats_heap_sys_bytes":   internal.MemHeapSys,
+	"go_memstats_heap_objects":     internal.MemHeapObjects,
+	"go_goroutines":                internal.Routines,
+}
+
+func init() {
+	if err := promRules(os.Getenv("MTR_PROMETHEUS_LABELS"), os.Getenv("MTR_PROMETHEUS_TYPES")); err != nil {
+		log.Printf("ERROR: problem with Prometheus config: %s", err)
+	}
+}
+
+// promRules updates promLabels and promTypes from the label and type rules.
+// Rules are comma separated key=value pairs.
+func promRules(labels, types string) error {
+	for _, v := range promSplit(labels) {
+		switch v[0] {
+		case "applicationID", "instanceID":
+			promLabels[v[0]] = v[1]
+		default:
+			return fmt.Errorf("invalid label rule: %s", strings.Join(v, "="))
+		}
+	}
+
+	for _, v := range promSplit(types) {
+		i, err := strconv.Atoi(v[1])
+		if err != nil {
+			return fmt.Errorf("invalid type rule: %s", strings.Join(v, "="))
+		}
+		promTypes[v[0]] = internal.ID(i)
+	}
+
+	return nil
+}
+
+func promSplit(s string) (r [][]string) {
+	for _, v := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) == 2 && kv[0] != "" && kv[1] != "" {
+			r = append(r, kv)
+		}
+	}
+
+	return
+}
+
+// Types for the Prometheus remote write protocol.
+// cf https://github.com/prometheus/prometheus/blob/master/prompb/remote.proto
+type promWriteRequest struct {
+	Timeseries []*promTimeSeries `protobuf:"bytes,1,rep,name=timeseries" json:"timeseries,omitempty"`
+}
+
+type promTimeSeries struct {
+	Labels  []*promLabel  `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+	Samples []*promSample `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+}
+
+type promLabel struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+type promSample struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *promWriteRequest) Reset()         { *m = promWriteRequest{} }
+func (m *promWriteRequest) String() string { return proto.CompactTextString(m) }
+func (*promWriteRequest) ProtoMessage()    {}
+
+func (m *promTimeSeries) Reset()         { *m = promTimeSeries{} }
+func (m *promTimeSeries) String() string { return proto.CompactTextString(m) }
+func (*promTimeSeries) ProtoMessage()    {}
+
+func (m *promLabel) Reset()         { *m = promLabel{} }
+func (m *promLabel) String() string { return proto.CompactTextString(m) }
+func (*promLabel) ProtoMessage()    {}
+
+func (m *promSample) Reset()         { *m = promSample{} }
+func (m *promSample) String() string { return proto.CompactTextString(m) }
+func (*promSample) ProtoMessage()    {}
+
+type applicationPrometheus struct {
+	applications map[string]application
+	instances    map[string]applicationInstance
+	update       *sql.Stmt
+	insert       *sql.Stmt
+}
+
+/*
+save saves snappy compressed WriteRequest protobufs from the request body
+as app metrics.  The request is saved in one transaction so a failed request
+can be retried by Prometheus without saving part of it twice.  Series with a mapped
+name but without applicationID or instanceID labels are skipped and reported with
+a 400 (after the other series are saved) so that they are not retried.
+*/
+func (a *applicationPrometheus) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	c, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	by, err := snappy.Decode(nil, c)
+	if err != nil {
+		return weft.BadRequest("invalid snappy encoding")
+	}
+
+	var wr promWriteRequest
+
+	if err = proto.Unmarshal(by, &wr); err != nil {
+		return weft.BadRequest("invalid WriteRequest protobuf")
+	}
+
+	a.applications = make(map[string]application)
+	a.instances = make(map[string]applicationInstance)
+
+	txn, err := db.Begin()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	// The primary key on app.metric would abort the transaction for a repeated time
+	// so update existing values instead of relying on the unique violation.
+	if a.update, err = txn.Prepare(`UPDATE app.metric SET value = $5
+		WHERE applicationPK = $1 AND instancePK = $2 AND typePK = $3 AND time = $4`); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if a.insert, err = txn.Prepare(`INSERT INTO app.metric (applicationPK, instancePK, typePK, time, value)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (SELECT 1 FROM app.metric WHERE applicationPK = $1 AND instancePK = $2 AND typePK = $3 AND time = $4)`); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	var skipped int
+
+	for _, s := range wr.Timeseries {
+		ok, res := a.saveSeries(txn, s)
+		if !res.Ok {
+			txn.Rollback()
+			return res
+		}
+
+		if !ok {
+			skipped++
+		}
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	// The rest of the request has been saved.  Skipped series are logged rather than
+	// reported to the client; they will never be saved so a retry doesn't help.
+	if skipped > 0 {
+		log.Printf("WARN: Prometheus write skipped %d series without %s or %s labels",
+			skipped, promLabels["applicationID"], promLabels["instanceID"])
+	}
+
+	return &weft.StatusOK
+}
+
+// saveSeries saves the samples in s.  Series with names that are not mapped to a type are ignored.
+// ok is false for series with a mapped name that could not be saved because they are missing labels.
+func (a *applicationPrometheus) saveSeries(txn *sql.Tx, s *promTimeSeries) (ok bool, res *weft.Result) {
+	var name, applicationID, instanceID string
+
+	for _, l := range s.Labels {
+		switch l.Name {
+		case "__name__":
+			name = l.Value
+		case promLabels["applicationID"]:
+			applicationID = l.Value
+		}
+
+		// not in the switch; the same label could be used for applicationID and instanceID.
+		if l.Name == promLabels["instanceID"] {
+			instanceID = l.Value
+		}
+	}
+
+	typePK, ok := promTypes[name]
+	if !ok {
+		return true, &weft.StatusOK
+	}
+
+	if applicationID == "" || instanceID == "" {
+		return false, &weft.StatusOK
+	}
+
+	app, ok := a.applications[applicationID]
+	if !ok {
+		app.applicationID = applicationID
+		if res := app.loadTx(txn); !res.Ok {
+			return false, res
+		}
+		a.applications[applicationID] = app
+	}
+
+	inst, ok := a.instances[instanceID]
+	if !ok {
+		inst.instanceID = instanceID
+		if res := inst.loadTx(txn); !res.Ok {
+			return false, res
+		}
+		a.instances[instanceID] = inst
+	}
+
+	for _, v := range s.Samples {
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			continue
+		}
+
+		t := time.Unix(0, v.Timestamp*int64(time.Millisecond)).UTC()
+		value := int64(math.Floor(v.Value + 0.5))
+
+		// The same series can be sent more than once in a second (time is stored to the second).
+		// Keep the latest value.
+		r, err := a.update.Exec(app.applicationPK, inst.instancePK, int(typePK), t, value)
+		if err != nil {
+			return false, weft.InternalServerError(err)
+		}
+
+		if i, err := r.RowsAffected(); err == nil && i > 0 {
+			continue
+		}
+
+		if _, err = a.insert.Exec(app.applicationPK, inst.instancePK, int(typePK), t, value); err != nil {
+			return false, weft.InternalServerError(err)
+		}
+	}
+
+	return true, &weft.StatusOK
+}
+
+// checkPromTypes removes, and logs, mappings in promTypes to typePKs that are not in app.type.
+// Saving them would fail the foreign key on app.metric and Prometheus would retry the
+// request forever.
+func checkPromTypes() error {
+	rows, err := dbR.Query(`SELECT typePK FROM app.type`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := make(map[internal.ID]bool)
+
+	for rows.Next() {
+		var i int
+		if err = rows.Scan(&i); err != nil {
+			return err
+		}
+		types[internal.ID(i)] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for k, v := range promTypes {
+		if !types[v] {
+			log.Printf("ERROR: Prometheus type rule %s=%d is not an app type, ignoring it", k, int(v))
+			delete(promTypes, k)
+		}
+	}
+
+	return nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-
 	"github.com/GeoNet/weft"
 	"net/http"
 	"strconv"
@@ -51,8 +50,13 @@ func (a *applicationTimer) save(r *http.Request) *weft.Result {
 		return res
 	}
 
+	return a.insert()
+}
+
+// insert saves the timer.  The application and source PKs must be loaded.
+func (a *applicationTimer) insert() *weft.Result {
 	// TODO - what to do when sending from multiple instances and primary key violations?
-	if _, err = db.Exec(`INSERT INTO app.timer(applicationPK, sourcePK, time, average, count, fifty, ninety) VALUES($1,$2,$3,$4,$5,$6,$7)`,
+	if _, err := db.Exec(`INSERT INTO app.timer(applicationPK, sourcePK, time, average, count, fifty, ninety) VALUES($1,$2,$3,$4,$5,$6,$7)`,
 		a.applicationPK, a.sourcePK, a.t, a.average, a.count, a.fifty, a.ninety); err != nil {
 		return weft.InternalServerError(err)
 	}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/weft"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const day = time.Hour * 24
+
+// availabilityRetention is the retention for field.metric and data.latency.  It is read from
+// MTR_METRIC_RETENTION_DAYS (default 40).  Older metrics are deleted by deleteMetrics.
+// Days before this that were not rolled up can't be computed and are reported as missing.
+var availabilityRetention = metricRetention()
+
+// availabilityBackfill is how far back rollups are made for days that have not been rolled up.
+// It is inside availabilityRetention.
+var availabilityBackfill = availabilityRetention - day*2
+
+// metricRetention returns the metric retention from MTR_METRIC_RETENTION_DAYS.  It is used to
+// initialise availabilityRetention so that it is set before the init funcs that check against it.
+func metricRetention() time.Duration {
+	if s := os.Getenv("MTR_METRIC_RETENTION_DAYS"); s != "" {
+		if d, err := strconv.Atoi(s); err == nil && d > 2 {
+			return day * time.Duration(d)
+		}
+		log.Printf("ERROR: invalid MTR_METRIC_RETENTION_DAYS %s", s)
+	}
+
+	return day * 40
+}
+
+// availability is the time a metric was good, bad, late, or missing.
+type availability struct {
+	good, bad, late, missing time.Duration
+}
+
+func (a *availability) add(b availability) {
+	a.good += b.good
+	a.bad += b.bad
+	a.late += b.late
+	a.missing += b.missing
+}
+
+func (a availability) total() time.Duration {
+	return a.good + a.bad + a.late + a.missing
+}
+
+// count adds the time from start to end when last was the latest value.  last is nil if there
+// are no values yet.  The status of a value lasts for gapFactor expected intervals, as for metric
+// gaps.  After that the time is missing until the metric is late at lateAfter.
+func (a *availability) count(last *incidentPoint, start, end time.Time, interval time.Duration) {
+	if !end.After(start) {
+		return
+	}
+
+	if last == nil {
+		a.missing += end.Sub(start)
+		return
+	}
+
+	clamp := func(t time.Time) time.Time {
+		switch {
+		case t.Before(start):
+			return start
+		case t.After(end):
+			return end
+		}
+		return t
+	}
+
+	s := clamp(last.t.Add(interval * gapFactor))
+	l := clamp(last.t.Add(lateAfter))
+
+	if l.Before(s) {
+		l = s
+	}
+
+	if last.excess() > 0 {
+		a.bad += s.Sub(start)
+	} else {
+		a.good += s.Sub(start)
+	}
+
+	a.missing += l.Sub(s)
+	a.late += end.Sub(l)
+}
+
+// metricAvailability returns the availability from start to end for one metric with values expected
+// every interval.  prev is the latest value before start or nil if there isn't one.  pts are the values
+// from start to end in time order.
+func metricAvailability(prev *incidentPoint, pts []incidentPoint, start, end time.Time, interval time.Duration) availability {
+	var a availability
+
+	last := prev
+	t := start
+
+	for i := range pts {
+		a.count(last, t, pts[i].t, interval)
+		last = &pts[i]
+		t = pts[i].t
+	}
+
+	a.count(last, t, end, interval)
+
+	return a
+}
+
+// availabilityKind computes and rolls up availability for field metrics or data latencies.
+type availabilityKind struct {
+	table     string                          // the rollup table e.g., field.metric_availability
+	dayTable  string                          // the days that have been rolled up e.g., field.metric_availability_day
+	pkCol     string                          // the device or site pk column e.g., devicePK
+	metricSQL string                          // selects pk, typePK for the metrics.
+	pointSQL  string                          // selects pk, typePK, time, value, lower, upper from $1 to $2 ordered by pk, typePK, time.
+	prevSQL   string                          // selects pk, typePK, time, value, lower, upper for the latest value before $1.
+	intervals func() (metricIntervals, error) // the expected intervals, nil for defaultInterval.
+}
+
+var fieldAvailability = availabilityKind{
+	table:     "field.metric_availability",
+	dayTable:  "field.metric_availability_day",
+	pkCol:     "devicePK",
+	metricSQL: `SELECT devicePK, typePK FROM field.metric_summary`,
+	pointSQL: `SELECT devicePK, typePK, time, value, COALESCE(lower, 0), COALESCE(upper, 0)
+		FROM field.metric LEFT OUTER JOIN field.threshold USING (devicePK, typePK)
+		WHERE time >= $1 AND time < $2 ORDER BY devicePK, typePK, time`,
+	prevSQL: `SELECT s.devicePK, s.typePK, p.time, p.value, s.lower, s.upper
+		FROM field.metric_summary s CROSS JOIN LATERAL (SELECT time, value FROM field.metric
+			WHERE devicePK = s.devicePK AND typePK = s.typePK AND time < $1
+			ORDER BY rate_limit DESC LIMIT 1) p`,
+	intervals: loadMetricIntervals,
+}
+
+var dataAvailability = availabilityKind{
+	table:     "data.latency_availability",
+	dayTable:  "data.latency_availability_day",
+	pkCol:     "sitePK",
+	metricSQL: `SELECT sitePK, typePK FROM data.latency_summary`,
+	pointSQL: `SELECT sitePK, typePK, time, mean, COALESCE(lower, 0), COALESCE(upper, 0)
+		FROM data.latency LEFT OUTER JOIN data.latency_threshold USING (sitePK, typePK)
+		WHERE time >= $1 AND time < $2 ORDER BY sitePK, typePK, time`,
+	prevSQL: `SELECT s.sitePK, s.typePK, p.time, p.mean, s.lower, s.upper
+		FROM data.latency_summary s CROSS JOIN LATERAL (SELECT time, mean FROM data.latency
+			WHERE sitePK = s.sitePK AND typePK = s.typePK AND time < $1
+			ORDER BY rate_limit DESC LIMIT 1) p`,
+}
+
+// compute returns the availability for each metric from start to end using the raw values.
+func (k availabilityKind) compute(start, end time.Time) (map[incidentKey]availability, error) {
+	var iv metricIntervals
+
+	if k.intervals != nil {
+		var err error
+		if iv, err = k.intervals(); err != nil {
+			return nil, err
+		}
+	}
+
+	prev := make(map[incidentKey]*incidentPoint)
+	pts := make(map[incidentKey][]incidentPoint)
+
+	rows, err := dbR.Query(k.prevSQL, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m incidentKey
+		var p incidentPoint
+
+		if err = rows.Scan(&m.pk, &m.typePK, &p.t, &p.value, &p.lower, &p.upper); err != nil {
+			return nil, err
+		}
+
+		prev[m] = &p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	if rows, err = dbR.Query(k.pointSQL, start, end); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m incidentKey
+		var p incidentPoint
+
+		if err = rows.Scan(&m.pk, &m.typePK, &p.t, &p.value, &p.lower, &p.upper); err != nil {
+			return nil, err
+		}
+
+		pts[m] = append(pts[m], p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	a := make(map[incidentKey]availability)
+
+	for m, p := range prev {
+		a[m] = metricAvailability(p, pts[m], start, end, iv.interval(m))
+	}
+
+	for m, p := range pts {
+		if _, ok := a[m]; !ok {
+			a[m] = metricAvailability(nil, p, start, end, iv.interval(m))
+		}
+	}
+
+	return a, nil
+}
+
+// rolledUp returns the days from start to end (inclusive) that have been rolled up.
+func (k availabilityKind) rolledUp(start, end time.Time) (map[time.Time]bool, error) {
+	rows, err := dbR.Query(`SELECT day FROM `+k.dayTable+` WHERE day >= $1 AND day <= $2`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[time.Time]bool)
+
+	for rows.Next() {
+		var d time.Time
+
+		if err = rows.Scan(&d); err != nil {
+			return nil, err
+		}
+
+		days[time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	return days, rows.Err()
+}
+
+// rollup replaces the rollups for the day starting at d.  The day is marked as rolled up
+// even if there were no metrics.
+func (k availabilityKind) rollup(d time.Time) error {
+	a, err := k.compute(d, d.Add(day))
+	if err != nil {
+		return err
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = txn.Exec(`DELETE FROM `+k.table+` WHERE day = $1`, d); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	for m, v := range a {
+		if _, err = txn.Exec(`INSERT INTO `+k.table+`(`+k.pkCol+`, typePK, day, good, bad, late, missing)
+			VALUES($1, $2, $3, $4, $5, $6, $7)`, m.pk, m.typePK, d,
+			int(v.good.Seconds()), int(v.bad.Seconds()), int(v.late.Seconds()), int(v.missing.Seconds())); err != nil {
+			txn.Rollback()
+			return err
+		}
+	}
+
+	if _, err = txn.Exec(`INSERT INTO `+k.dayTable+`(day) SELECT $1::DATE
+		WHERE NOT EXISTS (SELECT 1 FROM `+k.dayTable+` WHERE day = $1)`, d); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// read returns the availability for each metric for the days from start to end (inclusive).  Rollups are
+// used for days that have them, otherwise availability is computed from the raw values up to now.  Days
+// that were not rolled up before the raw values were deleted are missing for every metric.
+func (k availabilityKind) read(start, end, now time.Time) (map[incidentKey]availability, error) {
+	days, err := k.rolledUp(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	a := make(map[incidentKey]availability)
+
+	rows, err := dbR.Query(`SELECT `+k.pkCol+`, typePK, good, bad, late, missing FROM `+k.table+`
+		WHERE day >= $1 AND day <= $2`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m incidentKey
+		var good, bad, late, missing int64
+
+		if err = rows.Scan(&m.pk, &m.typePK, &good, &bad, &late, &missing); err != nil {
+			return nil, err
+		}
+
+		v := a[m]
+		v.add(availability{
+			good:    time.Duration(good) * time.Second,
+			bad:     time.Duration(bad) * time.Second,
+			late:    time.Duration(late) * time.Second,
+			missing: time.Duration(missing) * time.Second,
+		})
+		a[m] = v
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	var expired int
+
+	for d := start; !d.After(end) && d.Before(now); d = d.Add(day) {
+		if days[d] {
+			continue
+		}
+
+		if d.Before(now.Add(-availabilityRetention)) {
+			expired++
+			continue
+		}
+
+		e := d.Add(day)
+		if e.After(now) {
+			e = now
+		}
+
+		r, err := k.compute(d, e)
+		if err != nil {
+			return nil, err
+		}
+
+		for m, x := range r {
+			v := a[m]
+			v.add(x)
+			a[m] = v
+		}
+	}
+
+	if expired == 0 {
+		return a, nil
+	}
+
+	if rows, err = dbR.Query(k.metricSQL); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m incidentKey
+
+		if err = rows.Scan(&m.pk, &m.typePK); err != nil {
+			return nil, err
+		}
+
+		if _, ok := a[m]; !ok {
+			a[m] = availability{}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for m, v := range a {
+		v.missing += day * time.Duration(expired)
+		a[m] = v
+	}
+
+	return a, nil
+}
+
+func availabilityTimed() {
+	ticker := time.NewTicker(time.Hour).C
+	for {
+		select {
+		case <-ticker:
+			if err := rollupAvailability(time.Now().UTC()); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// rollupAvailability rolls up complete days back to availabilityBackfill that have not been rolled up.
+// Yesterday is always rolled up again to include metrics that arrive late.
+func rollupAvailability(now time.Time) error {
+	today := now.Truncate(day)
+	yesterday := today.Add(-day)
+
+	for _, k := range []availabilityKind{fieldAvailability, dataAvailability} {
+		days, err := k.rolledUp(today.Add(-availabilityBackfill), yesterday)
+		if err != nil {
+			return err
+		}
+
+		for d := today.Add(-availabilityBackfill); d.Before(today); d = d.Add(day) {
+			if days[d] && !d.Equal(yesterday) {
+				continue
+			}
+
+			if err = k.rollup(d); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// availabilityMetric is the availability for a field metric or data latency.
+type availabilityMetric struct {
+	deviceID, modelID, siteID, typeID string
+	tags                              []string
+	a                                 availability
+}
+
+// availabilityRow is the availability for a metric or a group of metrics.
+type availabilityRow struct {
+	ID      string
+	TypeID  string `json:",omitempty"`
+	Minutes int64
+	Good    float64
+	Bad     float64
+	Late    float64
+	Missing float64
+}
+
+// availabilityReport is the percentage of the time metrics were good, bad, late, or missing over a date range.
+type availabilityReport struct {
+	From, To string
+	GroupBy  string
+	Rows     []availabilityRow
+}
+
+// read reads the report for the from and to (inclusive) dates e.g., 2016-01-31 in the query.  groupBy is one of
+// metric (default), device, site, model, or tag.  Metrics can be selected with deviceID, siteID, modelID, typeID, tag,
+// and state (default active).
+func (rp *availabilityReport) read(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"from", "to"}, []string{"groupBy", "deviceID", "siteID", "modelID", "typeID", "tag", "state"}); !res.Ok {
+		return res
+	}
+
+	var state stateFilter
+
+	if res := state.read(r); !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	start, err := time.Parse("2006-01-02", v.Get("from"))
+	if err != nil {
+		return weft.BadRequest("invalid from")
+	}
+
+	end, err := time.Parse("2006-01-02", v.Get("to"))
+	if err != nil {
+		return weft.BadRequest("invalid to")
+	}
+
+	if end.Before(start) {
+		return weft.BadRequest("to is before from")
+	}
+
+	if end.Sub(start) > day*366 {
+		return weft.BadRequest("date range longer than one year")
+	}
+
+	rp.From = v.Get("from")
+	rp.To = v.Get("to")
+	rp.GroupBy = v.Get("groupBy")
+
+	if rp.GroupBy == "" {
+		rp.GroupBy = "metric"
+	}
+
+	switch rp.GroupBy {
+	case "metric", "device", "site", "model", "tag":
+	default:
+		return weft.BadRequest("invalid groupBy")
+	}
+
+	now := time.Now().UTC()
+
+	var metrics []availabilityMetric
+
+	for _, k := range []availabilityKind{fieldAvailability, dataAvailability} {
+		a, err := k.read(start, end, now)
+		if err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		m, err := k.metrics(a, state)
+		if err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		metrics = append(metrics, m...)
+	}
+
+	groups := make(map[availabilityRow]availability)
+
+	for _, m := range metrics {
+		if !m.selected(v.Get("deviceID"), v.Get("siteID"), v.Get("modelID"), v.Get("typeID"), v.Get("tag")) {
+			continue
+		}
+
+		for _, g := range m.groups(rp.GroupBy) {
+			a := groups[g]
+			a.add(m.a)
+			groups[g] = a
+		}
+	}
+
+	for g, a := range groups {
+		t := a.total()
+
+		if t == 0 {
+			continue
+		}
+
+		g.Minutes = int64(t.Minutes())
+		g.Good = percent(a.good, t)
+		g.Bad = percent(a.bad, t)
+		g.Late = percent(a.late, t)
+		g.Missing = percent(a.missing, t)
+
+		rp.Rows = append(rp.Rows, g)
+	}
+
+	sort.Sort(availabilityRows(rp.Rows))
+
+	return &weft.StatusOK
+}
+
+// percent returns d as a percentage of t to 2 decimal places.
+func percent(d, t time.Duration) float64 {
+	p, _ := strconv.ParseFloat(strconv.FormatFloat(100*d.Seconds()/t.Seconds(), 'f', 2, 64), 64)
+	return p
+}
+
+// metrics looks up the IDs and tags for the availability in a.  Metrics for devices or sites
+// that are not in state are skipped.
+func (k availabilityKind) metrics(a map[incidentKey]availability, state stateFilter) ([]availabilityMetric, error) {
+	var ids, types, tags string
+	var args []interface{}
+
+	switch k.pkCol {
+	case "devicePK":
+		ids = `SELECT devicePK, deviceID, modelID FROM field.device JOIN field.model USING (modelPK) WHERE ` + state.sql("state", &args)
+		types = `SELECT typePK, typeID FROM field.type`
+		tags = `SELECT devicePK, typePK, tag FROM field.metric_tag JOIN mtr.tag USING (tagPK)`
+	default:
+		ids = `SELECT sitePK, siteID, '' FROM data.site WHERE ` + state.sql("state", &args)
+		types = `SELECT typePK, typeID FROM data.type`
+		tags = `SELECT sitePK, typePK, tag FROM data.latency_tag JOIN mtr.tag USING (tagPK)`
+	}
+
+	idm := make(map[int][2]string)
+	tm := make(map[int]string)
+	tagm := make(map[incidentKey][]string)
+
+	rows, err := dbR.Query(ids, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pk int
+		var id, model string
+
+		if err = rows.Scan(&pk, &id, &model); err != nil {
+			return nil, err
+		}
+
+		idm[pk] = [2]string{id, model}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	if rows, err = dbR.Query(types); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pk int
+		var id string
+
+		if err = rows.Scan(&pk, &id); err != nil {
+			return nil, err
+		}
+
+		tm[pk] = id
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	if rows, err = dbR.Query(tags); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m incidentKey
+		var tag string
+
+		if err = rows.Scan(&m.pk, &m.typePK, &tag); err != nil {
+			return nil, err
+		}
+
+		tagm[m] = append(tagm[m], tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var l []availabilityMetric
+
+	for m, v := range a {
+		if _, ok := idm[m.pk]; !ok {
+			continue
+		}
+
+		x := availabilityMetric{typeID: tm[m.typePK], tags: tagm[m], a: v}
+
+		if k.pkCol == "devicePK" {
+			x.deviceID = idm[m.pk][0]
+			x.modelID = idm[m.pk][1]
+		} else {
+			x.siteID = idm[m.pk][0]
+		}
+
+		l = append(l, x)
+	}
+
+	return l, nil
+}
+
+// selected returns true if m matches the non empty selectors.
+func (m availabilityMetric) selected(deviceID, siteID, modelID, typeID, tag string) bool {
+	switch {
+	case deviceID != "" && m.deviceID != deviceID:
+		return false
+	case siteID != "" && m.siteID != siteID:
+		return false
+	case modelID != "" && m.modelID != modelID:
+		return false
+	case typeID != "" && m.typeID != typeID:
+		return false
+	}
+
+	if tag == "" {
+		return true
+	}
+
+	for _, t := range m.tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// groups returns the report rows m is included in.  Devices and models are for field metrics,
+// sites are for data latencies.  A metric is in the group for each of its tags.
+func (m availabilityMetric) groups(groupBy string) []availabilityRow {
+	switch groupBy {
+	case "device":
+		if m.deviceID != "" {
+			return []availabilityRow{{ID: m.deviceID}}
+		}
+	case "site":
+		if m.siteID != "" {
+			return []availabilityRow{{ID: m.siteID}}
+		}
+	case "model":
+		if m.modelID != "" {
+			return []availabilityRow{{ID: m.modelID}}
+		}
+	case "tag":
+		var g []availabilityRow
+		for _, t := range m.tags {
+			g = append(g, availabilityRow{ID: t})
+		}
+		return g
+	default:
+		return []availabilityRow{{ID: m.deviceID + m.siteID, TypeID: m.typeID}}
+	}
+
+	return nil
+}
+
+type availabilityRows []availabilityRow
+
+func (a availabilityRows) Len() int      { return len(a) }
+func (a availabilityRows) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a availabilityRows) Less(i, j int) bool {
+	if a[i].ID != a[j].ID {
+		return a[i].ID < a[j].ID
+	}
+	return a[i].TypeID < a[j].TypeID
+}
+
+func (rp *availabilityReport) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := rp.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := json.Marshal(rp)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+func (rp *availabilityReport) csv(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := rp.read(r); !res.Ok {
+		return res
+	}
+
+	w := csv.NewWriter(b)
+
+	w.Write([]string{"id", "typeID", "minutes", "good", "bad", "late", "missing"})
+
+	for _, v := range rp.Rows {
+		w.Write([]string{v.ID, v.TypeID, strconv.FormatInt(v.Minutes, 10),
+			fmt.Sprintf("%.2f", v.Good), fmt.Sprintf("%.2f", v.Bad), fmt.Sprintf("%.2f", v.Late), fmt.Sprintf("%.2f", v.Missing)})
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	h.Set("Content-Type", "text/csv")
+
+	return &weft.StatusOK
+}
+
+var availabilityTemplate = template.Must(template.New("availability").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Availability {{.From}} to {{.To}}</title></head>
+<body>
+<h3>Availability by {{.GroupBy}} {{.From}} to {{.To}}</h3>
+<table>
+<tr><th>ID</th><th>Type</th><th>Minutes</th><th>Good %</th><th>Bad %</th><th>Late %</th><th>Missing %</th></tr>
+{{range .Rows}}<tr><td>{{.ID}}</td><td>{{.TypeID}}</td><td>{{.Minutes}}</td><td>{{printf "%.2f" .Good}}</td><td>{{printf "%.2f" .Bad}}</td><td>{{printf "%.2f" .Late}}</td><td>{{printf "%.2f" .Missing}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func (rp *availabilityReport) html(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := rp.read(r); !res.Ok {
+		return res
+	}
+
+	if err := availabilityTemplate.Execute(b, rp); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+
+	return &weft.StatusOK
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// panelPlot is a window and resolution for a dashboard panel.
+type panelPlot struct {
+	window, resolution string
+}
+
+// panelPlots are the windows and resolutions of the existing plots and the query
+// for each.  A 12 hour window at five_minutes is a spark line.
+var panelPlots = map[panelPlot]url.Values{
+	{"12h", "minute"}:       {"resolution": {"minute"}},
+	{"12h", "five_minutes"}: {"plot": {"spark"}},
+	{"48h", "five_minutes"}: {"resolution": {"five_minutes"}},
+	{"28d", "hour"}:         {"resolution": {"hour"}},
+}
+
+// panelResolution is the default resolution for a window.
+var panelResolution = map[string]string{
+	"12h": "minute",
+	"48h": "five_minutes",
+	"28d": "hour",
+}
+
+// appGroups are the groups for application metric plots.
+var appGroups = map[string]bool{
+	"counters": true,
+	"timers":   true,
+	"memory":   true,
+	"objects":  true,
+	"routines": true,
+}
+
+// panelPlotPath returns the path and query for the SVG plot for a panel.  The panel must be valid.
+func panelPlotPath(p *mtrpb.DashboardPanel) string {
+	q := url.Values{}
+
+	for k, v := range panelPlots[panelPlot{p.Window, p.Resolution}] {
+		q[k] = v
+	}
+
+	var path string
+
+	switch p.Kind {
+	case "field":
+		path = "/field/metric"
+		q.Set("deviceID", p.SourceID)
+		q.Set("typeID", p.TypeID)
+	case "data":
+		path = "/data/latency"
+		q.Set("siteID", p.SourceID)
+		q.Set("typeID", p.TypeID)
+	case "app":
+		path = "/app/metric"
+		q.Set("applicationID", p.SourceID)
+		q.Set("group", p.TypeID)
+	}
+
+	return path + "?" + q.Encode()
+}
+
+// dashboard is a named board of plots.
+type dashboard struct {
+	name string
+}
+
+// dashboardPK returns the pk for the dashboard name.  owner must be the owner of the dashboard.
+//
+// owner is a query parameter chosen by the caller and all writes use the same basic auth user,
+// so ownership guards against changing someone else's dashboard by mistake.  It does not
+// authorise anything; anyone with the write credentials can use any owner.
+func dashboardPK(name, owner string) (int, *weft.Result) {
+	var pk int
+	var o string
+
+	if err := dbR.QueryRow(`SELECT dashboardPK, owner FROM mtr.dashboard WHERE name = $1`, name).Scan(&pk, &o); err != nil {
+		if err == sql.ErrNoRows {
+			return pk, weft.BadRequest("unknown dashboard " + name)
+		}
+		return pk, weft.InternalServerError(err)
+	}
+
+	if o != owner {
+		return pk, notOwner(name)
+	}
+
+	return pk, &weft.StatusOK
+}
+
+// notOwner is the result for a change to a dashboard with a different owner (see dashboardPK).
+func notOwner(name string) *weft.Result {
+	return &weft.Result{Ok: false, Code: http.StatusForbidden, Msg: "not the owner of dashboard " + name}
+}
+
+// save creates the dashboard or updates the description.  Only the owner can update a dashboard
+// and they can give it to newOwner.
+func (d *dashboard) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"name", "owner"}, []string{"description", "newOwner"}); !res.Ok {
+		return res
+	}
+
+	d.name = r.URL.Query().Get("name")
+
+	if d.name == "" || strings.ContainsAny(d.name, "/ ") {
+		return weft.BadRequest("invalid name")
+	}
+
+	owner := r.URL.Query().Get("owner")
+	description := r.URL.Query().Get("description")
+
+	if owner == "" {
+		return weft.BadRequest("invalid owner")
+	}
+
+	newOwner := owner
+
+	if v := r.URL.Query().Get("newOwner"); v != "" {
+		newOwner = v
+	}
+
+	if _, err := db.Exec(`INSERT INTO mtr.dashboard(name, owner, description) VALUES($1, $2, $3)`,
+		d.name, newOwner, description); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			u, err := db.Exec(`UPDATE mtr.dashboard SET owner=$3, description=$4 WHERE name=$1 AND owner=$2`,
+				d.name, owner, newOwner, description)
+			if err != nil {
+				return weft.InternalServerError(err)
+			}
+
+			n, err := u.RowsAffected()
+			if err != nil {
+				return weft.InternalServerError(err)
+			}
+
+			if n == 0 {
+				return notOwner(d.name)
+			}
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+// delete deletes the dashboard if owner is its owner.  This cascades to the panels.
+func (d *dashboard) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"name", "owner"}, []string{}); !res.Ok {
+		return res
+	}
+
+	pk, res := dashboardPK(r.URL.Query().Get("name"), r.URL.Query().Get("owner"))
+	if !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM mtr.dashboard WHERE dashboardPK = $1`, pk); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// read returns all dashboards, without panels, or the dashboard for the name query parameter with its panels.
+// The result is empty for an unknown name.
+func (d *dashboard) read(r *http.Request) ([]*mtrpb.Dashboard, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{"name"}); !res.Ok {
+		return nil, res
+	}
+
+	d.name = r.URL.Query().Get("name")
+
+	rows, err := dbR.Query(`SELECT name, owner, description FROM mtr.dashboard
+		WHERE ($1 = '' OR name = $1) ORDER BY name ASC`, d.name)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.Dashboard
+
+	for rows.Next() {
+		var v mtrpb.Dashboard
+
+		if err = rows.Scan(&v.Name, &v.Owner, &v.Description); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		l = append(l, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	if d.name == "" || len(l) == 0 {
+		return l, &weft.StatusOK
+	}
+
+	rows, err = dbR.Query(`SELECT position, kind, sourceID, typeID, time_window, resolution
+		FROM mtr.dashboard_panel JOIN mtr.dashboard USING (dashboardPK)
+		WHERE name = $1 ORDER BY position ASC`, d.name)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p mtrpb.DashboardPanel
+
+		if err = rows.Scan(&p.Position, &p.Kind, &p.SourceID, &p.TypeID, &p.Window, &p.Resolution); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		p.Plot = panelPlotPath(&p)
+
+		l[0].Panels = append(l[0].Panels, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+func (d *dashboard) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var dr mtrpb.DashboardResult
+	var res *weft.Result
+
+	if dr.Result, res = d.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&dr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type dashboardPanelJSON struct {
+	Position   int32
+	Kind       string
+	SourceID   string
+	TypeID     string
+	Window     string
+	Resolution string
+	Plot       string
+}
+
+type dashboardJSON struct {
+	Name        string
+	Owner       string
+	Description string
+	Panels      []dashboardPanelJSON `json:",omitempty"`
+}
+
+func (d *dashboard) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := d.read(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]dashboardJSON, len(l))
+
+	for i, v := range l {
+		j[i] = dashboardJSON{Name: v.Name, Owner: v.Owner, Description: v.Description}
+
+		for _, p := range v.Panels {
+			j[i].Panels = append(j[i].Panels, dashboardPanelJSON{
+				Position:   p.Position,
+				Kind:       p.Kind,
+				SourceID:   p.SourceID,
+				TypeID:     p.TypeID,
+				Window:     p.Window,
+				Resolution: p.Resolution,
+				Plot:       p.Plot,
+			})
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+// dashboardPanel is a plot on a dashboard.
+type dashboardPanel struct{}
+
+// panel returns the panel from the query parameters.  One of deviceID, siteID, or applicationID selects
+// the kind of plot.  The ids are checked.
+func (d *dashboardPanel) panel(r *http.Request) (*mtrpb.DashboardPanel, *weft.Result) {
+	v := r.URL.Query()
+
+	position, err := strconv.Atoi(v.Get("position"))
+	if err != nil || position < 0 || position > 32767 {
+		return nil, weft.BadRequest("invalid position")
+	}
+
+	p := mtrpb.DashboardPanel{Position: int32(position), Window: v.Get("window"), Resolution: v.Get("resolution")}
+
+	if p.Window == "" {
+		p.Window = "12h"
+	}
+
+	if p.Resolution == "" {
+		p.Resolution = panelResolution[p.Window]
+	}
+
+	if _, ok := panelPlots[panelPlot{p.Window, p.Resolution}]; !ok {
+		return nil, weft.BadRequest("invalid window and resolution " + p.Window + " " + p.Resolution)
+	}
+
+	var res *weft.Result
+
+	switch {
+	case v.Get("deviceID") != "" && v.Get("typeID") != "":
+		p.Kind, p.SourceID, p.TypeID = "field", v.Get("deviceID"), v.Get("typeID")
+
+		if _, res = fieldDevicePK(p.SourceID); !res.Ok {
+			return nil, res
+		}
+
+		if _, res = loadFieldType(p.TypeID); !res.Ok {
+			return nil, res
+		}
+	case v.Get("siteID") != "" && v.Get("typeID") != "":
+		p.Kind, p.SourceID, p.TypeID = "data", v.Get("siteID"), v.Get("typeID")
+
+		var s dataSite
+
+		if res = s.loadPK(r); !res.Ok {
+			return nil, res
+		}
+
+		if _, res = loadDataType(p.TypeID); !res.Ok {
+			return nil, res
+		}
+	case v.Get("applicationID") != "" && v.Get("group") != "":
+		p.Kind, p.SourceID, p.TypeID = "app", v.Get("applicationID"), v.Get("group")
+
+		var a appMetric
+
+		if res = a.loadPK(r); !res.Ok {
+			return nil, weft.BadRequest("unknown applicationID")
+		}
+
+		if !appGroups[p.TypeID] {
+			return nil, weft.BadRequest("invalid group " + p.TypeID)
+		}
+
+		if _, ok := panelPlots[panelPlot{p.Window, p.Resolution}]["plot"]; ok {
+			return nil, weft.BadRequest("no spark plots for applications")
+		}
+	default:
+		return nil, weft.BadRequest("one of deviceID and typeID, siteID and typeID, or applicationID and group is required")
+	}
+
+	return &p, &weft.StatusOK
+}
+
+// save adds the panel at position or replaces the panel already there.  owner must be the owner of the dashboard.
+func (d *dashboardPanel) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"name", "owner", "position"},
+		[]string{"deviceID", "siteID", "applicationID", "typeID", "group", "window", "resolution"}); !res.Ok {
+		return res
+	}
+
+	pk, res := dashboardPK(r.URL.Query().Get("name"), r.URL.Query().Get("owner"))
+	if !res.Ok {
+		return res
+	}
+
+	p, res := d.panel(r)
+	if !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`INSERT INTO mtr.dashboard_panel(dashboardPK, position, kind, sourceID, typeID, time_window, resolution)
+		VALUES($1, $2, $3, $4, $5, $6, $7)`, pk, p.Position, p.Kind, p.SourceID, p.TypeID, p.Window, p.Resolution); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			if _, err := db.Exec(`UPDATE mtr.dashboard_panel SET kind=$3, sourceID=$4, typeID=$5, time_window=$6, resolution=$7
+				WHERE dashboardPK=$1 AND position=$2`, pk, p.Position, p.Kind, p.SourceID, p.TypeID, p.Window, p.Resolution); err != nil {
+				return weft.InternalServerError(err)
+			}
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+func (d *dashboardPanel) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"name", "owner", "position"}, []string{}); !res.Ok {
+		return res
+	}
+
+	pk, res := dashboardPK(r.URL.Query().Get("name"), r.URL.Query().Get("owner"))
+	if !res.Ok {
+		return res
+	}
+
+	position, err := strconv.Atoi(r.URL.Query().Get("position"))
+	if err != nil {
+		return weft.BadRequest("invalid position")
+	}
+
+	if _, err = db.Exec(`DELETE FROM mtr.dashboard_panel WHERE dashboardPK = $1 AND position = $2`, pk, position); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
@@ -152,6 +152,10 @@ func (d *dataLatency) delete(r *http.Request) *weft.Result {
 }
 
 func (d *dataLatency) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if r.URL.Query().Get("plot") == "overlay" {
+		return d.overlay(r, h, b)
+	}
+
 	if res := weft.CheckQuery(r, []string{"siteID", "typeID"}, []string{"plot", "resolution", "yrange"}); !res.Ok {
 		return res
 	}
@@ -211,14 +215,23 @@ func (d *dataLatency) plot(resolution string, b *bytes.Buffer) *weft.Result {
 
 	p.SetTitle(fmt.Sprintf("Site: %s - %s", d.siteID, strings.Title(d.dataType.Name)))
 
+	h := newSiteHistory()
+	h.pk = d.sitePK
+
+	if res = h.plotEvents(&p); !res.Ok {
+		return res
+	}
+
 	var err error
 	var rows *sql.Rows
+	var start time.Time // the start of the plot
 
 	// TODO - loading avg(mean) at each resolution.  Need to add max(fifty) and max(ninety) when there are some values.
 
 	switch resolution {
 	case "minute":
-		p.SetXAxis(time.Now().UTC().Add(time.Hour*-12), time.Now().UTC())
+		start = time.Now().UTC().Add(time.Hour * -12)
+		p.SetXAxis(start, time.Now().UTC())
 		p.SetXLabel("12 hours")
 
 		rows, err = dbR.Query(`SELECT date_trunc('`+resolution+`',time) as t, avg(mean) FROM data.latency WHERE
@@ -228,7 +241,8 @@ func (d *dataLatency) plot(resolution string, b *bytes.Buffer) *weft.Result {
 		ORDER BY t ASC`,
 			d.sitePK, d.dataType.typePK)
 	case "five_minutes":
-		p.SetXAxis(time.Now().UTC().Add(time.Hour*-24*2), time.Now().UTC())
+		start = time.Now().UTC().Add(time.Hour * -24 * 2)
+		p.SetXAxis(start, time.Now().UTC())
 		p.SetXLabel("48 hours")
 
 		rows, err = dbR.Query(`SELECT date_trunc('hour', time) + extract(minute from time)::int / 5 * interval '5 min' as t,
@@ -239,7 +253,8 @@ func (d *dataLatency) plot(resolution string, b *bytes.Buffer) *weft.Result {
 		ORDER BY t ASC`,
 			d.sitePK, d.dataType.typePK)
 	case "hour":
-		p.SetXAxis(time.Now().UTC().Add(time.Hour*-24*28), time.Now().UTC())
+		start = time.Now().UTC().Add(time.Hour * -24 * 28)
+		p.SetXAxis(start, time.Now().UTC())
 		p.SetXLabel("4 weeks")
 
 		rows, err = dbR.Query(`SELECT date_trunc('`+resolution+`',time) as t, avg(mean) FROM data.latency WHERE
@@ -285,6 +300,13 @@ func (d *dataLatency) plot(resolution string, b *bytes.Buffer) *weft.Result {
 
 	p.AddSeries(ts.Series{Colour: "deepskyblue", Points: pts})
 
+	m, err := dataBaseline.markers(d.sitePK, d.dataType.typePK, start, d.dataType.Scale)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	p.AddMarkers(m)
+
 	if err = ts.Line.Draw(p, b); err != nil {
 		return weft.InternalServerError(err)
 	}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/mtr/ts"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dataLatencyNetwork is the distribution of latency across all sites, or the sites with latencies matching
+// a tag query, for each time bucket.
+type dataLatencyNetwork struct {
+	typePK     int
+	resolution string
+	query      string
+	expr       tagExpr
+}
+
+// resolutionBucket is the SQL for the time bucket and the window for a plot resolution, as for dataLatency.plot.
+var resolutionBucket = map[string]struct {
+	sql    string
+	window time.Duration
+	label  string
+}{
+	"minute":       {sql: `date_trunc('minute', time)`, window: time.Hour * 12, label: "12 hours"},
+	"five_minutes": {sql: `date_trunc('hour', time) + extract(minute from time)::int / 5 * interval '5 min'`, window: time.Hour * 48, label: "48 hours"},
+	"hour":         {sql: `date_trunc('hour', time)`, window: time.Hour * 24 * 28, label: "4 weeks"},
+}
+
+// read returns the network latency for the typeID (all types if not set), resolution (default minute),
+// and optional tag query in the request.  The query parameters must already have been checked.
+func (d *dataLatencyNetwork) read(r *http.Request) ([]*mtrpb.DataLatencyNetwork, *weft.Result) {
+	v := r.URL.Query()
+
+	if v.Get("typeID") != "" {
+		t, res := loadDataType(v.Get("typeID"))
+		if !res.Ok {
+			return nil, res
+		}
+		d.typePK = t.typePK
+	}
+
+	d.resolution = v.Get("resolution")
+	if d.resolution == "" {
+		d.resolution = "minute"
+	}
+
+	bucket, ok := resolutionBucket[d.resolution]
+	if !ok {
+		return nil, weft.BadRequest("invalid resolution")
+	}
+
+	args := []interface{}{d.typePK, bucket.window.Seconds()}
+	where := "true"
+
+	if d.query = v.Get("query"); d.query != "" {
+		var err error
+
+		if d.expr, err = parseTagQuery(d.query); err != nil {
+			return nil, weft.BadRequest(err.Error())
+		}
+
+		where = d.expr.sql(`EXISTS (SELECT 1 FROM data.latency_tag JOIN mtr.tag USING (tagPK)
+			WHERE sitePK = l.sitePK AND typePK = l.typePK AND tag LIKE $%d)`, &args)
+	}
+
+	// Each site is averaged over the bucket first so that sites are weighted equally at every resolution.
+	// unknown ninety values are 0 and are NULL in s.
+	rows, err := dbR.Query(`WITH s AS (
+			SELECT typePK, sitePK, `+bucket.sql+` AS t, avg(mean) AS mean, avg(ninety) FILTER (WHERE ninety > 0) AS ninety
+			FROM data.latency l
+			WHERE ($1 = 0 OR typePK = $1) AND time > now() - $2 * interval '1 second'
+			AND `+where+`
+			GROUP BY typePK, sitePK, t
+		)
+		SELECT typeID, t,
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY mean),
+		percentile_cont(0.9) WITHIN GROUP (ORDER BY ninety),
+		count(*)
+		FROM s JOIN data.type USING (typePK)
+		GROUP BY typeID, t
+		ORDER BY typeID, t`, args...)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.DataLatencyNetwork
+
+	for rows.Next() {
+		var n mtrpb.DataLatencyNetwork
+		var t time.Time
+		var ninety sql.NullFloat64
+
+		if err = rows.Scan(&n.TypeID, &t, &n.Mean, &ninety, &n.Sites); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		n.Seconds = t.Unix()
+		n.Ninety = ninety.Float64
+
+		l = append(l, &n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+func (d *dataLatencyNetwork) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "resolution", "query"}); !res.Ok {
+		return res
+	}
+
+	var nr mtrpb.DataLatencyNetworkResult
+	var res *weft.Result
+
+	if nr.Result, res = d.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&nr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type dataLatencyNetworkJSON struct {
+	TypeID string
+	Time   string
+	Mean   float64
+	Ninety float64
+	Sites  int32
+}
+
+func (d *dataLatencyNetwork) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "resolution", "query"}); !res.Ok {
+		return res
+	}
+
+	l, res := d.read(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]dataLatencyNetworkJSON, len(l))
+
+	for i, v := range l {
+		j[i] = dataLatencyNetworkJSON{
+			TypeID: v.TypeID,
+			Time:   time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			Mean:   v.Mean,
+			Ninety: v.Ninety,
+			Sites:  v.Sites,
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+/*
+svg plots the median of the site means and the 90th percentile of the site ninety values
+for a type of latency.
+*/
+func (d *dataLatencyNetwork) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"typeID"}, []string{"resolution", "query"}); !res.Ok {
+		return res
+	}
+
+	l, res := d.read(r)
+	if !res.Ok {
+		return res
+	}
+
+	typeID := r.URL.Query().Get("typeID")
+	t := dataTypes[typeID]
+	bucket := resolutionBucket[d.resolution]
+
+	var p ts.Plot
+
+	p.SetUnit(t.Unit)
+	p.SetXAxis(time.Now().UTC().Add(-bucket.window), time.Now().UTC())
+	p.SetXLabel(bucket.label)
+	p.SetTitle(fmt.Sprintf("Network - %s", strings.Title(t.Name)))
+
+	sites := "all sites"
+	if d.query != "" {
+		sites = d.query
+	}
+
+	var mean, ninety []ts.Point
+
+	for _, v := range l {
+		tm := time.Unix(v.Seconds, 0).UTC()
+
+		mean = append(mean, ts.Point{DateTime: tm, Value: v.Mean * t.Scale})
+
+		if v.Ninety > 0 {
+			ninety = append(ninety, ts.Point{DateTime: tm, Value: v.Ninety * t.Scale})
+		}
+	}
+
+	if len(l) > 0 {
+		p.SetLatest(mean[len(mean)-1], "deepskyblue")
+		sites = fmt.Sprintf("%s, %d reporting", sites, l[len(l)-1].Sites)
+	} else {
+		// there is nothing to auto range the y axis on.
+		p.SetYAxis(0, 1)
+	}
+
+	p.SetSubTitle("Sites: " + sites)
+
+	p.AddSeries(ts.Series{Colour: "deepskyblue", Points: mean})
+	p.AddSeries(ts.Series{Colour: "orange", Points: ninety})
+
+	p.SetLabels(ts.Labels{
+		{Label: "median of means", Colour: "deepskyblue"},
+		{Label: "90th percentile of ninety", Colour: "orange"},
+	})
+
+	if err := ts.Line.Draw(p, b); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/map180"
 	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
 	"github.com/golang/protobuf/proto"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,57 +20,285 @@ type dataLatencySummary struct {
 }
 
 func (d *dataLatencySummary) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{}, []string{"typeID"}); !res.Ok {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "state"}); !res.Ok {
 		return res
 	}
 
 	typeID := r.URL.Query().Get("typeID")
 
-	var err error
-	var rows *sql.Rows
+	var sf stateFilter
 
-	switch typeID {
-	case "":
-		rows, err = dbR.Query(`SELECT siteID, typeID, time, mean, fifty, ninety, lower, upper
-		FROM data.latency_summary`)
-	default:
-		rows, err = dbR.Query(`SELECT siteID, typeID, time, mean, fifty, ninety, lower, upper
-		FROM data.latency_summary
-		WHERE typeID = $1;`, typeID)
+	if res := sf.read(r); !res.Ok {
+		return res
 	}
+
+	summaries, err := dataSummaries(`$1 = '' OR s.typeID = $1`, []interface{}{typeID}, sf)
 	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
+	dlsr := mtrpb.DataLatencySummaryResult{Result: summaries}
+
+	var by []byte
+
+	if by, err = proto.Marshal(&dlsr); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+// dataSummaries returns the latest data latency summaries, with the status and anomaly score, for
+// the sites in sf.  where is an SQL boolean expression on data.latency_summary s with its args in args.
+func dataSummaries(where string, args []interface{}, sf stateFilter) ([]*mtrpb.DataLatencySummary, error) {
+	tp, err := loadTopology()
+	if err != nil {
+		return nil, err
+	}
+
+	state := sf.sql("state", &args)
+
+	rows, err := dbR.Query(`SELECT s.siteID, s.typeID, s.time, s.mean, s.fifty, s.ninety, s.lower, s.upper, b.median, b.mad, b.meanad
+		FROM data.latency_summary s `+dataBaselineSQL+`
+		WHERE (`+where+`)
+		AND s.sitePK IN (SELECT sitePK FROM data.site WHERE `+state+`)
+		ORDER BY s.siteID, s.typeID`, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var t time.Time
-	var dlsr mtrpb.DataLatencySummaryResult
+	var summaries []*mtrpb.DataLatencySummary
 
 	for rows.Next() {
-
 		var dls mtrpb.DataLatencySummary
+		var t time.Time
+		var median, mad, meanAD sql.NullFloat64
 
 		if err = rows.Scan(&dls.SiteID, &dls.TypeID, &t, &dls.Mean, &dls.Fifty, &dls.Ninety,
-			&dls.Lower, &dls.Upper); err != nil {
-			return weft.InternalServerError(err)
+			&dls.Lower, &dls.Upper, &median, &mad, &meanAD); err != nil {
+			return nil, err
 		}
 
 		dls.Seconds = t.Unix()
+		dls.Score, dls.Anomalous = anomaly(float64(dls.Mean), median, mad, meanAD)
+		dls.Status, dls.RootCause = tp.dataStatus(dls.SiteID, dataStatus(t, dls.Mean, dls.Fifty, dls.Ninety, dls.Lower, dls.Upper))
+
+		summaries = append(summaries, &dls)
+	}
+
+	return summaries, rows.Err()
+}
+
+func (d *dataLatencySummary) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"bbox", "width", "typeID"}, []string{"state"}); !res.Ok {
+		return res
+	}
+
+	var rows *sql.Rows
+	var width int
+	var err error
+
+	typeID := r.URL.Query().Get("typeID")
+	bbox := mapBboxLookup(r.URL.Query().Get("bbox"))
+
+	if err = map180.ValidBbox(bbox); err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	if width, err = strconv.Atoi(r.URL.Query().Get("width")); err != nil {
+		return weft.BadRequest("invalid width")
+	}
+
+	if _, ok := dataTypes[typeID]; !ok {
+		return weft.BadRequest("invalid type " + typeID)
+	}
+
+	var raw map180.Raw
+	if raw, err = wm.MapRaw(bbox, width); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	var sf stateFilter
+
+	if res := sf.read(r); !res.Ok {
+		return res
+	}
+
+	tp, err := loadTopology()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	args := []interface{}{typeID}
+	state := sf.sql("state", &args)
+
+	if rows, err = dbR.Query(`with p as (select geom, siteID, time, mean, fifty, ninety, lower, upper,
+			st_transform(geom::geometry, 3857) as pt
+			FROM data.latency_summary
+			where typeID = $1
+			AND sitePK IN (SELECT sitePK FROM data.site WHERE `+state+`))
+			select ST_X(pt), ST_Y(pt)*-1, ST_X(geom::geometry),ST_Y(geom::geometry), siteID, time,
+			mean, fifty, ninety, lower, upper from p`, args...); err != nil {
+		return weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	dt := dataTypes[typeID]
+
+	var pts []point
+
+	for rows.Next() {
+		var p point
+		var t time.Time
+		var mean, fifty, ninety, lower, upper int32
+		var siteID string
+
+		if err = rows.Scan(&p.x, &p.y, &p.longitude, &p.latitude, &siteID, &t, &mean, &fifty, &ninety, &lower, &upper); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		p.project(raw)
+
+		var cause []string
+
+		p.status, cause = tp.dataStatus(siteID, dataStatus(t, mean, fifty, ninety, lower, upper))
+		p.title = fmt.Sprintf("%s mean %g %s, %s", siteID, float64(mean)*dt.Scale, dt.Unit, age(t))
+
+		if len(cause) > 0 {
+			p.title += ", impacted by " + strings.Join(cause, ", ")
+		}
+		p.link = plotLink("/data/plot", url.Values{"siteID": {siteID}, "typeID": {typeID}})
 
-		dlsr.Result = append(dlsr.Result, &dls)
+		pts = append(pts, p)
 	}
 	rows.Close()
 
-	var by []byte
+	statusMapSVG(raw, pts, b)
 
-	if by, err = proto.Marshal(&dlsr); err != nil {
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
+
+type dataLatencySummaryProperties struct {
+	SiteID    string   `json:"siteID"`
+	TypeID    string   `json:"typeID"`
+	Time      string   `json:"time"`
+	Mean      float64  `json:"mean"`
+	Fifty     float64  `json:"fifty"`
+	Ninety    float64  `json:"ninety"`
+	Lower     float64  `json:"lower"`
+	Upper     float64  `json:"upper"`
+	Unit      string   `json:"unit"`
+	Status    string   `json:"status"`
+	RootCause []string `json:"rootCause,omitempty"`
+	Tags      []string `json:"tags"`
+}
+
+/*
+geoJSON writes the latest data latency summaries as a GeoJSON FeatureCollection.  Values
+are scaled for display in unit.
+*/
+func (d *dataLatencySummary) geoJSON(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "bbox", "state"}); !res.Ok {
+		return res
+	}
+
+	var s spatialQuery
+
+	if res := s.read(r); !res.Ok {
+		return res
+	}
+
+	var args []interface{}
+
+	q, err := s.selectSQL("data.latency_summary", "sitePK, typePK", "TRUE", &args)
+	if err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	typeID := r.URL.Query().Get("typeID")
+
+	if typeID != "" {
+		if _, ok := dataTypes[typeID]; !ok {
+			return weft.BadRequest("invalid type " + typeID)
+		}
+	}
+
+	var sf stateFilter
+
+	if res := sf.read(r); !res.Ok {
+		return res
+	}
+
+	tp, err := loadTopology()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	args = append(args, typeID)
+	n := strconv.Itoa(len(args))
+	state := sf.sql("state", &args)
+
+	var rows *sql.Rows
+
+	if rows, err = dbR.Query(`WITH d AS (`+q+`),
+		t AS (SELECT sitePK, typePK, string_agg(tag, ',' ORDER BY tag) AS tags
+			FROM data.latency_tag JOIN mtr.tag USING (tagPK) GROUP BY sitePK, typePK)
+		SELECT ST_AsGeoJSON(geom), siteID, typeID, time, mean, fifty, ninety, lower, upper, COALESCE(tags, '')
+		FROM d JOIN data.latency_summary USING (sitePK, typePK)
+		LEFT OUTER JOIN t USING (sitePK, typePK)
+		WHERE ($`+n+` = '' OR typeID = $`+n+`)
+		AND sitePK IN (SELECT sitePK FROM data.site WHERE `+state+`)
+		ORDER BY siteID, typeID`, args...); err != nil {
+		return weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	g := newGeoJSONFeatureCollection()
+
+	for rows.Next() {
+		var p dataLatencySummaryProperties
+		var geom, tags string
+		var t time.Time
+		var mean, fifty, ninety, lower, upper int32
+
+		if err = rows.Scan(&geom, &p.SiteID, &p.TypeID, &t, &mean, &fifty, &ninety, &lower, &upper, &tags); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		dt := dataTypes[p.TypeID]
+
+		p.Time = t.UTC().Format(time.RFC3339)
+		p.Mean = float64(mean) * dt.Scale
+		p.Fifty = float64(fifty) * dt.Scale
+		p.Ninety = float64(ninety) * dt.Scale
+		p.Lower = float64(lower) * dt.Scale
+		p.Upper = float64(upper) * dt.Scale
+		p.Unit = dt.Unit
+		p.Status, p.RootCause = tp.dataStatus(p.SiteID, dataStatus(t, mean, fifty, ninety, lower, upper))
+		p.Tags = splitTags(tags)
+
+		g.add(geom, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	by, err := json.Marshal(g)
+	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
 	b.Write(by)
 
-	h.Set("Content-Type", "application/x-protobuf")
+	h.Set("Content-Type", "application/vnd.geo+json")
 
 	return &weft.StatusOK
 }
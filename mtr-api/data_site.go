@@ -1,14 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
 	"github.com/lib/pq"
 	"net/http"
 	"strconv"
-	"github.com/GeoNet/mtr/mtrpb"
-	"github.com/golang/protobuf/proto"
-	"bytes"
 )
 
 type dataSite struct {
@@ -47,6 +48,13 @@ func (d *dataSite) save(r *http.Request) *weft.Result {
 		}
 	}
 
+	// record the location if it has changed.
+	h := newSiteHistory()
+
+	if err := h.initLocation(d.siteID); err != nil {
+		return weft.InternalServerError(err)
+	}
+
 	return &weft.StatusOK
 }
 
@@ -76,32 +84,123 @@ func (d *dataSite) loadPK(r *http.Request) *weft.Result {
 	return &weft.StatusOK
 }
 
-func (f *dataSite) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
-		return res
+// sites returns the data sites selected by the spatial query params in r along
+// with the latest latency summary for each type for the sites.  The optional siteID
+// query param selects a single site.  Only active sites are included unless the state
+// query param is used.
+func (d *dataSite) sites(r *http.Request) ([]*mtrpb.DataSite, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, append([]string{"siteID", "state"}, spatialParams...)); !res.Ok {
+		return nil, res
+	}
+
+	var s spatialQuery
+	var sf stateFilter
+
+	if res := s.read(r); !res.Ok {
+		return nil, res
+	}
+
+	if res := sf.read(r); !res.Ok {
+		return nil, res
+	}
+
+	// The site filters are applied in the spatial query so nearest selects from matching sites.
+	args := []interface{}{r.URL.Query().Get("siteID")}
+	where := `($1 = '' OR siteID = $1) AND ` + sf.sql("state", &args)
+
+	q, err := s.selectSQL("data.site", "sitePK", where, &args)
+	if err != nil {
+		return nil, weft.BadRequest(err.Error())
+	}
+
+	tp, err := loadTopology()
+	if err != nil {
+		return nil, weft.InternalServerError(err)
 	}
 
-	var err error
 	var rows *sql.Rows
 
-	if rows, err = dbR.Query(`SELECT siteID, latitude, longitude FROM data.site`); err != nil {
-		return weft.InternalServerError(err)
+	if rows, err = dbR.Query(`WITH d AS (`+q+`)
+		SELECT siteID, latitude, longitude, distance, state, s.typeID, s.time, s.mean, s.fifty, s.ninety, s.lower, s.upper, b.median, b.mad, b.meanad
+		FROM d JOIN data.site USING (sitePK)
+		LEFT OUTER JOIN data.latency_summary s USING (sitePK)
+		`+dataBaselineSQL+`
+		ORDER BY distance ASC, siteID ASC, s.typeID ASC`, args...); err != nil {
+		return nil, weft.InternalServerError(err)
 	}
+	defer rows.Close()
 
-	var ts mtrpb.DataSiteResult
+	var sites []*mtrpb.DataSite
+	var ds *mtrpb.DataSite
 
 	for rows.Next() {
-		var t mtrpb.DataSite
+		var siteID, state string
+		var latitude, longitude, distance float64
+		var typeID sql.NullString
+		var t pq.NullTime
+		var mean, fifty, ninety, lower, upper sql.NullInt64
+		var median, mad, meanAD sql.NullFloat64
 
-		if err = rows.Scan(&t.SiteID, &t.Latitude, &t.Longitude); err != nil {
-			return weft.InternalServerError(err)
+		if err = rows.Scan(&siteID, &latitude, &longitude, &distance, &state, &typeID, &t, &mean, &fifty, &ninety, &lower, &upper,
+			&median, &mad, &meanAD); err != nil {
+			return nil, weft.InternalServerError(err)
 		}
 
-		ts.Result = append(ts.Result, &t)
+		if ds == nil || ds.SiteID != siteID {
+			ds = &mtrpb.DataSite{
+				SiteID:    siteID,
+				Latitude:  latitude,
+				Longitude: longitude,
+				Distance:  distance,
+				Status:    "unknown",
+				State:     state,
+			}
+			sites = append(sites, ds)
+		}
+
+		if !typeID.Valid {
+			continue
+		}
+
+		l := mtrpb.DataLatencySummary{
+			SiteID:  siteID,
+			TypeID:  typeID.String,
+			Seconds: t.Time.Unix(),
+			Mean:    int32(mean.Int64),
+			Fifty:   int32(fifty.Int64),
+			Ninety:  int32(ninety.Int64),
+			Lower:   int32(lower.Int64),
+			Upper:   int32(upper.Int64),
+		}
+
+		if len(ds.Latency) == 0 {
+			ds.Status = "good"
+		}
+
+		l.Status, l.RootCause = tp.dataStatus(siteID, dataStatus(t.Time, l.Mean, l.Fifty, l.Ninety, l.Lower, l.Upper))
+		l.Score, l.Anomalous = anomaly(float64(l.Mean), median, mad, meanAD)
+
+		ds.Status = worstStatus(ds.Status, l.Status)
+		ds.Latency = append(ds.Latency, &l)
 	}
 
-	var by []byte
-	if by, err = proto.Marshal(&ts); err != nil {
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return sites, &weft.StatusOK
+}
+
+func (d *dataSite) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var dsr mtrpb.DataSiteResult
+	var res *weft.Result
+
+	if dsr.Result, res = d.sites(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&dsr)
+	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
@@ -111,3 +210,75 @@ func (f *dataSite) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.
 
 	return &weft.StatusOK
 }
+
+type dataSiteJSON struct {
+	SiteID    string
+	Latitude  float64
+	Longitude float64
+	Distance  float64
+	Status    string
+	State     string
+	Latency   []dataLatencySummaryJSON
+}
+
+type dataLatencySummaryJSON struct {
+	TypeID    string
+	Seconds   int64
+	Mean      int32
+	Fifty     int32
+	Ninety    int32
+	Lower     int32
+	Upper     int32
+	Status    string
+	RootCause []string `json:",omitempty"`
+}
+
+func (d *dataSite) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	sites, res := d.sites(r)
+	if !res.Ok {
+		return res
+	}
+
+	by, err := json.Marshal(dataSiteJSONs(sites))
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+func dataSiteJSONs(sites []*mtrpb.DataSite) []dataSiteJSON {
+	j := make([]dataSiteJSON, len(sites))
+
+	for i, s := range sites {
+		j[i] = dataSiteJSON{
+			SiteID:    s.SiteID,
+			Latitude:  s.Latitude,
+			Longitude: s.Longitude,
+			Distance:  s.Distance,
+			Status:    s.Status,
+			State:     s.State,
+			Latency:   make([]dataLatencySummaryJSON, len(s.Latency)),
+		}
+
+		for k, l := range s.Latency {
+			j[i].Latency[k] = dataLatencySummaryJSON{
+				TypeID:    l.TypeID,
+				Seconds:   l.Seconds,
+				Mean:      l.Mean,
+				Fifty:     l.Fifty,
+				Ninety:    l.Ninety,
+				Lower:     l.Lower,
+				Upper:     l.Upper,
+				Status:    l.Status,
+				RootCause: l.RootCause,
+			}
+		}
+	}
+
+	return j
+}
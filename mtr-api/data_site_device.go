@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"strings"
+)
+
+// siteDevice links a field device to a data site with a role e.g., datalogger, comms, power.
+// A device can be linked to many sites and a site to many devices.
+type siteDevice struct {
+	site     dataSite
+	devicePK int
+}
+
+func (s *siteDevice) loadPK(r *http.Request) *weft.Result {
+	if res := s.site.loadPK(r); !res.Ok {
+		return res
+	}
+
+	var res *weft.Result
+
+	s.devicePK, res = fieldDevicePK(r.URL.Query().Get("deviceID"))
+
+	return res
+}
+
+func (s *siteDevice) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"siteID", "deviceID", "role"}, []string{}); !res.Ok {
+		return res
+	}
+
+	role := r.URL.Query().Get("role")
+
+	if strings.TrimSpace(role) == "" {
+		return weft.BadRequest("empty role")
+	}
+
+	if res := s.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`INSERT INTO data.site_device(sitePK, devicePK, role) VALUES($1, $2, $3)`,
+		s.site.sitePK, s.devicePK, role); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			// ignore unique constraint errors
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+// delete removes the link for role or, if role is not set, all links between the site and device.
+func (s *siteDevice) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"siteID", "deviceID"}, []string{"role"}); !res.Ok {
+		return res
+	}
+
+	if res := s.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM data.site_device WHERE sitePK = $1 AND devicePK = $2 AND ($3 = '' OR role = $3)`,
+		s.site.sitePK, s.devicePK, r.URL.Query().Get("role")); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// links returns the site device links.  The optional siteID and deviceID query params
+// select links for a site or device.
+func (s *siteDevice) links(r *http.Request) ([]*mtrpb.SiteDevice, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{"siteID", "deviceID"}); !res.Ok {
+		return nil, res
+	}
+
+	l, err := loadSiteDevices(r.URL.Query().Get("siteID"), r.URL.Query().Get("deviceID"))
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+// loadSiteDevices returns the site device links for siteID and deviceID.  Empty matches all.
+func loadSiteDevices(siteID, deviceID string) ([]*mtrpb.SiteDevice, error) {
+	var rows *sql.Rows
+	var err error
+
+	if rows, err = dbR.Query(`SELECT siteID, deviceID, role FROM data.site_device
+		JOIN data.site USING (sitePK) JOIN field.device USING (devicePK)
+		WHERE ($1 = '' OR siteID = $1) AND ($2 = '' OR deviceID = $2)
+		ORDER BY siteID ASC, deviceID ASC, role ASC`, siteID, deviceID); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.SiteDevice
+
+	for rows.Next() {
+		var v mtrpb.SiteDevice
+
+		if err = rows.Scan(&v.SiteID, &v.DeviceID, &v.Role); err != nil {
+			return nil, err
+		}
+
+		l = append(l, &v)
+	}
+
+	return l, rows.Err()
+}
+
+func (s *siteDevice) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var sr mtrpb.SiteDeviceResult
+	var res *weft.Result
+
+	if sr.Result, res = s.links(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&sr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type siteDeviceJSON struct {
+	SiteID   string
+	DeviceID string
+	Role     string
+}
+
+func (s *siteDevice) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := s.links(r)
+	if !res.Ok {
+		return res
+	}
+
+	by, err := json.Marshal(siteDeviceJSONs(l))
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+func siteDeviceJSONs(l []*mtrpb.SiteDevice) []siteDeviceJSON {
+	j := make([]siteDeviceJSON, len(l))
+
+	for i, v := range l {
+		j[i] = siteDeviceJSON{SiteID: v.SiteID, DeviceID: v.DeviceID, Role: v.Role}
+	}
+
+	return j
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"html"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fieldDependency is a dependency between field devices.  The device depends on the parent
+// e.g., a datalogger depends on the radio repeater it communicates through.  Data sites depend
+// on the devices linked to them with data.site_device.
+type fieldDependency struct {
+	devicePK, parentPK int
+}
+
+func (f *fieldDependency) loadPK(r *http.Request) *weft.Result {
+	var res *weft.Result
+
+	if f.devicePK, res = fieldDevicePK(r.URL.Query().Get("deviceID")); !res.Ok {
+		return res
+	}
+
+	f.parentPK, res = fieldDevicePK(r.URL.Query().Get("parentID"))
+
+	return res
+}
+
+// save adds a dependency.  A dependency that would make a cycle is an error.
+func (f *fieldDependency) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"deviceID", "parentID"}, []string{}); !res.Ok {
+		return res
+	}
+
+	if r.URL.Query().Get("deviceID") == r.URL.Query().Get("parentID") {
+		return weft.BadRequest("a device can't depend on itself")
+	}
+
+	if res := f.loadPK(r); !res.Ok {
+		return res
+	}
+
+	// the device must not already be upstream of the parent.
+	var cycle bool
+
+	if err := db.QueryRow(`WITH RECURSIVE up(devicePK) AS (
+		SELECT parentPK FROM field.device_dependency WHERE devicePK = $1
+		UNION
+		SELECT d.parentPK FROM field.device_dependency d JOIN up ON d.devicePK = up.devicePK)
+		SELECT EXISTS (SELECT 1 FROM up WHERE devicePK = $2)`, f.parentPK, f.devicePK).Scan(&cycle); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if cycle {
+		return weft.BadRequest("dependency would make a cycle")
+	}
+
+	if _, err := db.Exec(`INSERT INTO field.device_dependency(devicePK, parentPK) VALUES($1, $2)`,
+		f.devicePK, f.parentPK); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			// ignore unique constraint errors
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldDependency) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"deviceID", "parentID"}, []string{}); !res.Ok {
+		return res
+	}
+
+	if res := f.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM field.device_dependency WHERE devicePK = $1 AND parentPK = $2`,
+		f.devicePK, f.parentPK); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldDependency) all(r *http.Request) ([]*mtrpb.FieldDependency, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	d, err := loadDependencies()
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return d, &weft.StatusOK
+}
+
+func loadDependencies() ([]*mtrpb.FieldDependency, error) {
+	var rows *sql.Rows
+	var err error
+
+	if rows, err = dbR.Query(`SELECT d.deviceID, p.deviceID FROM field.device_dependency
+		JOIN field.device d USING (devicePK) JOIN field.device p ON (parentPK = p.devicePK)
+		ORDER BY d.deviceID ASC, p.deviceID ASC`); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var d []*mtrpb.FieldDependency
+
+	for rows.Next() {
+		var v mtrpb.FieldDependency
+
+		if err = rows.Scan(&v.DeviceID, &v.ParentID); err != nil {
+			return nil, err
+		}
+
+		d = append(d, &v)
+	}
+
+	return d, rows.Err()
+}
+
+func (f *fieldDependency) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var fdr mtrpb.FieldDependencyResult
+	var res *weft.Result
+
+	if fdr.Result, res = f.all(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&fdr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type fieldDependencyJSON struct {
+	DeviceID string
+	ParentID string
+}
+
+func (f *fieldDependency) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	d, res := f.all(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]fieldDependencyJSON, len(d))
+
+	for i, v := range d {
+		j[i] = fieldDependencyJSON{DeviceID: v.DeviceID, ParentID: v.ParentID}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+// reachabilityTypes are the field metric types that show if a device can be reached.  Only these
+// are used to mark devices downstream as impacted; e.g., a full disk on a repeater doesn't stop the
+// devices behind it sending data.  Set with MTR_REACHABILITY_TYPES e.g., conn,ping
+var reachabilityTypes = map[string]bool{"conn": true, "ping": true}
+
+func init() {
+	if s := os.Getenv("MTR_REACHABILITY_TYPES"); s != "" {
+		reachabilityTypes = make(map[string]bool)
+
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				reachabilityTypes[v] = true
+			}
+		}
+	}
+}
+
+// topology is the dependency graph with the status of each device and site.  It is used to mark
+// metrics downstream of an unreachable device as impacted instead of bad or late.
+// Only active devices and sites are included.
+type topology struct {
+	parents map[string][]string // deviceID to the parent deviceIDs.
+	sites   map[string][]string // siteID to the linked deviceIDs.
+	device  map[string]string   // deviceID to the worst status of its metrics.
+	reach   map[string]string   // deviceID to the worst status of its reachabilityTypes metrics.
+	site    map[string]string   // siteID to the worst status of its latencies.
+}
+
+// topologyCache is the topology, refreshed with the summary views.
+var topologyCache struct {
+	sync.Mutex
+	t *topology
+}
+
+// loadTopology returns the cached topology.  It is read from the DB if it has not been loaded yet.
+func loadTopology() (topology, error) {
+	topologyCache.Lock()
+	defer topologyCache.Unlock()
+
+	if topologyCache.t == nil {
+		t, err := readTopology()
+		if err != nil {
+			return t, err
+		}
+
+		topologyCache.t = &t
+	}
+
+	return *topologyCache.t, nil
+}
+
+// refreshTopology reads the topology from the DB and caches it.
+func refreshTopology() error {
+	t, err := readTopology()
+	if err != nil {
+		return err
+	}
+
+	topologyCache.Lock()
+	topologyCache.t = &t
+	topologyCache.Unlock()
+
+	return nil
+}
+
+func readTopology() (topology, error) {
+	t := topology{
+		parents: make(map[string][]string),
+		sites:   make(map[string][]string),
+		device:  make(map[string]string),
+		reach:   make(map[string]string),
+		site:    make(map[string]string),
+	}
+
+	rows, err := dbR.Query(`SELECT d.deviceID, p.deviceID FROM field.device_dependency
+		JOIN field.device d USING (devicePK) JOIN field.device p ON (parentPK = p.devicePK)
+		WHERE d.state = 'active' AND p.state = 'active'
+		ORDER BY d.deviceID ASC, p.deviceID ASC`)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deviceID, parentID string
+
+		if err = rows.Scan(&deviceID, &parentID); err != nil {
+			return t, err
+		}
+
+		t.parents[deviceID] = append(t.parents[deviceID], parentID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return t, err
+	}
+
+	rows.Close()
+
+	rows, err = dbR.Query(`SELECT DISTINCT siteID, deviceID FROM data.site_device
+		JOIN data.site s USING (sitePK) JOIN field.device d USING (devicePK)
+		WHERE s.state = 'active' AND d.state = 'active'
+		ORDER BY siteID ASC, deviceID ASC`)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var siteID, deviceID string
+
+		if err = rows.Scan(&siteID, &deviceID); err != nil {
+			return t, err
+		}
+
+		t.sites[siteID] = append(t.sites[siteID], deviceID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return t, err
+	}
+
+	rows.Close()
+
+	rows, err = dbR.Query(`SELECT deviceID, typeID, time, value, lower, upper FROM field.metric_summary
+		WHERE devicePK IN (SELECT devicePK FROM field.device WHERE state = 'active')`)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deviceID, typeID string
+		var tm time.Time
+		var value, lower, upper int32
+
+		if err = rows.Scan(&deviceID, &typeID, &tm, &value, &lower, &upper); err != nil {
+			return t, err
+		}
+
+		s := fieldStatus(tm, value, lower, upper)
+
+		t.device[deviceID] = worstStatus(t.device[deviceID], s)
+
+		if reachabilityTypes[typeID] {
+			t.reach[deviceID] = worstStatus(t.reach[deviceID], s)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return t, err
+	}
+
+	rows.Close()
+
+	rows, err = dbR.Query(`SELECT siteID, time, mean, fifty, ninety, lower, upper FROM data.latency_summary
+		WHERE sitePK IN (SELECT sitePK FROM data.site WHERE state = 'active')`)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var siteID string
+		var tm time.Time
+		var mean, fifty, ninety, lower, upper int32
+
+		if err = rows.Scan(&siteID, &tm, &mean, &fifty, &ninety, &lower, &upper); err != nil {
+			return t, err
+		}
+
+		t.site[siteID] = worstStatus(t.site[siteID], dataStatus(tm, mean, fifty, ninety, lower, upper))
+	}
+
+	return t, rows.Err()
+}
+
+// failing returns true if status is bad or late.
+func failing(status string) bool {
+	return status == "bad" || status == "late"
+}
+
+// upstream returns deviceIDs and all the devices they depend on.
+func (t *topology) upstream(deviceIDs []string) []string {
+	var u []string
+	seen := make(map[string]bool)
+	q := append([]string{}, deviceIDs...)
+
+	for len(q) > 0 {
+		d := q[0]
+		q = q[1:]
+
+		if seen[d] {
+			continue
+		}
+
+		seen[d] = true
+		u = append(u, d)
+		q = append(q, t.parents[d]...)
+	}
+
+	return u
+}
+
+// rootCause returns the unreachable (failing reachability metrics) devices in deviceIDs, or upstream of them,
+// that do not themselves depend on an unreachable device.
+func (t *topology) rootCause(deviceIDs []string) []string {
+	var c []string
+
+	for _, d := range t.upstream(deviceIDs) {
+		if !failing(t.reach[d]) {
+			continue
+		}
+
+		root := true
+
+		for _, p := range t.upstream(t.parents[d]) {
+			if failing(t.reach[p]) {
+				root = false
+				break
+			}
+		}
+
+		if root {
+			c = append(c, d)
+		}
+	}
+
+	sort.Strings(c)
+
+	return c
+}
+
+// fieldStatus returns the status for a metric with status on deviceID.  A bad or late
+// metric is impacted if a device that deviceID depends on is unreachable.  The root
+// cause is returned for impacted metrics.
+func (t *topology) fieldStatus(deviceID, status string) (string, []string) {
+	if !failing(status) {
+		return status, nil
+	}
+
+	if c := t.rootCause(t.parents[deviceID]); len(c) > 0 {
+		return "impacted", c
+	}
+
+	return status, nil
+}
+
+// dataStatus returns the status for a latency with status at siteID.  A bad or late
+// latency is impacted if a device linked to the site is unreachable.
+func (t *topology) dataStatus(siteID, status string) (string, []string) {
+	if !failing(status) {
+		return status, nil
+	}
+
+	if c := t.rootCause(t.sites[siteID]); len(c) > 0 {
+		return "impacted", c
+	}
+
+	return status, nil
+}
+
+// statusColour returns the map marker colour for status.
+func statusColour(status string) string {
+	for _, s := range statusStyles {
+		if s.status == status {
+			return s.colour
+		}
+	}
+
+	return "lightslategrey"
+}
+
+// topologyNode is a device or site in the topology SVG.
+type topologyNode struct {
+	id, status string
+	parents    []string
+	depth, row int
+}
+
+// svg writes the dependency graph as an SVG tree.  Devices without a parent are on the left
+// with the devices that depend on them to the right.  Sites are drawn after the devices they
+// are linked to.  Nodes are coloured by status.
+func (f *fieldDependency) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	t, err := loadTopology()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	nodes := make(map[string]*topologyNode)
+
+	add := func(id, status string, parents []string) {
+		if status == "" {
+			status = "unknown"
+		}
+
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = &topologyNode{id: id, status: status, parents: parents, depth: -1}
+		}
+	}
+
+	for d, p := range t.parents {
+		s, _ := t.fieldStatus(d, t.device[d])
+		add(d, s, p)
+
+		for _, v := range p {
+			s, _ = t.fieldStatus(v, t.device[v])
+			add(v, s, t.parents[v])
+		}
+	}
+
+	for _, d := range t.sites {
+		for _, v := range d {
+			s, _ := t.fieldStatus(v, t.device[v])
+			add(v, s, t.parents[v])
+		}
+	}
+
+	// sites are prefixed so they can't clash with a deviceID.
+	for site, d := range t.sites {
+		s, _ := t.dataStatus(site, t.site[site])
+		add("site:"+site, s, d)
+	}
+
+	var depth func(n *topologyNode) int
+
+	depth = func(n *topologyNode) int {
+		if n.depth >= 0 {
+			return n.depth
+		}
+
+		n.depth = 0
+
+		for _, p := range n.parents {
+			if d := depth(nodes[p]) + 1; d > n.depth {
+				n.depth = d
+			}
+		}
+
+		return n.depth
+	}
+
+	var ids []string
+
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	rows := make(map[int]int)
+	var maxDepth, maxRow int
+
+	for _, id := range ids {
+		n := nodes[id]
+		d := depth(n)
+
+		n.row = rows[d]
+		rows[d]++
+
+		if d > maxDepth {
+			maxDepth = d
+		}
+
+		if n.row > maxRow {
+			maxRow = n.row
+		}
+	}
+
+	const colWidth, rowHeight, margin = 200, 24, 20
+
+	x := func(n *topologyNode) int { return margin + n.depth*colWidth }
+	y := func(n *topologyNode) int { return margin + n.row*rowHeight }
+
+	width := 2*margin + (maxDepth+1)*colWidth
+	height := 2*margin + (maxRow+1)*rowHeight
+
+	b.WriteString(`<?xml version="1.0"?>`)
+	b.WriteString(fmt.Sprintf("<svg width=\"%d\" height=\"%d\" xmlns=\"http://www.w3.org/2000/svg\">", width, height))
+	b.WriteString(fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" style=\"fill: white\"/>", width, height))
+
+	b.WriteString("<g style=\"stroke: lightslategrey; stroke-width: 1; fill: none\">")
+	for _, id := range ids {
+		n := nodes[id]
+		for _, p := range n.parents {
+			pn := nodes[p]
+			b.WriteString(fmt.Sprintf("<path d=\"M%d %d C%d %d %d %d %d %d\"/>",
+				x(pn), y(pn), x(pn)+colWidth/2, y(pn), x(n)-colWidth/2, y(n), x(n), y(n)))
+		}
+	}
+	b.WriteString("</g>")
+
+	for _, id := range ids {
+		n := nodes[id]
+		c := statusColour(n.status)
+
+		b.WriteString(fmt.Sprintf("<g><title>%s %s</title>", html.EscapeString(n.id), n.status))
+		b.WriteString(fmt.Sprintf("<circle cx=\"%d\" cy=\"%d\" r=\"6\" style=\"stroke: %s; fill: %s\"/>", x(n), y(n), c, c))
+		b.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\" font-family=\"Arial\" font-size=\"12\">%s</text></g>",
+			x(n)+10, y(n)+4, html.EscapeString(n.id)))
+	}
+
+	b.WriteString("</svg>")
+
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// fieldAttribute is a key value attribute for a field device or model.
+// Keys in known are validated, any other key is free-form.
+type fieldAttribute struct {
+	table   string // the attribute table e.g., field.device_attribute
+	pkCol   string // the pk column in table e.g., devicePK
+	idParam string // the query parameter for the device or model e.g., deviceID
+	known   map[string]func(string) error
+	pk      int
+}
+
+// deviceAttributes are the well known device attributes.
+var deviceAttributes = map[string]func(string) error{
+	"installed": validDate, // install date e.g., 2015-05-14
+	"serial":    nil,
+	"firmware":  nil,
+	"owner":     nil,
+	"comms":     nil, // comms method e.g., radio, cellular, satellite
+}
+
+// modelAttributes are the well known model attributes.
+var modelAttributes = map[string]func(string) error{
+	"manufacturer":    nil,
+	"expectedMetrics": validFieldTypes, // comma separated typeIDs e.g., voltage,clock
+}
+
+func newDeviceAttribute() fieldAttribute {
+	return fieldAttribute{table: "field.device_attribute", pkCol: "devicePK", idParam: "deviceID", known: deviceAttributes}
+}
+
+func newModelAttribute() fieldAttribute {
+	return fieldAttribute{table: "field.model_attribute", pkCol: "modelPK", idParam: "modelID", known: modelAttributes}
+}
+
+func validDate(s string) error {
+	if _, err := time.Parse("2006-01-02", s); err != nil {
+		return fmt.Errorf("invalid date %s, expected e.g., 2015-05-14", s)
+	}
+	return nil
+}
+
+func validFieldTypes(s string) error {
+	for _, t := range strings.Split(s, ",") {
+		if _, ok := fieldTypes[t]; !ok {
+			return fmt.Errorf("invalid type %s", t)
+		}
+	}
+	return nil
+}
+
+func (f *fieldAttribute) loadPK(r *http.Request) *weft.Result {
+	var res *weft.Result
+
+	switch f.idParam {
+	case "deviceID":
+		f.pk, res = fieldDevicePK(r.URL.Query().Get("deviceID"))
+	default:
+		f.pk, res = fieldModelPK(r.URL.Query().Get("modelID"))
+	}
+
+	return res
+}
+
+func (f *fieldAttribute) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{f.idParam, "key", "value"}, []string{}); !res.Ok {
+		return res
+	}
+
+	key := r.URL.Query().Get("key")
+	value := r.URL.Query().Get("value")
+
+	if key == "" {
+		return weft.BadRequest("empty key")
+	}
+
+	if v, ok := f.known[key]; ok && v != nil {
+		if err := v(value); err != nil {
+			return weft.BadRequest(err.Error())
+		}
+	}
+
+	if res := f.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`INSERT INTO `+f.table+`(`+f.pkCol+`, key, value) VALUES($1, $2, $3)`,
+		f.pk, key, value); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			if _, err := db.Exec(`UPDATE `+f.table+` SET value=$3 WHERE `+f.pkCol+`=$1 AND key=$2`,
+				f.pk, key, value); err != nil {
+				return weft.InternalServerError(err)
+			}
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldAttribute) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{f.idParam, "key"}, []string{}); !res.Ok {
+		return res
+	}
+
+	if res := f.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM `+f.table+` WHERE `+f.pkCol+`=$1 AND key=$2`,
+		f.pk, r.URL.Query().Get("key")); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldAttribute) attributes(r *http.Request) ([]*mtrpb.FieldAttribute, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{f.idParam}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	if res := f.loadPK(r); !res.Ok {
+		return nil, res
+	}
+
+	rows, err := dbR.Query(`SELECT key, value FROM `+f.table+` WHERE `+f.pkCol+`=$1 ORDER BY key ASC`, f.pk)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var a []*mtrpb.FieldAttribute
+
+	for rows.Next() {
+		var v mtrpb.FieldAttribute
+
+		if err = rows.Scan(&v.Key, &v.Value); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		a = append(a, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return a, &weft.StatusOK
+}
+
+func (f *fieldAttribute) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var ar mtrpb.FieldAttributeResult
+	var res *weft.Result
+
+	if ar.Result, res = f.attributes(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&ar)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+// jsonV1 writes the attributes as a JSON object of key:value
+func (f *fieldAttribute) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	a, res := f.attributes(r)
+	if !res.Ok {
+		return res
+	}
+
+	by, err := json.Marshal(attributeMap(a))
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+// attributeMap returns a as a map of key:value for JSON.
+func attributeMap(a []*mtrpb.FieldAttribute) map[string]string {
+	m := make(map[string]string)
+
+	for _, v := range a {
+		m[v.Key] = v.Value
+	}
+
+	return m
+}
+
+// loadAttributes returns the attributes from table (field.device_attribute or field.model_attribute)
+// keyed by the ID (deviceID or modelID).
+func loadAttributes(query string, args ...interface{}) (map[string][]*mtrpb.FieldAttribute, error) {
+	var rows *sql.Rows
+	var err error
+
+	if rows, err = dbR.Query(query, args...); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string][]*mtrpb.FieldAttribute)
+
+	for rows.Next() {
+		var id string
+		var v mtrpb.FieldAttribute
+
+		if err = rows.Scan(&id, &v.Key, &v.Value); err != nil {
+			return nil, err
+		}
+
+		m[id] = append(m[id], &v)
+	}
+
+	return m, rows.Err()
+}
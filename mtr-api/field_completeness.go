@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// gapFactor is how many expected intervals there must be between values for a gap.
+// It allows for values that arrive a little late.
+const gapFactor = 2
+
+// metricGap is a period with no values for a metric.
+type metricGap struct {
+	start, end time.Time
+	missing    int64
+}
+
+// metricGaps returns the gaps in times, which must be in order, between start and end.  There is a gap
+// when there are more than gapFactor intervals between values or the start or end of the window.
+func metricGaps(times []time.Time, start, end time.Time, interval time.Duration) []metricGap {
+	var gaps []metricGap
+
+	prev := start
+
+	for _, t := range append(times, end) {
+		if d := t.Sub(prev); d > interval*gapFactor {
+			gaps = append(gaps, metricGap{start: prev, end: t, missing: int64(d/interval) - 1})
+		}
+		prev = t
+	}
+
+	return gaps
+}
+
+// completeness returns received as a percentage of the values expected between start and end, at most 100.
+func completeness(received int, start, end time.Time, interval time.Duration) (expected int64, pc float64) {
+	expected = int64(end.Sub(start) / interval)
+
+	if expected == 0 {
+		return 0, 100
+	}
+
+	pc = math.Min(100, float64(received)/float64(expected)*100)
+
+	return expected, math.Floor(pc*100+0.5) / 100
+}
+
+type fieldCompleteness struct{}
+
+// read returns the completeness for field metrics over the last hours (default 24) in the query.
+// The optional deviceID and typeID select metrics.
+func (f *fieldCompleteness) read(r *http.Request) ([]*mtrpb.FieldMetricCompleteness, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{"deviceID", "typeID", "hours"}); !res.Ok {
+		return nil, res
+	}
+
+	v := r.URL.Query()
+
+	hours := 24
+
+	if v.Get("hours") != "" {
+		var err error
+		if hours, err = strconv.Atoi(v.Get("hours")); err != nil || hours <= 0 || time.Hour*time.Duration(hours) > availabilityRetention {
+			return nil, weft.BadRequest("invalid hours")
+		}
+	}
+
+	if v.Get("typeID") != "" {
+		if _, res := loadFieldType(v.Get("typeID")); !res.Ok {
+			return nil, res
+		}
+	}
+
+	end := time.Now().UTC()
+	start := end.Add(time.Hour * time.Duration(-hours))
+
+	// metrics with no values in the window come from the summary with a NULL time.
+	rows, err := dbR.Query(`WITH m AS (
+		SELECT devicePK, typePK, time FROM field.metric WHERE time >= $1
+		UNION ALL
+		SELECT devicePK, typePK, NULL FROM field.metric_summary WHERE time < $1
+		)
+		SELECT deviceID, typeID, COALESCE(mi.seconds, ti.seconds, $4), time
+		FROM m JOIN field.device USING (devicePK) JOIN field.type USING (typePK)
+		LEFT OUTER JOIN field.metric_interval mi ON (mi.devicePK = m.devicePK AND mi.typePK = m.typePK)
+		LEFT OUTER JOIN field.type_interval ti ON (ti.typePK = m.typePK)
+		WHERE ($2 = '' OR deviceID = $2) AND ($3 = '' OR typeID = $3)
+		ORDER BY deviceID, typeID, time`, start, v.Get("deviceID"), v.Get("typeID"), int(defaultInterval.Seconds()))
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.FieldMetricCompleteness
+	var c *mtrpb.FieldMetricCompleteness
+	var times []time.Time
+
+	done := func() {
+		if c == nil {
+			return
+		}
+
+		interval := time.Duration(c.Interval) * time.Second
+
+		c.Received = int64(len(times))
+		c.Expected, c.Completeness = completeness(len(times), start, end, interval)
+
+		for _, g := range metricGaps(times, start, end, interval) {
+			c.Gaps = append(c.Gaps, &mtrpb.FieldGap{Start: g.start.Unix(), End: g.end.Unix(), Missing: g.missing})
+		}
+
+		l = append(l, c)
+	}
+
+	for rows.Next() {
+		var deviceID, typeID string
+		var interval int32
+		var t *time.Time
+
+		if err = rows.Scan(&deviceID, &typeID, &interval, &t); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		if c == nil || c.DeviceID != deviceID || c.TypeID != typeID {
+			done()
+			c = &mtrpb.FieldMetricCompleteness{DeviceID: deviceID, TypeID: typeID, Interval: interval}
+			times = nil
+		}
+
+		if t != nil {
+			times = append(times, *t)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	done()
+
+	return l, &weft.StatusOK
+}
+
+func (f *fieldCompleteness) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var cr mtrpb.FieldMetricCompletenessResult
+	var res *weft.Result
+
+	if cr.Result, res = f.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&cr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type fieldGapJSON struct {
+	Start   string
+	End     string
+	Missing int64
+}
+
+type fieldCompletenessJSON struct {
+	DeviceID     string
+	TypeID       string
+	Interval     int32
+	Expected     int64
+	Received     int64
+	Completeness float64
+	Gaps         []fieldGapJSON
+}
+
+func (f *fieldCompleteness) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := f.read(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]fieldCompletenessJSON, len(l))
+
+	for i, v := range l {
+		j[i] = fieldCompletenessJSON{
+			DeviceID:     v.DeviceID,
+			TypeID:       v.TypeID,
+			Interval:     v.Interval,
+			Expected:     v.Expected,
+			Received:     v.Received,
+			Completeness: v.Completeness,
+			Gaps:         []fieldGapJSON{},
+		}
+
+		for _, g := range v.Gaps {
+			j[i].Gaps = append(j[i].Gaps, fieldGapJSON{
+				Start:   time.Unix(g.Start, 0).UTC().Format(time.RFC3339),
+				End:     time.Unix(g.End, 0).UTC().Format(time.RFC3339),
+				Missing: g.Missing,
+			})
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
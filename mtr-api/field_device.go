@@ -3,7 +3,10 @@ package main
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
 	"github.com/lib/pq"
 	"net/http"
 	"strconv"
@@ -76,6 +79,13 @@ func (f *fieldDevice) save(r *http.Request) *weft.Result {
 		}
 	}
 
+	// record the initial location.  Use /field/device/location to move a device.
+	h := newDeviceHistory()
+
+	if err := h.initLocation(f.deviceID); err != nil {
+		return weft.InternalServerError(err)
+	}
+
 	return &weft.StatusOK
 }
 
@@ -93,22 +103,215 @@ func (f *fieldDevice) delete(r *http.Request) *weft.Result {
 	return &weft.StatusOK
 }
 
-func (f *fieldDevice) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+// devices returns the field devices selected by the spatial query params in r along
+// with the latest summary for each metric and the attributes for the devices.
+// The optional deviceID query param selects a single device and siteID selects the devices
+// linked to a site.  Only active devices are included unless the state query param is used.
+func (f *fieldDevice) devices(r *http.Request) ([]*mtrpb.FieldDevice, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, append([]string{"deviceID", "siteID", "state"}, spatialParams...)); !res.Ok {
+		return nil, res
+	}
+
+	var s spatialQuery
+	var sf stateFilter
+
+	if res := s.read(r); !res.Ok {
+		return nil, res
+	}
+
+	if res := sf.read(r); !res.Ok {
+		return nil, res
+	}
+
+	// The device filters are applied in the spatial query so nearest selects from matching devices.
+	args := []interface{}{r.URL.Query().Get("deviceID"), r.URL.Query().Get("siteID")}
+	where := `($1 = '' OR deviceID = $1)
+		AND ($2 = '' OR devicePK IN (SELECT devicePK FROM data.site_device JOIN data.site USING (sitePK) WHERE siteID = $2))
+		AND ` + sf.sql("state", &args)
+
+	q, err := s.selectSQL("field.device", "devicePK", where, &args)
+	if err != nil {
+		return nil, weft.BadRequest(err.Error())
+	}
+
+	tp, err := loadTopology()
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	var rows *sql.Rows
+
+	if rows, err = dbR.Query(`WITH d AS (`+q+`)
+		SELECT deviceID, modelID, latitude, longitude, distance, state, s.typeID, s.time, s.value, s.lower, s.upper, b.median, b.mad, b.meanad
+		FROM d JOIN field.device USING (devicePK) JOIN field.model USING (modelPK)
+		LEFT OUTER JOIN field.metric_summary s USING (devicePK)
+		`+fieldBaselineSQL+`
+		ORDER BY distance ASC, deviceID ASC, s.typeID ASC`, args...); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var devices []*mtrpb.FieldDevice
+	var d *mtrpb.FieldDevice
+
+	for rows.Next() {
+		var deviceID, modelID, state string
+		var latitude, longitude, distance float64
+		var typeID sql.NullString
+		var t pq.NullTime
+		var value, lower, upper sql.NullInt64
+		var median, mad, meanAD sql.NullFloat64
+
+		if err = rows.Scan(&deviceID, &modelID, &latitude, &longitude, &distance, &state, &typeID, &t, &value, &lower, &upper,
+			&median, &mad, &meanAD); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		if d == nil || d.DeviceID != deviceID {
+			d = &mtrpb.FieldDevice{
+				DeviceID:  deviceID,
+				ModelID:   modelID,
+				Latitude:  latitude,
+				Longitude: longitude,
+				Distance:  distance,
+				Status:    "unknown",
+				State:     state,
+			}
+			devices = append(devices, d)
+		}
+
+		if !typeID.Valid {
+			continue
+		}
+
+		m := mtrpb.FieldMetricSummary{
+			DeviceID: deviceID,
+			ModelID:  modelID,
+			TypeID:   typeID.String,
+			Seconds:  t.Time.Unix(),
+			Value:    int32(value.Int64),
+			Lower:    int32(lower.Int64),
+			Upper:    int32(upper.Int64),
+		}
+
+		if len(d.Metrics) == 0 {
+			d.Status = "good"
+		}
+
+		m.Status, m.RootCause = tp.fieldStatus(deviceID, fieldStatus(t.Time, m.Value, m.Lower, m.Upper))
+		m.Score, m.Anomalous = anomaly(float64(m.Value), median, mad, meanAD)
+
+		d.Status = worstStatus(d.Status, m.Status)
+		d.Metrics = append(d.Metrics, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	a, err := loadAttributes(`SELECT deviceID, key, value FROM field.device_attribute
+		JOIN field.device USING (devicePK)
+		WHERE ($1 = '' OR deviceID = $1)
+		ORDER BY deviceID, key`, r.URL.Query().Get("deviceID"))
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	for _, d := range devices {
+		d.Attributes = a[d.DeviceID]
+	}
+
+	return devices, &weft.StatusOK
+}
+
+func (f *fieldDevice) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var fdr mtrpb.FieldDeviceResult
+	var res *weft.Result
+
+	if fdr.Result, res = f.devices(r); !res.Ok {
 		return res
 	}
 
-	var s string
+	by, err := proto.Marshal(&fdr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type fieldDeviceJSON struct {
+	DeviceID   string
+	ModelID    string
+	Latitude   float64
+	Longitude  float64
+	Distance   float64
+	Status     string
+	State      string
+	Metrics    []fieldMetricSummaryJSON
+	Attributes map[string]string
+}
+
+type fieldMetricSummaryJSON struct {
+	TypeID    string
+	Seconds   int64
+	Value     int32
+	Lower     int32
+	Upper     int32
+	Status    string
+	RootCause []string `json:",omitempty"`
+}
+
+func (f *fieldDevice) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	devices, res := f.devices(r)
+	if !res.Ok {
+		return res
+	}
 
-	if err := dbR.QueryRow(`SELECT COALESCE(array_to_json(array_agg(row_to_json(l))), '[]')
-		FROM (SELECT deviceid AS "DeviceID", modelid AS "ModelID", latitude AS "Latitude",
-			longitude AS "Longitude" FROM field.device JOIN field.model USING(modelpk)) l`).Scan(&s); err != nil {
+	by, err := json.Marshal(fieldDeviceJSONs(devices))
+	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
-	b.WriteString(s)
+	b.Write(by)
 
 	h.Set("Content-Type", "application/json;version=1")
 
 	return &weft.StatusOK
 }
+
+func fieldDeviceJSONs(devices []*mtrpb.FieldDevice) []fieldDeviceJSON {
+	j := make([]fieldDeviceJSON, len(devices))
+
+	for i, d := range devices {
+		j[i] = fieldDeviceJSON{
+			DeviceID:   d.DeviceID,
+			ModelID:    d.ModelID,
+			Latitude:   d.Latitude,
+			Longitude:  d.Longitude,
+			Distance:   d.Distance,
+			Status:     d.Status,
+			State:      d.State,
+			Metrics:    make([]fieldMetricSummaryJSON, len(d.Metrics)),
+			Attributes: attributeMap(d.Attributes),
+		}
+
+		for k, m := range d.Metrics {
+			j[i].Metrics[k] = fieldMetricSummaryJSON{
+				TypeID:    m.TypeID,
+				Seconds:   m.Seconds,
+				Value:     m.Value,
+				Lower:     m.Lower,
+				Upper:     m.Upper,
+				Status:    m.Status,
+				RootCause: m.RootCause,
+			}
+		}
+	}
+
+	return j
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// forecastDays is the days of history used for trends.  Set with MTR_FORECAST_DAYS.
+// It must be less than the retention for field.metric (availabilityRetention).
+var forecastDays = 7
+
+// forecastMinPoints is the fewest hourly averages for a trend.
+const forecastMinPoints = 6
+
+// forecastMaxPoints is the most points used for the slopes of a trend.  The number of slopes
+// grows with the square of the points so longer histories are subsampled.
+const forecastMaxPoints = 240
+
+// forecastRefresh is how often the trends for breachDays are computed.
+const forecastRefresh = time.Minute * 10
+
+// forecastHorizon is the longest forecast.  A trend from a few days of values says little
+// about further ahead than this.
+const forecastHorizon = day * 365
+
+// forecastColour is for trend projections on plots.
+const forecastColour = "darkorange"
+
+func init() {
+	if s := os.Getenv("MTR_FORECAST_DAYS"); s != "" {
+		if d, err := strconv.Atoi(s); err == nil && d > 0 && day*time.Duration(d) < availabilityRetention {
+			forecastDays = d
+		} else {
+			log.Printf("ERROR: invalid MTR_FORECAST_DAYS %s", s)
+		}
+	}
+}
+
+type trendPoint struct {
+	t     time.Time
+	value float64
+}
+
+// trend is a line through value at t changing by slope per second.
+type trend struct {
+	t     time.Time
+	value float64
+	slope float64
+}
+
+// at returns the value of the trend at x.
+func (tr trend) at(x time.Time) float64 {
+	return tr.value + tr.slope*x.Sub(tr.t).Seconds()
+}
+
+// breach returns when the trend reaches lower or upper after now, or now if it is already outside them.
+// There is no breach if the thresholds are not set, the trend is moving away from them, or it is
+// further ahead than forecastHorizon.
+func (tr trend) breach(now time.Time, lower, upper float64) (time.Time, bool) {
+	if lower == 0 && upper == 0 {
+		return time.Time{}, false
+	}
+
+	v := tr.at(now)
+
+	if v < lower || v > upper {
+		return now, true
+	}
+
+	var s float64
+
+	switch {
+	case tr.slope > 0:
+		s = (upper - v) / tr.slope
+	case tr.slope < 0:
+		s = (lower - v) / tr.slope
+	default:
+		return time.Time{}, false
+	}
+
+	if s > forecastHorizon.Seconds() {
+		return time.Time{}, false
+	}
+
+	return now.Add(time.Duration(s * float64(time.Second))), true
+}
+
+// theilSen returns the Theil-Sen trend for pts, which must be in time order.  The slope is the median
+// of the slopes between all pairs of points and the line passes through the median of the values
+// adjusted to the time of the last point.  It is not moved much by outliers such as a spike in
+// disk use.  The slopes are from at most forecastMaxPoints evenly spaced points.  false if there
+// are fewer than forecastMinPoints.
+func theilSen(pts []trendPoint) (trend, bool) {
+	if len(pts) < forecastMinPoints {
+		return trend{}, false
+	}
+
+	sp := subsample(pts, forecastMaxPoints)
+
+	var slopes []float64
+
+	for i := range sp {
+		for j := i + 1; j < len(sp); j++ {
+			if d := sp[j].t.Sub(sp[i].t).Seconds(); d > 0 {
+				slopes = append(slopes, (sp[j].value-sp[i].value)/d)
+			}
+		}
+	}
+
+	if len(slopes) == 0 {
+		return trend{}, false
+	}
+
+	tr := trend{t: pts[len(pts)-1].t, slope: median(slopes)}
+
+	values := make([]float64, len(pts))
+
+	for i, p := range pts {
+		values[i] = p.value - tr.slope*p.t.Sub(tr.t).Seconds()
+	}
+
+	tr.value = median(values)
+
+	return tr, true
+}
+
+// subsample returns n evenly spaced points from pts including the first and last.  pts if there
+// are n or fewer.
+func subsample(pts []trendPoint, n int) []trendPoint {
+	if len(pts) <= n {
+		return pts
+	}
+
+	s := make([]trendPoint, n)
+	step := float64(len(pts)-1) / float64(n-1)
+
+	for i := range s {
+		s[i] = pts[int(float64(i)*step+0.5)]
+	}
+
+	return s
+}
+
+// median returns the median of v.  v is sorted in place.
+func median(v []float64) float64 {
+	sort.Float64s(v)
+
+	n := len(v)
+
+	if n%2 == 1 {
+		return v[n/2]
+	}
+
+	return (v[n/2-1] + v[n/2]) / 2
+}
+
+// metricTrend is the trend for a field metric and its thresholds (both 0 if not set).
+type metricTrend struct {
+	deviceID, typeID string
+	lower, upper     int32
+	trend            trend
+}
+
+// trends returns the trends for field metrics from hourly averages over the last days.  devicePK and typePK
+// select metrics if not 0.  Metrics with too few values for a trend are not included.
+func trends(devicePK, typePK, days int) ([]metricTrend, error) {
+	rows, err := dbR.Query(`SELECT deviceID, typeID, COALESCE(th.lower, 0), COALESCE(th.upper, 0),
+		date_trunc('hour', time) AS t, avg(value)
+		FROM field.metric m JOIN field.device USING (devicePK) JOIN field.type USING (typePK)
+		LEFT OUTER JOIN field.threshold th ON (th.devicePK = m.devicePK AND th.typePK = m.typePK)
+		WHERE time > now() - $1 * interval '1 day'
+		AND ($2 = 0 OR m.devicePK = $2) AND ($3 = 0 OR m.typePK = $3)
+		GROUP BY deviceID, typeID, th.lower, th.upper, t
+		ORDER BY deviceID, typeID, t`, days, devicePK, typePK)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var l []metricTrend
+	var m *metricTrend
+	var pts []trendPoint
+
+	done := func() {
+		if m == nil {
+			return
+		}
+
+		var ok bool
+
+		if m.trend, ok = theilSen(pts); ok {
+			l = append(l, *m)
+		}
+	}
+
+	for rows.Next() {
+		var v metricTrend
+		var p trendPoint
+
+		if err = rows.Scan(&v.deviceID, &v.typeID, &v.lower, &v.upper, &p.t, &p.value); err != nil {
+			return nil, err
+		}
+
+		if m == nil || m.deviceID != v.deviceID || m.typeID != v.typeID {
+			done()
+			m = &v
+			pts = nil
+		}
+
+		pts = append(pts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	done()
+
+	return l, nil
+}
+
+// forecast returns the forecast for m at now.
+func (m metricTrend) forecast(now time.Time) *mtrpb.FieldMetricForecast {
+	f := mtrpb.FieldMetricForecast{
+		DeviceID: m.deviceID,
+		TypeID:   m.typeID,
+		Slope:    m.trend.slope * day.Seconds(),
+		Value:    m.trend.at(now),
+		Lower:    m.lower,
+		Upper:    m.upper,
+	}
+
+	if t, ok := m.trend.breach(now, float64(m.lower), float64(m.upper)); ok {
+		f.Breach = true
+		f.Seconds = t.Unix()
+		f.Days = math.Floor(t.Sub(now).Hours()/24*100+0.5) / 100
+	}
+
+	return &f
+}
+
+// trendCache is the trends for all field metrics over forecastDays, refreshed every forecastRefresh.
+var trendCache struct {
+	sync.Mutex
+	l      []metricTrend
+	loaded bool
+}
+
+// loadTrends returns the cached trends.  They are computed if they have not been loaded yet.
+func loadTrends() ([]metricTrend, error) {
+	trendCache.Lock()
+	defer trendCache.Unlock()
+
+	if !trendCache.loaded {
+		l, err := trends(0, 0, forecastDays)
+		if err != nil {
+			return nil, err
+		}
+
+		trendCache.l = l
+		trendCache.loaded = true
+	}
+
+	return trendCache.l, nil
+}
+
+// refreshTrends computes the trends and caches them.
+func refreshTrends() error {
+	l, err := trends(0, 0, forecastDays)
+	if err != nil {
+		return err
+	}
+
+	trendCache.Lock()
+	trendCache.l = l
+	trendCache.loaded = true
+	trendCache.Unlock()
+
+	return nil
+}
+
+func trendsTimed() {
+	ticker := time.NewTicker(forecastRefresh).C
+	for {
+		select {
+		case <-ticker:
+			if err := refreshTrends(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// breachWithin returns the deviceID and typeID, joined with a space, of field metrics forecast
+// to reach a threshold within days.  It uses the cached trends.
+func breachWithin(days float64) (map[string]bool, error) {
+	l, err := loadTrends()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	b := make(map[string]bool)
+
+	for _, m := range l {
+		if f := m.forecast(now); f.Breach && f.Days <= days {
+			b[m.deviceID+" "+m.typeID] = true
+		}
+	}
+
+	return b, nil
+}
+
+// breachFilter returns the field metrics forecast to reach a threshold within the breachDays
+// in the query, as for breachWithin.  nil if breachDays is not set.
+func breachFilter(r *http.Request) (map[string]bool, *weft.Result) {
+	v := r.URL.Query().Get("breachDays")
+
+	if v == "" {
+		return nil, &weft.StatusOK
+	}
+
+	days, err := strconv.ParseFloat(v, 64)
+	if err != nil || days < 0 {
+		return nil, weft.BadRequest("invalid breachDays")
+	}
+
+	b, err := breachWithin(days)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return b, &weft.StatusOK
+}
+
+type fieldForecast struct{}
+
+// read returns the forecasts for field metrics.  The optional deviceID and typeID select metrics and
+// days sets the history window (default forecastDays).
+func (f *fieldForecast) read(r *http.Request) ([]*mtrpb.FieldMetricForecast, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{"deviceID", "typeID", "days"}); !res.Ok {
+		return nil, res
+	}
+
+	v := r.URL.Query()
+
+	days := forecastDays
+
+	if v.Get("days") != "" {
+		var err error
+		if days, err = strconv.Atoi(v.Get("days")); err != nil || days <= 0 || day*time.Duration(days) >= availabilityRetention {
+			return nil, weft.BadRequest("invalid days")
+		}
+	}
+
+	var devicePK, typePK int
+
+	if v.Get("deviceID") != "" {
+		var res *weft.Result
+		if devicePK, res = fieldDevicePK(v.Get("deviceID")); !res.Ok {
+			return nil, res
+		}
+	}
+
+	if v.Get("typeID") != "" {
+		t, res := loadFieldType(v.Get("typeID"))
+		if !res.Ok {
+			return nil, res
+		}
+		typePK = t.typePK
+	}
+
+	l, err := trends(devicePK, typePK, days)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	now := time.Now().UTC()
+
+	var fc []*mtrpb.FieldMetricForecast
+
+	for _, m := range l {
+		fc = append(fc, m.forecast(now))
+	}
+
+	return fc, &weft.StatusOK
+}
+
+func (f *fieldForecast) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var fr mtrpb.FieldMetricForecastResult
+	var res *weft.Result
+
+	if fr.Result, res = f.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&fr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+// fieldForecastJSON is a forecast for JSON.  Breach and Days are omitted when there is no breach.
+// Days is 0 when the value is already outside the thresholds.
+type fieldForecastJSON struct {
+	DeviceID string
+	TypeID   string
+	Slope    float64
+	Value    float64
+	Lower    int32
+	Upper    int32
+	Breach   string   `json:",omitempty"`
+	Days     *float64 `json:",omitempty"`
+}
+
+func (f *fieldForecast) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := f.read(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]fieldForecastJSON, len(l))
+
+	for i, v := range l {
+		j[i] = fieldForecastJSON{
+			DeviceID: v.DeviceID,
+			TypeID:   v.TypeID,
+			Slope:    v.Slope,
+			Value:    v.Value,
+			Lower:    v.Lower,
+			Upper:    v.Upper,
+		}
+
+		if v.Breach {
+			days := v.Days
+			j[i].Breach = time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339)
+			j[i].Days = &days
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
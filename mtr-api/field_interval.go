@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultInterval is the expected interval between values for metrics with no interval set.
+// Metrics are rate limited to one value a minute.
+const defaultInterval = time.Minute
+
+// fieldInterval is the expected reporting interval for a type of metric or, if deviceID
+// is set, for a device and type.
+type fieldInterval struct{}
+
+func (f *fieldInterval) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"typeID", "seconds"}, []string{"deviceID"}); !res.Ok {
+		return res
+	}
+
+	s, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || s <= 0 {
+		return weft.BadRequest("invalid seconds")
+	}
+
+	t, res := loadFieldType(r.URL.Query().Get("typeID"))
+	if !res.Ok {
+		return res
+	}
+
+	if r.URL.Query().Get("deviceID") == "" {
+		if _, err = db.Exec(`INSERT INTO field.type_interval(typePK, seconds) VALUES($1, $2)`, t.typePK, s); err != nil {
+			if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+				// ignore unique constraint errors
+			} else {
+				return weft.InternalServerError(err)
+			}
+		}
+
+		if _, err = db.Exec(`UPDATE field.type_interval SET seconds = $2 WHERE typePK = $1`, t.typePK, s); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		return &weft.StatusOK
+	}
+
+	var fm fieldMetric
+
+	if res = fm.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err = db.Exec(`INSERT INTO field.metric_interval(devicePK, typePK, seconds) VALUES($1, $2, $3)`,
+		fm.devicePK, fm.fieldType.typePK, s); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			// ignore unique constraint errors
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	if _, err = db.Exec(`UPDATE field.metric_interval SET seconds = $3 WHERE devicePK = $1 AND typePK = $2`,
+		fm.devicePK, fm.fieldType.typePK, s); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldInterval) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"typeID"}, []string{"deviceID"}); !res.Ok {
+		return res
+	}
+
+	t, res := loadFieldType(r.URL.Query().Get("typeID"))
+	if !res.Ok {
+		return res
+	}
+
+	if r.URL.Query().Get("deviceID") == "" {
+		if _, err := db.Exec(`DELETE FROM field.type_interval WHERE typePK = $1`, t.typePK); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		return &weft.StatusOK
+	}
+
+	var fm fieldMetric
+
+	if res = fm.loadPK(r); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM field.metric_interval WHERE devicePK = $1 AND typePK = $2`,
+		fm.devicePK, fm.fieldType.typePK); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+func (f *fieldInterval) all(r *http.Request) ([]*mtrpb.FieldMetricInterval, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	rows, err := dbR.Query(`SELECT '', typeID, seconds FROM field.type_interval JOIN field.type USING (typePK)
+		UNION ALL
+		SELECT deviceID, typeID, seconds FROM field.metric_interval JOIN field.device USING (devicePK) JOIN field.type USING (typePK)
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.FieldMetricInterval
+
+	for rows.Next() {
+		var v mtrpb.FieldMetricInterval
+
+		if err = rows.Scan(&v.DeviceID, &v.TypeID, &v.Seconds); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		l = append(l, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+func (f *fieldInterval) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var ir mtrpb.FieldMetricIntervalResult
+	var res *weft.Result
+
+	if ir.Result, res = f.all(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&ir)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type fieldIntervalJSON struct {
+	DeviceID string `json:",omitempty"`
+	TypeID   string
+	Seconds  int32
+}
+
+func (f *fieldInterval) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := f.all(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]fieldIntervalJSON, len(l))
+
+	for i, v := range l {
+		j[i] = fieldIntervalJSON{DeviceID: v.DeviceID, TypeID: v.TypeID, Seconds: v.Seconds}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+// metricInterval returns the expected interval between values for the device and type.
+func metricInterval(devicePK, typePK int) (time.Duration, error) {
+	var s sql.NullInt64
+
+	if err := dbR.QueryRow(`SELECT COALESCE(
+		(SELECT seconds FROM field.metric_interval WHERE devicePK = $1 AND typePK = $2),
+		(SELECT seconds FROM field.type_interval WHERE typePK = $2))`, devicePK, typePK).Scan(&s); err != nil {
+		return 0, err
+	}
+
+	if !s.Valid {
+		return defaultInterval, nil
+	}
+
+	return time.Duration(s.Int64) * time.Second, nil
+}
+
+// metricIntervals are the expected intervals for all field metrics.
+type metricIntervals struct {
+	types   map[int]time.Duration
+	metrics map[incidentKey]time.Duration
+}
+
+// interval returns the expected interval for the device and type in k.
+func (m metricIntervals) interval(k incidentKey) time.Duration {
+	if d, ok := m.metrics[k]; ok {
+		return d
+	}
+
+	if d, ok := m.types[k.typePK]; ok {
+		return d
+	}
+
+	return defaultInterval
+}
+
+// loadMetricIntervals loads the expected intervals for all field metrics.
+func loadMetricIntervals() (metricIntervals, error) {
+	m := metricIntervals{
+		types:   make(map[int]time.Duration),
+		metrics: make(map[incidentKey]time.Duration),
+	}
+
+	rows, err := dbR.Query(`SELECT typePK, seconds FROM field.type_interval`)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var typePK, s int
+
+		if err = rows.Scan(&typePK, &s); err != nil {
+			return m, err
+		}
+
+		m.types[typePK] = time.Duration(s) * time.Second
+	}
+
+	if err = rows.Err(); err != nil {
+		return m, err
+	}
+
+	rows.Close()
+
+	if rows, err = dbR.Query(`SELECT devicePK, typePK, seconds FROM field.metric_interval`); err != nil {
+		return m, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k incidentKey
+		var s int
+
+		if err = rows.Scan(&k.pk, &k.typePK, &s); err != nil {
+			return m, err
+		}
+
+		m.metrics[k] = time.Duration(s) * time.Second
+	}
+
+	return m, rows.Err()
+}
@@ -114,7 +114,11 @@ func (f *fieldMetric) delete(r *http.Request) *weft.Result {
 }
 
 func (f *fieldMetric) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{"deviceID", "typeID"}, []string{"plot", "resolution"}); !res.Ok {
+	if r.URL.Query().Get("plot") == "overlay" {
+		return f.overlay(r, h, b)
+	}
+
+	if res := weft.CheckQuery(r, []string{"deviceID", "typeID"}, []string{"plot", "resolution", "forecast"}); !res.Ok {
 		return res
 	}
 
@@ -128,7 +132,7 @@ func (f *fieldMetric) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft
 		if resolution == "" {
 			resolution = "minute"
 		}
-		if res := f.plot(resolution, b); !res.Ok {
+		if res := f.plot(resolution, r.URL.Query().Get("forecast") == "true", b); !res.Ok {
 			return res
 		}
 	default:
@@ -201,7 +205,7 @@ func (f *fieldMetric) model() (s string, res *weft.Result) {
 plot draws an svg plot to b.  Assumes f.load has been called first.
 Valid values for resolution are 'minute', 'five_minutes', 'hour'.
 */
-func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
+func (f *fieldMetric) plot(resolution string, forecast bool, b *bytes.Buffer) *weft.Result {
 	var p ts.Plot
 
 	p.SetUnit(f.fieldType.Unit)
@@ -233,13 +237,29 @@ func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
 
 	p.SetTitle(fmt.Sprintf("Device: %s, Model: %s, Metric: %s", f.deviceID, mod, strings.Title(f.fieldType.Name)))
 
+	h := newDeviceHistory()
+	h.pk = f.devicePK
+
+	if res = h.plotEvents(&p); !res.Ok {
+		return res
+	}
+
+	interval, err := metricInterval(f.devicePK, f.fieldType.typePK)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
 	var rows *sql.Rows
-	var err error
+	var window time.Duration
+	var forecastLabel string
 
 	switch resolution {
 	case "minute":
+		window = time.Hour * 12
 		p.SetXAxis(time.Now().UTC().Add(time.Hour*-12), time.Now().UTC())
 		p.SetXLabel("12 hours")
+		forecastLabel = "12 hours and 3 hours ahead"
+		p.SetGap(plotGap(interval, time.Minute))
 
 		rows, err = dbR.Query(`SELECT date_trunc('`+resolution+`',time) as t, avg(value) FROM field.metric WHERE
 		devicePK = $1 AND typePK = $2
@@ -248,8 +268,11 @@ func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
 		ORDER BY t ASC`,
 			f.devicePK, f.fieldType.typePK)
 	case "five_minutes":
+		window = time.Hour * 24 * 2
 		p.SetXAxis(time.Now().UTC().Add(time.Hour*-24*2), time.Now().UTC())
 		p.SetXLabel("48 hours")
+		forecastLabel = "48 hours and 12 hours ahead"
+		p.SetGap(plotGap(interval, time.Minute*5))
 
 		rows, err = dbR.Query(`SELECT date_trunc('hour', time) + extract(minute from time)::int / 5 * interval '5 min' as t,
 		 avg(value) FROM field.metric WHERE
@@ -259,8 +282,11 @@ func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
 		ORDER BY t ASC`,
 			f.devicePK, f.fieldType.typePK)
 	case "hour":
+		window = time.Hour * 24 * 28
 		p.SetXAxis(time.Now().UTC().Add(time.Hour*-24*28), time.Now().UTC())
 		p.SetXLabel("4 weeks")
+		forecastLabel = "4 weeks and 1 week ahead"
+		p.SetGap(plotGap(interval, time.Hour))
 
 		rows, err = dbR.Query(`SELECT date_trunc('`+resolution+`',time) as t, avg(value) FROM field.metric WHERE
 		devicePK = $1 AND typePK = $2
@@ -305,6 +331,19 @@ func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
 
 	p.AddSeries(ts.Series{Colour: "deepskyblue", Points: pts})
 
+	m, err := fieldBaseline.markers(f.devicePK, f.fieldType.typePK, time.Now().UTC().Add(-window), f.fieldType.Scale)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	p.AddMarkers(m)
+
+	if forecast {
+		if res = f.forecast(&p, window, forecastLabel, lower, upper); !res.Ok {
+			return res
+		}
+	}
+
 	if err = ts.Line.Draw(p, b); err != nil {
 		return weft.InternalServerError(err)
 	}
@@ -312,13 +351,61 @@ func (f *fieldMetric) plot(resolution string, b *bytes.Buffer) *weft.Result {
 	return &weft.StatusOK
 }
 
+// forecast adds the trend for the metric as a dashed line from now until it reaches lower or upper,
+// or for a quarter of window.  The x axis is extended to show it and labelled with label.
+func (f *fieldMetric) forecast(p *ts.Plot, window time.Duration, label string, lower, upper int) *weft.Result {
+	l, err := trends(f.devicePK, f.fieldType.typePK, forecastDays)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	now := time.Now().UTC()
+	end := now.Add(window / 4)
+
+	p.SetXAxis(now.Add(-window), end)
+	p.SetXLabel(label)
+
+	if len(l) == 0 {
+		return &weft.StatusOK
+	}
+
+	tr := l[0].trend
+
+	if t, ok := tr.breach(now, float64(lower), float64(upper)); ok && t.Before(end) {
+		end = t
+	}
+
+	p.AddProjection(ts.Series{Colour: forecastColour, Points: []ts.Point{
+		{DateTime: now, Value: tr.at(now) * f.fieldType.Scale},
+		{DateTime: end, Value: tr.at(end) * f.fieldType.Scale},
+	}})
+
+	return &weft.StatusOK
+}
+
+// plotGap returns the longest time between values joined on a plot when values are
+// expected every interval and averaged over resolution.
+func plotGap(interval, resolution time.Duration) time.Duration {
+	if resolution > interval {
+		interval = resolution
+	}
+
+	return interval * gapFactor
+}
+
 // spark draws an svg spark line to b.  Assumes loadPK has been called already.
 func (f *fieldMetric) spark(b *bytes.Buffer) *weft.Result {
 	var p ts.Plot
 
 	p.SetXAxis(time.Now().UTC().Add(time.Hour*-12), time.Now().UTC())
 
-	var err error
+	interval, err := metricInterval(f.devicePK, f.fieldType.typePK)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	p.SetGap(plotGap(interval, time.Minute*5))
+
 	var rows *sql.Rows
 
 	if rows, err = dbR.Query(`SELECT date_trunc('hour', time) + extract(minute from time)::int / 5 * interval '5 min' as t,
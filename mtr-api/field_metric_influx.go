@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"github.com/GeoNet/weft"
+	"github.com/lib/pq"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+InfluxDB line protocol support for field metrics.  Loggers and Telegraf agents can
+write to /write as if mtr-api was an InfluxDB server e.g., for Telegraf
+
+    [[outputs.influxdb]]
+      urls = ["https://mtr-api.geonet.org.nz"]
+      username = "..."
+      password = "..."
+
+Each line is mapped to deviceID using the first of the tags listed in
+MTR_INFLUX_DEVICE_TAGS (default deviceID,host) that is present.
+
+Fields are mapped to a field metric typeID by measurement.field using the
+rules in MTR_INFLUX_TYPES e.g.,
+
+    MTR_INFLUX_TYPES=ping.average_response_ms=ping,power.volts=voltage
+
+The measurement mtr is always mapped with the field name as the typeID e.g.,
+
+    mtr,deviceID=gps-taupoairport voltage=12.5 1463262030000000000
+
+Fields that are not mapped are ignored.  A line with no mapped fields, or for an
+unknown device, is reported as an error.
+*/
+
+var influxDeviceTags = []string{"deviceID", "host"}
+
+// statusNoContent is the InfluxDB response for a successful write.
+var statusNoContent = weft.Result{Ok: true, Code: http.StatusNoContent}
+
+// influxTypes maps measurement.field to field metric typeID.
+var influxTypes = map[string]string{
+	"ping.average_response_ms": "ping",
+}
+
+func init() {
+	if err := influxRules(os.Getenv("MTR_INFLUX_DEVICE_TAGS"), os.Getenv("MTR_INFLUX_TYPES")); err != nil {
+		log.Printf("ERROR: problem with Influx config: %s", err)
+	}
+}
+
+// influxRules updates influxDeviceTags and influxTypes.
+func influxRules(tags, types string) error {
+	if t := strings.TrimSpace(tags); t != "" {
+		influxDeviceTags = strings.Split(t, ",")
+	}
+
+	for _, v := range strings.Split(types, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid type rule: %s", v)
+		}
+
+		if _, ok := fieldTypes[kv[1]]; !ok {
+			return fmt.Errorf("invalid typeID in type rule: %s", v)
+		}
+
+		influxTypes[kv[0]] = kv[1]
+	}
+
+	return nil
+}
+
+// influxPoint is a parsed line of line protocol.  String and boolean fields are dropped.
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]float64
+	t           time.Time
+}
+
+type fieldMetricInflux struct {
+	devices map[string]int
+}
+
+/*
+save parses line protocol from the request body and saves field metrics.
+Values are converted to the stored integer units using the inverse of the field type Scale.
+Lines are saved in a single transaction.  Lines that can't be saved are reported in the
+response and the remaining lines are still saved.
+*/
+func (f *fieldMetricInflux) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"db", "rp", "precision", "consistency"}); !res.Ok {
+		return res
+	}
+
+	var precision time.Duration
+
+	switch r.URL.Query().Get("precision") {
+	case "", "n", "ns":
+		precision = time.Nanosecond
+	case "u", "us":
+		precision = time.Microsecond
+	case "ms":
+		precision = time.Millisecond
+	case "s":
+		precision = time.Second
+	case "m":
+		precision = time.Minute
+	case "h":
+		precision = time.Hour
+	default:
+		return weft.BadRequest("invalid precision")
+	}
+
+	f.devices = make(map[string]int)
+
+	var err error
+	var txn *sql.Tx
+	var stmt *sql.Stmt
+
+	if txn, err = db.Begin(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	// The primary key on field.metric would abort the transaction for a repeated rate_limit
+	// so check for existing values.  See insertInfluxValue for concurrent writes.
+	if stmt, err = txn.Prepare(`INSERT INTO field.metric(devicePK, typePK, rate_limit, time, value)
+			SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM field.metric WHERE devicePK = $1 AND typePK = $2 AND rate_limit = $3)`); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	var lineErrs []string
+	now := time.Now().UTC()
+
+	s := bufio.NewScanner(r.Body)
+	n := 0
+
+	for s.Scan() {
+		n++
+
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p influxPoint
+
+		if p, err = parseInfluxLine(line, precision, now); err != nil {
+			lineErrs = append(lineErrs, fmt.Sprintf("line %d: %s", n, err.Error()))
+			continue
+		}
+
+		if msg, res := f.savePoint(txn, stmt, p); !res.Ok {
+			txn.Rollback()
+			return res
+		} else if msg != "" {
+			lineErrs = append(lineErrs, fmt.Sprintf("line %d: %s", n, msg))
+		}
+	}
+
+	if err = s.Err(); err != nil {
+		txn.Rollback()
+		return weft.BadRequest(err.Error())
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if len(lineErrs) > 0 {
+		return weft.BadRequest("partial write:\n" + strings.Join(lineErrs, "\n"))
+	}
+
+	return &statusNoContent
+}
+
+// insertInfluxValue saves a field metric value.  ok is false if there is already a value
+// for the minute.  A concurrent write for the same minute can still get past the NOT EXISTS
+// in stmt so the insert is made in a savepoint and the unique violation is rolled back
+// without aborting txn.
+func insertInfluxValue(txn *sql.Tx, stmt *sql.Stmt, devicePK, typePK int, t time.Time, value int32) (ok bool, err error) {
+	if _, err = txn.Exec(`SAVEPOINT influx_value`); err != nil {
+		return false, err
+	}
+
+	r, err := stmt.Exec(devicePK, typePK, t.Truncate(time.Minute).Unix(), t, value)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			_, e := txn.Exec(`ROLLBACK TO SAVEPOINT influx_value`)
+			return false, e
+		}
+		return false, err
+	}
+
+	if _, err = txn.Exec(`RELEASE SAVEPOINT influx_value`); err != nil {
+		return false, err
+	}
+
+	if i, err := r.RowsAffected(); err == nil && i == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// savePoint saves the mapped fields in p.  A non empty msg is returned for
+// problems with the point that should be reported to the client.
+func (f *fieldMetricInflux) savePoint(txn *sql.Tx, stmt *sql.Stmt, p influxPoint) (msg string, res *weft.Result) {
+	var deviceID string
+
+	for _, t := range influxDeviceTags {
+		if v, ok := p.tags[t]; ok {
+			deviceID = v
+			break
+		}
+	}
+
+	if deviceID == "" {
+		return "no device tag", &weft.StatusOK
+	}
+
+	devicePK, ok := f.devices[deviceID]
+	if !ok {
+		if devicePK, res = fieldDevicePK(deviceID); !res.Ok {
+			if res.Code == http.StatusBadRequest {
+				return "unknown deviceID " + deviceID, &weft.StatusOK
+			}
+			return "", res
+		}
+		f.devices[deviceID] = devicePK
+	}
+
+	var saved int
+	var dup []string
+
+	for k, v := range p.fields {
+		typeID, ok := influxTypes[p.measurement+"."+k]
+		if !ok && p.measurement == "mtr" {
+			typeID = k
+		}
+
+		ft, ok := fieldTypes[typeID]
+		if !ok {
+			continue
+		}
+
+		value := math.Floor(v/ft.Scale + 0.5)
+		if value > math.MaxInt32 || value < math.MinInt32 {
+			return fmt.Sprintf("value out of range for %s", typeID), &weft.StatusOK
+		}
+
+		ok, err := insertInfluxValue(txn, stmt, devicePK, ft.typePK, p.t, int32(value))
+		if err != nil {
+			return "", weft.InternalServerError(err)
+		}
+
+		if !ok {
+			dup = append(dup, typeID)
+		}
+
+		saved++
+	}
+
+	switch {
+	case saved == 0:
+		return "no mapped types for measurement " + p.measurement, &weft.StatusOK
+	case len(dup) > 0:
+		return "already data for the minute for " + strings.Join(dup, ","), &weft.StatusOK
+	}
+
+	return "", &weft.StatusOK
+}
+
+/*
+parseInfluxLine parses a line of line protocol:
+
+	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+
+Integer (1i), float, boolean, and string fields are parsed.  Only the numeric fields are returned.
+now is used if there is no timestamp.
+*/
+func parseInfluxLine(line string, precision time.Duration, now time.Time) (p influxPoint, err error) {
+	parts := splitInflux(line, ' ')
+
+	if len(parts) < 2 || len(parts) > 3 {
+		err = fmt.Errorf("invalid line protocol")
+		return
+	}
+
+	key := splitInflux(parts[0], ',')
+
+	p.measurement = unescapeInflux(key[0])
+	if p.measurement == "" {
+		err = fmt.Errorf("missing measurement")
+		return
+	}
+
+	p.tags = make(map[string]string)
+
+	for _, t := range key[1:] {
+		kv := splitInflux(t, '=')
+		if len(kv) != 2 {
+			err = fmt.Errorf("invalid tag %s", t)
+			return
+		}
+		p.tags[unescapeInflux(kv[0])] = unescapeInflux(kv[1])
+	}
+
+	p.fields = make(map[string]float64)
+
+	for _, fv := range splitInflux(parts[1], ',') {
+		kv := splitInflux(fv, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			err = fmt.Errorf("invalid field %s", fv)
+			return
+		}
+
+		k, v := unescapeInflux(kv[0]), kv[1]
+
+		switch {
+		case strings.HasPrefix(v, `"`):
+			// string field
+		case v == "t" || v == "T" || v == "true" || v == "True" || v == "TRUE",
+			v == "f" || v == "F" || v == "false" || v == "False" || v == "FALSE":
+			// boolean field
+		case strings.HasSuffix(v, "i"):
+			var i int64
+			if i, err = strconv.ParseInt(strings.TrimSuffix(v, "i"), 10, 64); err != nil {
+				err = fmt.Errorf("invalid integer field %s", fv)
+				return
+			}
+			p.fields[k] = float64(i)
+		default:
+			var f float64
+			if f, err = strconv.ParseFloat(v, 64); err != nil {
+				err = fmt.Errorf("invalid float field %s", fv)
+				return
+			}
+			p.fields[k] = f
+		}
+	}
+
+	if len(parts) == 3 {
+		var ts int64
+		if ts, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+			err = fmt.Errorf("invalid timestamp %s", parts[2])
+			return
+		}
+		p.t = time.Unix(0, ts*int64(precision)).UTC()
+	} else {
+		p.t = now
+	}
+
+	return
+}
+
+// splitInflux splits s on sep ignoring escaped separators and separators inside double quotes.
+func splitInflux(s string, sep byte) (r []string) {
+	var quoted bool
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				r = append(r, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	r = append(r, s[start:])
+
+	return
+}
+
+func unescapeInflux(s string) string {
+	if strings.IndexByte(s, '\\') == -1 {
+		return s
+	}
+
+	var b bytes.Buffer
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case ',', ' ', '=', '"', '\\':
+				i++
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
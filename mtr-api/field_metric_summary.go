@@ -3,14 +3,16 @@ package main
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/GeoNet/map180"
 	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
 	"github.com/golang/protobuf/proto"
-	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,53 +20,38 @@ type fieldLatest struct {
 	typeID string
 }
 
-type point struct {
-	latitude, longitude float64
-	x, y                float64
-}
-
 func (f *fieldLatest) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{}, []string{"typeID"}); !res.Ok {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "state", "breachDays"}); !res.Ok {
 		return res
 	}
 
 	typeID := r.URL.Query().Get("typeID")
 
-	var err error
-	var rows *sql.Rows
+	breach, res := breachFilter(r)
+	if !res.Ok {
+		return res
+	}
 
-	switch typeID {
-	case "":
-		rows, err = dbR.Query(`select deviceID, modelID, typeid, time, value, lower, upper
-		FROM field.metric_summary`)
-	default:
-		rows, err = dbR.Query(`select deviceID, modelID, typeid, time, value, lower, upper
-		FROM field.metric_summary
-		WHERE typeID = $1;`, typeID)
+	var sf stateFilter
+
+	if res := sf.read(r); !res.Ok {
+		return res
 	}
+
+	summaries, err := fieldSummaries(`$1 = '' OR s.typeID = $1`, []interface{}{typeID}, sf)
 	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
-	defer rows.Close()
-
-	var t time.Time
 	var fmlr mtrpb.FieldMetricSummaryResult
 
-	for rows.Next() {
-
-		var fmr mtrpb.FieldMetricSummary
-
-		if err = rows.Scan(&fmr.DeviceID, &fmr.ModelID, &fmr.TypeID, &t, &fmr.Value,
-			&fmr.Lower, &fmr.Upper); err != nil {
-			return weft.InternalServerError(err)
+	for _, fmr := range summaries {
+		if breach != nil && !breach[fmr.DeviceID+" "+fmr.TypeID] {
+			continue
 		}
 
-		fmr.Seconds = t.Unix()
-
-		fmlr.Result = append(fmlr.Result, &fmr)
+		fmlr.Result = append(fmlr.Result, fmr)
 	}
-	rows.Close()
 
 	var by []byte
 
@@ -79,8 +66,50 @@ func (f *fieldLatest) proto(r *http.Request, h http.Header, b *bytes.Buffer) *we
 	return &weft.StatusOK
 }
 
+// fieldSummaries returns the latest field metric summaries, with the status and anomaly score, for
+// the devices in sf.  where is an SQL boolean expression on field.metric_summary s with its args in args.
+func fieldSummaries(where string, args []interface{}, sf stateFilter) ([]*mtrpb.FieldMetricSummary, error) {
+	tp, err := loadTopology()
+	if err != nil {
+		return nil, err
+	}
+
+	state := sf.sql("state", &args)
+
+	rows, err := dbR.Query(`SELECT s.deviceID, s.modelID, s.typeID, s.time, s.value, s.lower, s.upper, b.median, b.mad, b.meanad
+		FROM field.metric_summary s `+fieldBaselineSQL+`
+		WHERE (`+where+`)
+		AND s.devicePK IN (SELECT devicePK FROM field.device WHERE `+state+`)
+		ORDER BY s.deviceID, s.typeID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*mtrpb.FieldMetricSummary
+
+	for rows.Next() {
+		var fmr mtrpb.FieldMetricSummary
+		var t time.Time
+		var median, mad, meanAD sql.NullFloat64
+
+		if err = rows.Scan(&fmr.DeviceID, &fmr.ModelID, &fmr.TypeID, &t, &fmr.Value,
+			&fmr.Lower, &fmr.Upper, &median, &mad, &meanAD); err != nil {
+			return nil, err
+		}
+
+		fmr.Seconds = t.Unix()
+		fmr.Score, fmr.Anomalous = anomaly(float64(fmr.Value), median, mad, meanAD)
+		fmr.Status, fmr.RootCause = tp.fieldStatus(fmr.DeviceID, fieldStatus(t, fmr.Value, fmr.Lower, fmr.Upper))
+
+		summaries = append(summaries, &fmr)
+	}
+
+	return summaries, rows.Err()
+}
+
 func (f *fieldLatest) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{"bbox", "width", "typeID"}, []string{}); !res.Ok {
+	if res := weft.CheckQuery(r, []string{"bbox", "width", "typeID"}, []string{"state"}); !res.Ok {
 		return res
 	}
 
@@ -89,7 +118,7 @@ func (f *fieldLatest) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft
 	var err error
 
 	f.typeID = r.URL.Query().Get("typeID")
-	bbox := r.URL.Query().Get("bbox")
+	bbox := mapBboxLookup(r.URL.Query().Get("bbox"))
 
 	if err = map180.ValidBbox(bbox); err != nil {
 		return weft.BadRequest(err.Error())
@@ -99,97 +128,197 @@ func (f *fieldLatest) svg(r *http.Request, h http.Header, b *bytes.Buffer) *weft
 		return weft.BadRequest("invalid width")
 	}
 
+	if _, ok := fieldTypes[f.typeID]; !ok {
+		return weft.BadRequest("invalid type " + f.typeID)
+	}
+
 	var raw map180.Raw
 	if raw, err = wm.MapRaw(bbox, width); err != nil {
 		return weft.InternalServerError(err)
 	}
 
-	if rows, err = dbR.Query(`with p as (select geom, time, value, lower, upper,
+	var sf stateFilter
+
+	if res := sf.read(r); !res.Ok {
+		return res
+	}
+
+	tp, err := loadTopology()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	args := []interface{}{f.typeID}
+	state := sf.sql("state", &args)
+
+	if rows, err = dbR.Query(`with p as (select geom, deviceID, modelID, time, value, lower, upper,
 			st_transform(geom::geometry, 3857) as pt
 			FROM field.metric_summary
-			where typeID = $1)
-			select ST_X(pt), ST_Y(pt)*-1, ST_X(geom::geometry),ST_Y(geom::geometry), time,
-			value, lower,upper from p`, f.typeID); err != nil {
+			where typeID = $1
+			AND devicePK IN (SELECT devicePK FROM field.device WHERE `+state+`))
+			select ST_X(pt), ST_Y(pt)*-1, ST_X(geom::geometry),ST_Y(geom::geometry), deviceID, modelID, time,
+			value, lower,upper from p`, args...); err != nil {
 		return weft.InternalServerError(err)
 	}
 	defer rows.Close()
 
-	ago := time.Now().UTC().Add(time.Hour * -3)
+	ft := fieldTypes[f.typeID]
 
-	var late []point
-	var good []point
-	var bad []point
-	var dunno []point
+	var pts []point
 
 	for rows.Next() {
 		var p point
 		var t time.Time
 		var min, max, v int
+		var deviceID, modelID string
 
-		if err = rows.Scan(&p.x, &p.y, &p.longitude, &p.latitude, &t, &v, &min, &max); err != nil {
+		if err = rows.Scan(&p.x, &p.y, &p.longitude, &p.latitude, &deviceID, &modelID, &t, &v, &min, &max); err != nil {
 			return weft.InternalServerError(err)
 		}
 
-		// Does not handle crossing the equator.
-		switch {
-		case raw.CrossesCentral && p.longitude > -180.0 && p.longitude < 0.0:
-			p.x = (p.x + map180.Width3857 - raw.LLX) * raw.DX
-			p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
-		case p.longitude > 0.0:
-			p.x = (p.x - math.Abs(raw.XShift)) * raw.DX
-			p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
-		default:
-			p.x = (p.x + math.Abs(raw.XShift)) * raw.DX
-			p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
+		p.project(raw)
 
+		var cause []string
+
+		p.status, cause = tp.fieldStatus(deviceID, fieldStatus(t, int32(v), int32(min), int32(max)))
+		p.title = fmt.Sprintf("%s (%s) %g %s, %s", deviceID, modelID, float64(v)*ft.Scale, ft.Unit, age(t))
+
+		if len(cause) > 0 {
+			p.title += ", impacted by " + strings.Join(cause, ", ")
 		}
-		switch {
-		case t.Before(ago):
-			late = append(late, p)
-		case min == 0 && max == 0:
-			dunno = append(dunno, p)
-		case v < min || v > max:
-			bad = append(bad, p)
-		default:
-			good = append(good, p)
-		}
+		p.link = plotLink("/field/plot", url.Values{"deviceID": {deviceID}, "typeID": {f.typeID}})
+
+		pts = append(pts, p)
 	}
 	rows.Close()
 
-	b.WriteString(`<?xml version="1.0"?>`)
-	b.WriteString(fmt.Sprintf("<svg  viewBox=\"0 0 %d %d\"  xmlns=\"http://www.w3.org/2000/svg\">",
-		raw.Width, raw.Height))
-	b.WriteString(fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" style=\"fill: azure\"/>", raw.Width, raw.Height))
-	b.WriteString(fmt.Sprintf("<path style=\"fill: wheat; stroke-width: 1; stroke-linejoin: round; stroke: lightslategrey\" d=\"%s\"/>", raw.Land))
-	b.WriteString(fmt.Sprintf("<path style=\"fill: azure; stroke-width: 1; stroke-linejoin: round; stroke: lightslategrey\" d=\"%s\"/>", raw.Lakes))
+	statusMapSVG(raw, pts, b)
 
-	b.WriteString("<g style=\"stroke: #377eb8; fill: #377eb8; \">") // blueish
-	for _, p := range dunno {
-		b.WriteString(fmt.Sprintf("<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"/>", p.x, p.y, 5))
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
+
+type fieldLatestProperties struct {
+	DeviceID  string   `json:"deviceID"`
+	ModelID   string   `json:"modelID"`
+	TypeID    string   `json:"typeID"`
+	Time      string   `json:"time"`
+	Value     float64  `json:"value"`
+	Lower     float64  `json:"lower"`
+	Upper     float64  `json:"upper"`
+	Unit      string   `json:"unit"`
+	Status    string   `json:"status"`
+	RootCause []string `json:"rootCause,omitempty"`
+	Tags      []string `json:"tags"`
+}
+
+/*
+geoJSON writes the latest field metrics as a GeoJSON FeatureCollection.  value, lower,
+and upper are scaled for display in unit.
+*/
+func (f *fieldLatest) geoJSON(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"typeID", "bbox", "state", "breachDays"}); !res.Ok {
+		return res
+	}
+
+	breach, res := breachFilter(r)
+	if !res.Ok {
+		return res
+	}
+
+	var s spatialQuery
+
+	if res := s.read(r); !res.Ok {
+		return res
 	}
-	b.WriteString("</g>")
 
-	b.WriteString("<g style=\"stroke: #4daf4a; fill: #4daf4a; \">") // greenish
-	for _, p := range good {
-		b.WriteString(fmt.Sprintf("<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"/>", p.x, p.y, 5))
+	var args []interface{}
+
+	q, err := s.selectSQL("field.metric_summary", "devicePK, typePK", "TRUE", &args)
+	if err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	f.typeID = r.URL.Query().Get("typeID")
+
+	if f.typeID != "" {
+		if _, ok := fieldTypes[f.typeID]; !ok {
+			return weft.BadRequest("invalid type " + f.typeID)
+		}
 	}
-	b.WriteString("</g>")
 
-	b.WriteString("<g style=\"stroke: #e41a1c; fill: #e41a1c; \">") //red
-	for _, p := range bad {
-		b.WriteString(fmt.Sprintf("<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"/>", p.x, p.y, 6))
+	var sf stateFilter
+
+	if res := sf.read(r); !res.Ok {
+		return res
 	}
-	b.WriteString("</g>")
 
-	b.WriteString("<g style=\"stroke: #984ea3; fill: #984ea3; \">") // purple
-	for _, p := range late {
-		b.WriteString(fmt.Sprintf("<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"/>", p.x, p.y, 6))
+	tp, err := loadTopology()
+	if err != nil {
+		return weft.InternalServerError(err)
 	}
-	b.WriteString("</g>")
 
-	b.WriteString("</svg>")
+	args = append(args, f.typeID)
+	n := strconv.Itoa(len(args))
+	state := sf.sql("state", &args)
 
-	h.Set("Content-Type", "image/svg+xml")
+	var rows *sql.Rows
+
+	if rows, err = dbR.Query(`WITH d AS (`+q+`),
+		t AS (SELECT devicePK, typePK, string_agg(tag, ',' ORDER BY tag) AS tags
+			FROM field.metric_tag JOIN mtr.tag USING (tagPK) GROUP BY devicePK, typePK)
+		SELECT ST_AsGeoJSON(geom), deviceID, modelID, typeID, time, value, lower, upper, COALESCE(tags, '')
+		FROM d JOIN field.metric_summary USING (devicePK, typePK)
+		LEFT OUTER JOIN t USING (devicePK, typePK)
+		WHERE ($`+n+` = '' OR typeID = $`+n+`)
+		AND devicePK IN (SELECT devicePK FROM field.device WHERE `+state+`)
+		ORDER BY deviceID, typeID`, args...); err != nil {
+		return weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	g := newGeoJSONFeatureCollection()
+
+	for rows.Next() {
+		var p fieldLatestProperties
+		var geom, tags string
+		var t time.Time
+		var value, lower, upper int32
+
+		if err = rows.Scan(&geom, &p.DeviceID, &p.ModelID, &p.TypeID, &t, &value, &lower, &upper, &tags); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		if breach != nil && !breach[p.DeviceID+" "+p.TypeID] {
+			continue
+		}
+
+		ft := fieldTypes[p.TypeID]
+
+		p.Time = t.UTC().Format(time.RFC3339)
+		p.Value = float64(value) * ft.Scale
+		p.Lower = float64(lower) * ft.Scale
+		p.Upper = float64(upper) * ft.Scale
+		p.Unit = ft.Unit
+		p.Status, p.RootCause = tp.fieldStatus(p.DeviceID, fieldStatus(t, value, lower, upper))
+		p.Tags = splitTags(tags)
+
+		g.add(geom, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	by, err := json.Marshal(g)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/vnd.geo+json")
 
 	return &weft.StatusOK
 }
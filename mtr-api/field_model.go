@@ -3,7 +3,10 @@ package main
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
 	"github.com/lib/pq"
 	"net/http"
 )
@@ -58,19 +61,93 @@ func (f *fieldModel) delete(r *http.Request) *weft.Result {
 	return &weft.StatusOK
 }
 
-func (f *fieldModel) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+// models returns the field models with their attributes.
+func (f *fieldModel) models(r *http.Request) ([]*mtrpb.FieldModel, *weft.Result) {
 	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	rows, err := dbR.Query(`SELECT modelID FROM field.model ORDER BY modelID ASC`)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var models []*mtrpb.FieldModel
+
+	for rows.Next() {
+		var m mtrpb.FieldModel
+
+		if err = rows.Scan(&m.ModelID); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		models = append(models, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	a, err := loadAttributes(`SELECT modelID, key, value FROM field.model_attribute
+		JOIN field.model USING (modelPK) ORDER BY modelID, key`)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	for _, m := range models {
+		m.Attributes = a[m.ModelID]
+	}
+
+	return models, &weft.StatusOK
+}
+
+func (f *fieldModel) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var fmr mtrpb.FieldModelResult
+	var res *weft.Result
+
+	if fmr.Result, res = f.models(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&fmr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type fieldModelJSON struct {
+	ModelID    string
+	Attributes map[string]string
+}
+
+func (f *fieldModel) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	models, res := f.models(r)
+	if !res.Ok {
 		return res
 	}
 
-	var s string
+	j := make([]fieldModelJSON, len(models))
+
+	for i, m := range models {
+		j[i] = fieldModelJSON{
+			ModelID:    m.ModelID,
+			Attributes: attributeMap(m.Attributes),
+		}
+	}
 
-	if err := dbR.QueryRow(`SELECT COALESCE(array_to_json(array_agg(row_to_json(l))), '[]')
-		FROM (SELECT modelID as "ModelID" FROM field.model) l`).Scan(&s); err != nil {
+	by, err := json.Marshal(j)
+	if err != nil {
 		return weft.InternalServerError(err)
 	}
 
-	b.WriteString(s)
+	b.Write(by)
 
 	h.Set("Content-Type", "application/json;version=1")
 
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// GeoJSON types for application/vnd.geo+json responses.  The geometry comes
+// from PostGIS (ST_AsGeoJSON) so it is not decoded.
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties interface{}     `json:"properties"`
+}
+
+func newGeoJSONFeatureCollection() geoJSONFeatureCollection {
+	return geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+}
+
+func (g *geoJSONFeatureCollection) add(geometry string, properties interface{}) {
+	g.Features = append(g.Features, geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   json.RawMessage(geometry),
+		Properties: properties,
+	})
+}
+
+// splitTags splits the comma separated tags from string_agg.
+func splitTags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
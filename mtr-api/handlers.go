@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/GeoNet/weft"
 	"net/http"
+	"strings"
 )
 
 func appMetricHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
@@ -55,6 +56,16 @@ func applicationTimerHandler(r *http.Request, h http.Header, b *bytes.Buffer) *w
 	}
 }
 
+func applicationPrometheusHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var a applicationPrometheus
+
+	switch r.Method {
+	case "POST":
+		return a.save(r)
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
 
 func fieldMetricHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldMetric
@@ -74,6 +85,17 @@ func fieldMetricHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.R
 	}
 }
 
+func fieldMetricInfluxHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var f fieldMetricInflux
+
+	switch r.Method {
+	case "POST":
+		return f.save(r)
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func fieldMetricTagHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldMetricTag
 
@@ -131,6 +153,95 @@ func tagsHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	}
 }
 
+func tagQueryHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var t tagQuery
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return t.search(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func tagBulkHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var t tagBulk
+
+	switch r.Method {
+	case "PUT", "DELETE":
+		return t.save(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return t.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func tagNamespaceHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var t tagNamespace
+
+	switch r.Method {
+	case "PUT":
+		return t.save(r)
+	case "DELETE":
+		return t.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return t.proto(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func dashboardHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var d dashboard
+
+	switch r.Method {
+	case "PUT":
+		return d.save(r)
+	case "DELETE":
+		return d.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return d.jsonV1(r, h, b)
+		case "application/x-protobuf":
+			return d.proto(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func dashboardPanelHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var d dashboardPanel
+
+	switch r.Method {
+	case "PUT":
+		return d.save(r)
+	case "DELETE":
+		return d.delete(r)
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func fieldModelHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldModel
 
@@ -141,6 +252,8 @@ func fieldModelHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Re
 		return f.delete(r)
 	case "GET":
 		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
 		case "application/json;version=1":
 			return f.jsonV1(r, h, b)
 		default:
@@ -161,6 +274,40 @@ func fieldDeviceHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.R
 		return f.delete(r)
 	case "GET":
 		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
+		case "application/json;version=1":
+			return f.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldModelAttributeHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	f := newModelAttribute()
+
+	return fieldAttributeHandler(&f, r, h, b)
+}
+
+func fieldDeviceAttributeHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	f := newDeviceAttribute()
+
+	return fieldAttributeHandler(&f, r, h, b)
+}
+
+func fieldAttributeHandler(f *fieldAttribute, r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	switch r.Method {
+	case "PUT":
+		return f.save(r)
+	case "DELETE":
+		return f.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
 		case "application/json;version=1":
 			return f.jsonV1(r, h, b)
 		default:
@@ -171,6 +318,196 @@ func fieldDeviceHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.R
 	}
 }
 
+func fieldDeviceStateHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l := newDeviceLifecycle()
+
+	return lifecycleHandler(&l, r, h, b)
+}
+
+func dataSiteStateHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	l := newSiteLifecycle()
+
+	return lifecycleHandler(&l, r, h, b)
+}
+
+func dataSiteDeviceHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var s siteDevice
+
+	switch r.Method {
+	case "PUT":
+		return s.save(r)
+	case "DELETE":
+		return s.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return s.proto(r, h, b)
+		case "application/json;version=1":
+			return s.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldDeviceDependencyHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var f fieldDependency
+
+	switch r.Method {
+	case "PUT":
+		return f.save(r)
+	case "DELETE":
+		return f.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
+		case "application/json;version=1":
+			return f.jsonV1(r, h, b)
+		default:
+			return f.svg(r, h, b)
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func siteHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var s site
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return s.proto(r, h, b)
+		case "application/json;version=1":
+			return s.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func incidentHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var n incident
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return n.proto(r, h, b)
+		case "application/json;version=1":
+			return n.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func availabilityHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var rp availabilityReport
+
+	switch r.Method {
+	case "GET":
+		switch a := r.Header.Get("Accept"); {
+		case a == "application/json;version=1":
+			return rp.jsonV1(r, h, b)
+		case a == "text/csv":
+			return rp.csv(r, h, b)
+		case a == "" || strings.HasPrefix(a, "text/html"):
+			return rp.html(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func lifecycleHandler(l *lifecycle, r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	switch r.Method {
+	case "PUT":
+		return l.save(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return l.proto(r, h, b)
+		case "application/json;version=1":
+			return l.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldDeviceRenameHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	d := newDeviceHistory()
+
+	return renameHandler(&d, r, h, b)
+}
+
+func dataSiteRenameHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	d := newSiteHistory()
+
+	return renameHandler(&d, r, h, b)
+}
+
+func renameHandler(d *history, r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	switch r.Method {
+	case "PUT":
+		return d.rename(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return d.renameProto(r, h, b)
+		case "application/json;version=1":
+			return d.renameJSONV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldDeviceLocationHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	d := newDeviceHistory()
+
+	return locationHandler(&d, r, h, b)
+}
+
+func dataSiteLocationHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	d := newSiteHistory()
+
+	return locationHandler(&d, r, h, b)
+}
+
+func locationHandler(d *history, r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	switch r.Method {
+	case "PUT":
+		return d.relocate(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return d.locationProto(r, h, b)
+		case "application/json;version=1":
+			return d.locationJSONV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func fieldTypeHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldType
 
@@ -187,6 +524,24 @@ func fieldTypeHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Res
 	}
 }
 
+func mapBboxHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var m mapBbox
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/x-protobuf":
+			return m.proto(r, h, b)
+		case "application/json;version=1":
+			return m.jsonV1(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func fieldThresholdHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldThreshold
 
@@ -209,6 +564,64 @@ func fieldThresholdHandler(r *http.Request, h http.Header, b *bytes.Buffer) *wef
 	}
 }
 
+func fieldIntervalHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var f fieldInterval
+
+	switch r.Method {
+	case "PUT":
+		return f.save(r)
+	case "DELETE":
+		return f.delete(r)
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return f.jsonV1(r, h, b)
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldCompletenessHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var f fieldCompleteness
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return f.jsonV1(r, h, b)
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
+func fieldForecastHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var f fieldForecast
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return f.jsonV1(r, h, b)
+		case "application/x-protobuf":
+			return f.proto(r, h, b)
+		default:
+			return &weft.NotAcceptable
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func fieldMetricLatestHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var f fieldLatest
 
@@ -217,6 +630,8 @@ func fieldMetricLatestHandler(r *http.Request, h http.Header, b *bytes.Buffer) *
 		switch r.Header.Get("Accept") {
 		case "application/x-protobuf":
 			return f.proto(r, h, b)
+		case "application/vnd.geo+json":
+			return f.geoJSON(r, h, b)
 		default:
 			return f.svg(r, h, b)
 		}
@@ -225,6 +640,24 @@ func fieldMetricLatestHandler(r *http.Request, h http.Header, b *bytes.Buffer) *
 	}
 }
 
+func dataLatencyNetworkHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var d dataLatencyNetwork
+
+	switch r.Method {
+	case "GET":
+		switch r.Header.Get("Accept") {
+		case "application/json;version=1":
+			return d.jsonV1(r, h, b)
+		case "application/x-protobuf":
+			return d.proto(r, h, b)
+		default:
+			return d.svg(r, h, b)
+		}
+	default:
+		return &weft.MethodNotAllowed
+	}
+}
+
 func dataSiteHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 	var d dataSite
 
@@ -233,6 +666,8 @@ func dataSiteHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Resu
 		switch r.Header.Get("Accept") {
 		case "application/x-protobuf":
 			return d.proto(r, h, b)
+		case "application/json;version=1":
+			return d.jsonV1(r, h, b)
 		default:
 			return &weft.NotAcceptable
 		}
@@ -312,10 +747,10 @@ func dataLatencySummaryHandler(r *http.Request, h http.Header, b *bytes.Buffer)
 		switch r.Header.Get("Accept") {
 		case "application/x-protobuf":
 			return d.proto(r, h, b)
-			//default:
-			//	return f.svg(r, h, b)
+		case "application/vnd.geo+json":
+			return d.geoJSON(r, h, b)
 		default:
-			return &weft.NotAcceptable
+			return d.svg(r, h, b)
 		}
 	default:
 		return &weft.MethodNotAllowed
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/mtr/ts"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// history is the rename and location history for a field device or data site.
+// Metrics, tags, and thresholds reference the device or site by pk so they are kept
+// when the device or site is renamed or moved.  Dashboard panels reference it by ID
+// and are updated when it is renamed.
+type history struct {
+	table         string // the device or site table e.g., field.device
+	renameTable   string // e.g., field.device_rename
+	locationTable string // e.g., field.device_location
+	pkCol         string // e.g., devicePK
+	idParam       string // the query parameter and ID column e.g., deviceID
+	newParam      string // the query parameter for a rename e.g., newDeviceID
+	panelKind     string // the dashboard panel kind e.g., field
+	pk            int
+}
+
+func newDeviceHistory() history {
+	return history{table: "field.device", renameTable: "field.device_rename", locationTable: "field.device_location",
+		pkCol: "devicePK", idParam: "deviceID", newParam: "newDeviceID", panelKind: "field"}
+}
+
+func newSiteHistory() history {
+	return history{table: "data.site", renameTable: "data.site_rename", locationTable: "data.site_location",
+		pkCol: "sitePK", idParam: "siteID", newParam: "newSiteID", panelKind: "data"}
+}
+
+func (h *history) loadPK(r *http.Request) *weft.Result {
+	var res *weft.Result
+
+	switch h.idParam {
+	case "deviceID":
+		h.pk, res = fieldDevicePK(r.URL.Query().Get("deviceID"))
+	default:
+		var d dataSite
+		res = d.loadPK(r)
+		h.pk = d.sitePK
+	}
+
+	return res
+}
+
+// rename changes the deviceID or siteID and updates the dashboard panels for it.  The rename is logged
+// with the time and changedBy.
+func (h *history) rename(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{h.idParam, h.newParam, "changedBy"}, []string{}); !res.Ok {
+		return res
+	}
+
+	user, res := changedBy(r)
+	if !res.Ok {
+		return res
+	}
+
+	oldID := r.URL.Query().Get(h.idParam)
+	newID := r.URL.Query().Get(h.newParam)
+
+	if newID == "" || newID == oldID {
+		return weft.BadRequest("invalid " + h.newParam)
+	}
+
+	if res := h.loadPK(r); !res.Ok {
+		return res
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if _, err = txn.Exec(`UPDATE `+h.table+` SET `+h.idParam+` = $2 WHERE `+h.pkCol+` = $1`, h.pk, newID); err != nil {
+		txn.Rollback()
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			return weft.BadRequest(newID + " already exists")
+		}
+		return weft.InternalServerError(err)
+	}
+
+	if _, err = txn.Exec(`INSERT INTO `+h.renameTable+`(`+h.pkCol+`, oldID, newID, time, changedBy)
+		VALUES($1, $2, $3, now(), $4)`, h.pk, oldID, newID, user); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if _, err = txn.Exec(`UPDATE mtr.dashboard_panel SET sourceID = $3 WHERE kind = $1 AND sourceID = $2`,
+		h.panelKind, oldID, newID); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// relocate adds a location for the device or site from time (default now), logged with changedBy.
+// The current location for the device or site is set to the latest location.
+func (h *history) relocate(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{h.idParam, "latitude", "longitude", "changedBy"}, []string{"time"}); !res.Ok {
+		return res
+	}
+
+	user, res := changedBy(r)
+	if !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	var latitude, longitude float64
+	var err error
+
+	if latitude, err = strconv.ParseFloat(v.Get("latitude"), 64); err != nil || latitude < -90 || latitude > 90 {
+		return weft.BadRequest("latitude invalid")
+	}
+
+	if longitude, err = strconv.ParseFloat(v.Get("longitude"), 64); err != nil || longitude < -180 || longitude > 360 {
+		return weft.BadRequest("longitude invalid")
+	}
+
+	t := time.Now().UTC()
+
+	if v.Get("time") != "" {
+		if t, err = time.Parse(time.RFC3339, v.Get("time")); err != nil {
+			return weft.BadRequest("invalid time")
+		}
+	}
+
+	if res := h.loadPK(r); !res.Ok {
+		return res
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if err = h.saveLocation(txn, latitude, longitude, t, user); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if _, err = txn.Exec(`UPDATE `+h.table+` SET latitude = l.latitude, longitude = l.longitude
+		FROM (SELECT latitude, longitude FROM `+h.locationTable+` WHERE `+h.pkCol+` = $1
+		ORDER BY time DESC LIMIT 1) l
+		WHERE `+h.pkCol+` = $1`, h.pk); err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// saveLocation adds or updates the location at t for h.pk.  t is truncated to the second.
+func (h *history) saveLocation(txn *sql.Tx, latitude, longitude float64, t time.Time, user string) error {
+	t = t.Truncate(time.Second)
+
+	res, err := txn.Exec(`UPDATE `+h.locationTable+` SET latitude = $2, longitude = $3, changedBy = $5
+		WHERE `+h.pkCol+` = $1 AND time = $4`, h.pk, latitude, longitude, t, user)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		_, err = txn.Exec(`INSERT INTO `+h.locationTable+`(`+h.pkCol+`, latitude, longitude, time, changedBy)
+			VALUES($1, $2, $3, $4, $5)`, h.pk, latitude, longitude, t, user)
+	}
+
+	return err
+}
+
+// initLocation records the current location for the device or site identified by id
+// if it is different to the latest location.  Used when a device or site is saved.
+func (h *history) initLocation(id string) error {
+	var latitude, longitude float64
+
+	if err := db.QueryRow(`SELECT `+h.pkCol+`, latitude, longitude FROM `+h.table+` WHERE `+h.idParam+` = $1`,
+		id).Scan(&h.pk, &latitude, &longitude); err != nil {
+		return err
+	}
+
+	var lat, lon float64
+
+	err := db.QueryRow(`SELECT latitude, longitude FROM `+h.locationTable+` WHERE `+h.pkCol+` = $1
+		ORDER BY time DESC LIMIT 1`, h.pk).Scan(&lat, &lon)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case lat == latitude && lon == longitude:
+		return nil
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = h.saveLocation(txn, latitude, longitude, time.Now().UTC(), ""); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	return txn.Commit()
+}
+
+func (h *history) renames(r *http.Request) ([]*mtrpb.Rename, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{h.idParam}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	if res := h.loadPK(r); !res.Ok {
+		return nil, res
+	}
+
+	return h.loadRenames()
+}
+
+// loadRenames returns the renames for h.pk oldest first.
+func (h *history) loadRenames() ([]*mtrpb.Rename, *weft.Result) {
+	rows, err := dbR.Query(`SELECT oldID, newID, time, changedBy FROM `+h.renameTable+`
+		WHERE `+h.pkCol+` = $1 ORDER BY time ASC, renamePK ASC`, h.pk)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var n []*mtrpb.Rename
+
+	for rows.Next() {
+		var v mtrpb.Rename
+		var t time.Time
+
+		if err = rows.Scan(&v.OldID, &v.NewID, &t, &v.ChangedBy); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		v.Seconds = t.Unix()
+
+		n = append(n, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return n, &weft.StatusOK
+}
+
+func (h *history) locations(r *http.Request) ([]*mtrpb.Location, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{h.idParam}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	if res := h.loadPK(r); !res.Ok {
+		return nil, res
+	}
+
+	return h.loadLocations()
+}
+
+// loadLocations returns the locations for h.pk oldest first.
+func (h *history) loadLocations() ([]*mtrpb.Location, *weft.Result) {
+	rows, err := dbR.Query(`SELECT latitude, longitude, time, changedBy FROM `+h.locationTable+`
+		WHERE `+h.pkCol+` = $1 ORDER BY time ASC`, h.pk)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []*mtrpb.Location
+
+	for rows.Next() {
+		var v mtrpb.Location
+		var t time.Time
+
+		if err = rows.Scan(&v.Latitude, &v.Longitude, &t, &v.ChangedBy); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		v.Seconds = t.Unix()
+
+		l = append(l, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+// plotEvents adds the renames and moves for h.pk to p.
+func (h *history) plotEvents(p *ts.Plot) *weft.Result {
+	n, res := h.loadRenames()
+	if !res.Ok {
+		return res
+	}
+
+	for _, v := range n {
+		p.AddEvent(time.Unix(v.Seconds, 0).UTC(), "renamed from "+v.OldID)
+	}
+
+	l, res := h.loadLocations()
+	if !res.Ok {
+		return res
+	}
+
+	// the first location is where the device or site was created.
+	for i := 1; i < len(l); i++ {
+		p.AddEvent(time.Unix(l[i].Seconds, 0).UTC(), "moved")
+	}
+
+	return &weft.StatusOK
+}
+
+func (h *history) renameProto(r *http.Request, hd http.Header, b *bytes.Buffer) *weft.Result {
+	var rr mtrpb.RenameResult
+	var res *weft.Result
+
+	if rr.Result, res = h.renames(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&rr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	hd.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type renameJSON struct {
+	OldID     string
+	NewID     string
+	Time      string
+	ChangedBy string
+}
+
+func (h *history) renameJSONV1(r *http.Request, hd http.Header, b *bytes.Buffer) *weft.Result {
+	n, res := h.renames(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]renameJSON, len(n))
+
+	for i, v := range n {
+		j[i] = renameJSON{
+			OldID:     v.OldID,
+			NewID:     v.NewID,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	hd.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+func (h *history) locationProto(r *http.Request, hd http.Header, b *bytes.Buffer) *weft.Result {
+	var lr mtrpb.LocationResult
+	var res *weft.Result
+
+	if lr.Result, res = h.locations(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&lr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	hd.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type locationJSON struct {
+	Latitude  float64
+	Longitude float64
+	Time      string
+	ChangedBy string
+}
+
+func (h *history) locationJSONV1(r *http.Request, hd http.Header, b *bytes.Buffer) *weft.Result {
+	l, res := h.locations(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]locationJSON, len(l))
+
+	for i, v := range l {
+		j[i] = locationJSON{
+			Latitude:  v.Latitude,
+			Longitude: v.Longitude,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	hd.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
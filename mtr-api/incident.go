@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// incidentWindow is how far back each incident scan looks for metrics.  It must be
+// longer than lateAfter so that late periods are found.
+const incidentWindow = time.Hour * 6
+
+// incidentPoint is a metric value with its thresholds.
+type incidentPoint struct {
+	t                   time.Time
+	value, lower, upper int32
+}
+
+// excess returns how far p is outside its thresholds.  0 if p is good or there are no thresholds.
+func (p incidentPoint) excess() int32 {
+	switch {
+	case p.lower == 0 && p.upper == 0:
+		return 0
+	case p.value < p.lower:
+		return p.lower - p.value
+	case p.value > p.upper:
+		return p.value - p.upper
+	}
+	return 0
+}
+
+// incidentSpan is a contiguous bad or late period.  end is zero for an ongoing span.
+type incidentSpan struct {
+	start, end time.Time
+	worst      int32
+	excess     int32
+	status     string
+}
+
+// add includes p in s if it is further outside the thresholds than the current worst.
+func (s *incidentSpan) add(p incidentPoint) {
+	if e := p.excess(); e > s.excess {
+		s.worst = p.value
+		s.excess = e
+	}
+}
+
+// incidentSpans merges contiguous bad points in pts, and gaps longer than lateAfter,
+// into spans.  pts must be for one metric and in time order.
+func incidentSpans(pts []incidentPoint, now time.Time) []incidentSpan {
+	var spans []incidentSpan
+	var cur *incidentSpan
+
+	for i, p := range pts {
+		if i > 0 && p.t.Sub(pts[i-1].t) > lateAfter {
+			if cur == nil {
+				cur = &incidentSpan{start: pts[i-1].t.Add(lateAfter), worst: pts[i-1].value, status: "late"}
+			}
+			cur.status = worstStatus(cur.status, "late")
+		}
+
+		if p.excess() > 0 {
+			if cur == nil {
+				cur = &incidentSpan{start: p.t, worst: p.value}
+			}
+			cur.status = "bad"
+			cur.add(p)
+			continue
+		}
+
+		if cur != nil {
+			cur.end = p.t
+			spans = append(spans, *cur)
+			cur = nil
+		}
+	}
+
+	if len(pts) > 0 {
+		last := pts[len(pts)-1]
+
+		if now.Sub(last.t) > lateAfter {
+			if cur == nil {
+				cur = &incidentSpan{start: last.t.Add(lateAfter), worst: last.value, status: "late"}
+			}
+			cur.status = worstStatus(cur.status, "late")
+		}
+	}
+
+	if cur != nil {
+		spans = append(spans, *cur)
+	}
+
+	return spans
+}
+
+// incidentScan finds incidents for field metrics or data latencies.  Only active devices and sites are scanned.
+type incidentScan struct {
+	table    string // the incident table e.g., field.incident
+	pkCol    string // the device or site pk column e.g., devicePK
+	pointSQL string // selects pk, typePK, time, value, lower, upper from $1 ordered by pk, typePK, time.
+	seedSQL  string // selects pk, typePK, time, value, lower, upper for the last value before $1 for each metric.
+}
+
+var fieldIncidentScan = incidentScan{
+	table: "field.incident",
+	pkCol: "devicePK",
+	pointSQL: `SELECT devicePK, typePK, time, value, COALESCE(lower, 0), COALESCE(upper, 0)
+		FROM field.metric LEFT OUTER JOIN field.threshold USING (devicePK, typePK)
+		WHERE time >= $1 AND devicePK IN (SELECT devicePK FROM field.device WHERE state = 'active')
+		ORDER BY devicePK, typePK, time`,
+	seedSQL: `SELECT s.devicePK, s.typePK, p.time, p.value, s.lower, s.upper
+		FROM field.metric_summary s CROSS JOIN LATERAL (SELECT time, value FROM field.metric
+			WHERE devicePK = s.devicePK AND typePK = s.typePK AND time < $1
+			ORDER BY rate_limit DESC LIMIT 1) p
+		WHERE s.devicePK IN (SELECT devicePK FROM field.device WHERE state = 'active')`,
+}
+
+var dataIncidentScan = incidentScan{
+	table: "data.incident",
+	pkCol: "sitePK",
+	pointSQL: `SELECT sitePK, typePK, time, mean, COALESCE(lower, 0), COALESCE(upper, 0)
+		FROM data.latency LEFT OUTER JOIN data.latency_threshold USING (sitePK, typePK)
+		WHERE time >= $1 AND sitePK IN (SELECT sitePK FROM data.site WHERE state = 'active')
+		ORDER BY sitePK, typePK, time`,
+	seedSQL: `SELECT s.sitePK, s.typePK, p.time, p.mean, s.lower, s.upper
+		FROM data.latency_summary s CROSS JOIN LATERAL (SELECT time, mean FROM data.latency
+			WHERE sitePK = s.sitePK AND typePK = s.typePK AND time < $1
+			ORDER BY rate_limit DESC LIMIT 1) p
+		WHERE s.sitePK IN (SELECT sitePK FROM data.site WHERE state = 'active')`,
+}
+
+type incidentKey struct {
+	pk, typePK int
+}
+
+// incidentStart identifies an incident; the metric and the start time in Unix seconds.
+type incidentStart struct {
+	incidentKey
+	start int64
+}
+
+// storedIncident is an incident in the DB that may be extended by a scan.
+type storedIncident struct {
+	incidentPK int
+	start      time.Time
+	end        pq.NullTime
+	worst      int32
+	status     string
+}
+
+func incidentsTimed() {
+	ticker := time.NewTicker(time.Minute * 5).C
+	for {
+		select {
+		case <-ticker:
+			if err := scanIncidents(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func scanIncidents() error {
+	now := time.Now().UTC()
+
+	if err := fieldIncidentScan.scan(now); err != nil {
+		return err
+	}
+
+	return dataIncidentScan.scan(now)
+}
+
+/*
+scan recomputes incidents from now - incidentWindow.  Each metric is seeded with its last value before
+the window so that late periods that started before the window are found.  Incidents that started
+before the window and are ongoing, or ended in the window, are extended or closed.  Other incidents
+are updated in place, keyed on the metric and start time, so that incidentPK doesn't change between
+scans.  Incidents that started in the window and are no longer found are deleted.
+
+The values in the window are read one metric at a time.
+*/
+func (s incidentScan) scan(now time.Time) error {
+	from := now.Add(-incidentWindow)
+
+	seeds, err := s.seeds(from)
+	if err != nil {
+		return err
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = s.scanTx(txn, seeds, from, now); err != nil {
+		txn.Rollback()
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// seeds returns the last value before from for each metric.
+func (s incidentScan) seeds(from time.Time) (map[incidentKey]incidentPoint, error) {
+	rows, err := db.Query(s.seedSQL, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seeds := make(map[incidentKey]incidentPoint)
+
+	for rows.Next() {
+		var k incidentKey
+		var p incidentPoint
+
+		if err = rows.Scan(&k.pk, &k.typePK, &p.t, &p.value, &p.lower, &p.upper); err != nil {
+			return nil, err
+		}
+
+		seeds[k] = p
+	}
+
+	return seeds, rows.Err()
+}
+
+func (s incidentScan) scanTx(txn *sql.Tx, seeds map[incidentKey]incidentPoint, from, now time.Time) error {
+	prev := make(map[incidentKey]storedIncident)
+
+	rows, err := txn.Query(`SELECT incidentPK, `+s.pkCol+`, typePK, startTime, endTime, worst, status FROM `+s.table+`
+		WHERE startTime < $1 AND (endTime IS NULL OR endTime >= $1)`, from)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var k incidentKey
+		var i storedIncident
+
+		if err = rows.Scan(&i.incidentPK, &k.pk, &k.typePK, &i.start, &i.end, &i.worst, &i.status); err != nil {
+			rows.Close()
+			return err
+		}
+
+		prev[k] = i
+	}
+
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+
+	rows.Close()
+
+	// incidents in the window that are not found again are deleted.
+	stale := make(map[incidentStart]int)
+
+	if rows, err = txn.Query(`SELECT incidentPK, `+s.pkCol+`, typePK, startTime FROM `+s.table+`
+		WHERE startTime >= $1`, from); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var k incidentStart
+		var pk int
+		var t time.Time
+
+		if err = rows.Scan(&pk, &k.pk, &k.typePK, &t); err != nil {
+			rows.Close()
+			return err
+		}
+
+		k.start = t.Unix()
+		stale[k] = pk
+	}
+
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+
+	rows.Close()
+
+	metric := func(k incidentKey, p []incidentPoint) error {
+		if seed, ok := seeds[k]; ok {
+			p = append([]incidentPoint{seed}, p...)
+			delete(seeds, k)
+		}
+
+		spans := incidentSpans(p, now)
+
+		if i, ok := prev[k]; ok {
+			if len(spans) > 0 && (spans[0].start.Before(from) || spans[0].start.Equal(p[0].t)) {
+				// the incident continues into the window.
+				sp := spans[0]
+				spans = spans[1:]
+
+				last := p[len(p)-1]
+				e := incidentPoint{value: i.worst, lower: last.lower, upper: last.upper}.excess()
+
+				if sp.excess > e {
+					i.worst = sp.worst
+				}
+
+				i.status = worstStatus(i.status, sp.status)
+				i.end = pq.NullTime{Time: sp.end, Valid: !sp.end.IsZero()}
+			} else if !i.end.Valid {
+				// the incident ended at the first good value.
+				for _, v := range p {
+					if v.t.After(i.start) && v.excess() == 0 {
+						i.end = pq.NullTime{Time: v.t, Valid: true}
+						break
+					}
+				}
+			}
+
+			if _, err := txn.Exec(`UPDATE `+s.table+` SET endTime = $2, worst = $3, status = $4 WHERE incidentPK = $1`,
+				i.incidentPK, i.end, i.worst, i.status); err != nil {
+				return err
+			}
+		}
+
+		for _, sp := range spans {
+			delete(stale, incidentStart{incidentKey: k, start: sp.start.Unix()})
+
+			if err := s.save(txn, k, sp); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// rows are read with db, not txn, so that the incidents can be saved while reading them.
+	if rows, err = db.Query(s.pointSQL, from); err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var k incidentKey
+	var pts []incidentPoint
+
+	for rows.Next() {
+		var n incidentKey
+		var p incidentPoint
+
+		if err = rows.Scan(&n.pk, &n.typePK, &p.t, &p.value, &p.lower, &p.upper); err != nil {
+			return err
+		}
+
+		if len(pts) > 0 && n != k {
+			if err = metric(k, pts); err != nil {
+				return err
+			}
+			pts = pts[:0]
+		}
+
+		k = n
+		pts = append(pts, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(pts) > 0 {
+		if err = metric(k, pts); err != nil {
+			return err
+		}
+	}
+
+	// metrics with no values in the window.
+	for k, p := range seeds {
+		if err = metric(k, []incidentPoint{p}); err != nil {
+			return err
+		}
+	}
+
+	for _, pk := range stale {
+		if _, err = txn.Exec(`DELETE FROM `+s.table+` WHERE incidentPK = $1`, pk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// save updates, or adds, the incident for the metric k that starts at sp.start.
+func (s incidentScan) save(txn *sql.Tx, k incidentKey, sp incidentSpan) error {
+	end := pq.NullTime{Time: sp.end, Valid: !sp.end.IsZero()}
+
+	r, err := txn.Exec(`UPDATE `+s.table+` SET endTime = $4, worst = $5, status = $6
+		WHERE `+s.pkCol+` = $1 AND typePK = $2 AND startTime = $3`,
+		k.pk, k.typePK, sp.start, end, sp.worst, sp.status)
+	if err != nil {
+		return err
+	}
+
+	if n, err := r.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	_, err = txn.Exec(`INSERT INTO `+s.table+`(`+s.pkCol+`, typePK, startTime, endTime, worst, status)
+		VALUES($1, $2, $3, $4, $5, $6)`,
+		k.pk, k.typePK, sp.start, end, sp.worst, sp.status)
+
+	return err
+}
+
+// incident reads incidents and groups those that overlap in time and share a device, site, or tag.
+type incident struct {
+	result []*mtrpb.Incident
+}
+
+// read reads incidents that were ongoing since the since query param (RFC3339, default 7 days ago).
+// The optional deviceID, siteID, and typeID query params select incidents.
+func (n *incident) read(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{"deviceID", "siteID", "typeID", "since"}); !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	since := time.Now().UTC().Add(time.Hour * -24 * 7)
+
+	if v.Get("since") != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v.Get("since")); err != nil {
+			return weft.BadRequest("invalid since")
+		}
+	}
+
+	if v.Get("deviceID") != "" && v.Get("siteID") != "" {
+		return weft.BadRequest("only one of deviceID or siteID")
+	}
+
+	now := time.Now().UTC()
+
+	if v.Get("siteID") == "" {
+		if err := n.load(`SELECT deviceID, '', typeID, startTime, endTime, worst, status,
+			COALESCE((SELECT string_agg(tag, ',' ORDER BY tag) FROM field.metric_tag JOIN mtr.tag USING (tagPK)
+			WHERE devicePK = i.devicePK AND typePK = i.typePK), '')
+			FROM field.incident i JOIN field.device USING (devicePK) JOIN field.type USING (typePK)
+			WHERE (endTime IS NULL OR endTime >= $1)
+			AND ($2 = '' OR deviceID = $2) AND ($3 = '' OR typeID = $3)`,
+			now, since, v.Get("deviceID"), v.Get("typeID")); err != nil {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	if v.Get("deviceID") == "" {
+		if err := n.load(`SELECT '', siteID, typeID, startTime, endTime, worst, status,
+			COALESCE((SELECT string_agg(tag, ',' ORDER BY tag) FROM data.latency_tag JOIN mtr.tag USING (tagPK)
+			WHERE sitePK = i.sitePK AND typePK = i.typePK), '')
+			FROM data.incident i JOIN data.site USING (sitePK) JOIN data.type USING (typePK)
+			WHERE (endTime IS NULL OR endTime >= $1)
+			AND ($2 = '' OR siteID = $2) AND ($3 = '' OR typeID = $3)`,
+			now, since, v.Get("siteID"), v.Get("typeID")); err != nil {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	sort.Sort(incidentsByStart(n.result))
+
+	groupIncidents(n.result)
+
+	return &weft.StatusOK
+}
+
+func (n *incident) load(query string, now time.Time, args ...interface{}) error {
+	rows, err := dbR.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i mtrpb.Incident
+		var start time.Time
+		var end pq.NullTime
+		var tags string
+
+		if err = rows.Scan(&i.DeviceID, &i.SiteID, &i.TypeID, &start, &end, &i.Worst, &i.Status, &tags); err != nil {
+			return err
+		}
+
+		i.Start = start.Unix()
+		i.Duration = int64(now.Sub(start).Seconds())
+
+		if end.Valid {
+			i.End = end.Time.Unix()
+			i.Duration = i.End - i.Start
+		}
+
+		i.Tags = splitTags(tags)
+
+		n.result = append(n.result, &i)
+	}
+
+	return rows.Err()
+}
+
+// incidentsByStart sorts incidents newest first.
+type incidentsByStart []*mtrpb.Incident
+
+func (a incidentsByStart) Len() int      { return len(a) }
+func (a incidentsByStart) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a incidentsByStart) Less(i, j int) bool {
+	if a[i].Start != a[j].Start {
+		return a[i].Start > a[j].Start
+	}
+	return a[i].DeviceID+a[i].SiteID+a[i].TypeID < a[j].DeviceID+a[j].SiteID+a[j].TypeID
+}
+
+// related returns true if incidents a and b overlap in time and share a device, site, or tag.
+func related(a, b *mtrpb.Incident) bool {
+	if a.Start > b.Start+b.Duration || b.Start > a.Start+a.Duration {
+		return false
+	}
+
+	if (a.DeviceID != "" && a.DeviceID == b.DeviceID) || (a.SiteID != "" && a.SiteID == b.SiteID) {
+		return true
+	}
+
+	for _, x := range a.Tags {
+		for _, y := range b.Tags {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// groupIncidents sets the group for incidents.  Related incidents, directly or through
+// other incidents, have the same group.  Groups are numbered from 1 in the order of incidents.
+func groupIncidents(incidents []*mtrpb.Incident) {
+	parent := make([]int, len(incidents))
+
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var root func(i int) int
+
+	root = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	for i := range incidents {
+		for j := i + 1; j < len(incidents); j++ {
+			if related(incidents[i], incidents[j]) {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+
+	group := make(map[int]int32)
+
+	for i, v := range incidents {
+		r := root(i)
+
+		if _, ok := group[r]; !ok {
+			group[r] = int32(len(group) + 1)
+		}
+
+		v.Group = group[r]
+	}
+}
+
+func (n *incident) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := n.read(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&mtrpb.IncidentResult{Result: n.result})
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type incidentJSON struct {
+	DeviceID string `json:",omitempty"`
+	SiteID   string `json:",omitempty"`
+	TypeID   string
+	Start    string
+	End      string `json:",omitempty"`
+	Duration int64
+	Worst    int32
+	Status   string
+	Group    int32
+	Tags     []string
+}
+
+func (n *incident) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := n.read(r); !res.Ok {
+		return res
+	}
+
+	j := make([]incidentJSON, len(n.result))
+
+	for k, v := range n.result {
+		j[k] = incidentJSON{
+			DeviceID: v.DeviceID,
+			SiteID:   v.SiteID,
+			TypeID:   v.TypeID,
+			Start:    time.Unix(v.Start, 0).UTC().Format(time.RFC3339),
+			Duration: v.Duration,
+			Worst:    v.Worst,
+			Status:   v.Status,
+			Group:    v.Group,
+			Tags:     v.Tags,
+		}
+
+		if v.End != 0 {
+			j[k].End = time.Unix(v.End, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lifecycleStates are the lifecycle states for field devices and data sites.
+// Only active devices and sites are included in summaries and maps unless the
+// state query parameter is used.
+var lifecycleStates = map[string]bool{
+	"planned":        true,
+	"active":         true,
+	"maintenance":    true,
+	"decommissioned": true,
+}
+
+// stateFilter selects devices or sites by lifecycle state.  states is empty for all states.
+type stateFilter struct {
+	states []string
+}
+
+// read reads the optional state query parameter.  It can be a comma separated list
+// of states or all.  The default is active.
+func (s *stateFilter) read(r *http.Request) *weft.Result {
+	switch v := r.URL.Query().Get("state"); v {
+	case "":
+		s.states = []string{"active"}
+	case "all":
+		s.states = nil
+	default:
+		for _, st := range strings.Split(v, ",") {
+			if !lifecycleStates[st] {
+				return weft.BadRequest("invalid state " + st)
+			}
+			s.states = append(s.states, st)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+// sql returns an SQL boolean expression that is true when col is one of the
+// states.  The states are appended to args.
+func (s *stateFilter) sql(col string, args *[]interface{}) string {
+	if len(s.states) == 0 {
+		return "TRUE"
+	}
+
+	var p []string
+
+	for _, st := range s.states {
+		*args = append(*args, st)
+		p = append(p, "$"+strconv.Itoa(len(*args)))
+	}
+
+	return col + " IN (" + strings.Join(p, ", ") + ")"
+}
+
+// lifecycle is the lifecycle state for a field device or data site.
+// Changes are logged with the time and the user that made the change.
+type lifecycle struct {
+	table    string // the device or site table e.g., field.device
+	logTable string // the state change table e.g., field.device_state
+	pkCol    string // the pk column e.g., devicePK
+	idParam  string // the query parameter for the device or site e.g., deviceID
+	pk       int
+}
+
+func newDeviceLifecycle() lifecycle {
+	return lifecycle{table: "field.device", logTable: "field.device_state", pkCol: "devicePK", idParam: "deviceID"}
+}
+
+func newSiteLifecycle() lifecycle {
+	return lifecycle{table: "data.site", logTable: "data.site_state", pkCol: "sitePK", idParam: "siteID"}
+}
+
+func (l *lifecycle) loadPK(r *http.Request) *weft.Result {
+	var res *weft.Result
+
+	switch l.idParam {
+	case "deviceID":
+		l.pk, res = fieldDevicePK(r.URL.Query().Get("deviceID"))
+	default:
+		var d dataSite
+		res = d.loadPK(r)
+		l.pk = d.sitePK
+	}
+
+	return res
+}
+
+// changedBy returns the changedBy query parameter; the person making a change.  The API has one
+// shared write user so the basic auth user can't be used to tell who made a change.
+func changedBy(r *http.Request) (string, *weft.Result) {
+	c := strings.TrimSpace(r.URL.Query().Get("changedBy"))
+	if c == "" {
+		return "", weft.BadRequest("changedBy is required")
+	}
+
+	return c, &weft.StatusOK
+}
+
+// save changes the state.  The change is logged, with changedBy, if the state is different to the current state.
+func (l *lifecycle) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{l.idParam, "state", "changedBy"}, []string{}); !res.Ok {
+		return res
+	}
+
+	user, res := changedBy(r)
+	if !res.Ok {
+		return res
+	}
+
+	state := r.URL.Query().Get("state")
+
+	if !lifecycleStates[state] {
+		return weft.BadRequest("invalid state " + state)
+	}
+
+	if res := l.loadPK(r); !res.Ok {
+		return res
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	u, err := txn.Exec(`UPDATE `+l.table+` SET state = $2 WHERE `+l.pkCol+` = $1 AND state <> $2`, l.pk, state)
+	if err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	n, err := u.RowsAffected()
+	if err != nil {
+		txn.Rollback()
+		return weft.InternalServerError(err)
+	}
+
+	if n == 1 {
+		if _, err = txn.Exec(`INSERT INTO `+l.logTable+`(`+l.pkCol+`, state, time, changedBy) VALUES($1, $2, now(), $3)`,
+			l.pk, state, user); err != nil {
+			txn.Rollback()
+			return weft.InternalServerError(err)
+		}
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// history returns the state changes oldest first.
+func (l *lifecycle) history(r *http.Request) ([]*mtrpb.LifecycleState, *weft.Result) {
+	if res := weft.CheckQuery(r, []string{l.idParam}, []string{}); !res.Ok {
+		return nil, res
+	}
+
+	if res := l.loadPK(r); !res.Ok {
+		return nil, res
+	}
+
+	rows, err := dbR.Query(`SELECT state, time, changedBy FROM `+l.logTable+`
+		WHERE `+l.pkCol+` = $1 ORDER BY time ASC, statePK ASC`, l.pk)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var s []*mtrpb.LifecycleState
+
+	for rows.Next() {
+		var v mtrpb.LifecycleState
+		var t time.Time
+
+		if err = rows.Scan(&v.State, &t, &v.ChangedBy); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		v.Seconds = t.Unix()
+
+		s = append(s, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return s, &weft.StatusOK
+}
+
+func (l *lifecycle) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var lr mtrpb.LifecycleStateResult
+	var res *weft.Result
+
+	if lr.Result, res = l.history(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&lr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type lifecycleStateJSON struct {
+	State     string
+	Time      string
+	ChangedBy string
+}
+
+func (l *lifecycle) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	s, res := l.history(r)
+	if !res.Ok {
+		return res
+	}
+
+	j := make([]lifecycleStateJSON, len(s))
+
+	for i, v := range s {
+		j[i] = lifecycleStateJSON{
+			State:     v.State,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		}
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/map180"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+The map region is set with MTR_MAP_REGION (default newzealand).  It must be a region
+known to map180.
+
+Named bbox presets for the SVG maps can be added (or the defaults replaced) with
+MTR_MAP_BBOXES.  Presets are ';' separated name=llx,lly,urx,ury e.g.,
+
+    MTR_MAP_BBOXES=Tonga=-176.5,-22.5,-173.5,-15.5;Samoa=-173,-14.5,-171,-13
+
+A preset name can be used anywhere a bbox query parameter is accepted.
+*/
+
+type mapBbox struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Bbox  string `json:"bbox"`
+}
+
+// mapBboxes are the map presets in display order.
+var mapBboxes = []mapBbox{
+	{Name: "NewZealand", Title: "New Zealand", Bbox: "165,-48,179,-34"},
+	{Name: "NewZealandChathamIsland", Title: "New Zealand, Chathams", Bbox: "165,-48,-175,-34"},
+	{Name: "NewZealandRegion", Title: "New Zealand, Kermadecs, Chathams", Bbox: "165,-48,-175,-28"},
+	{Name: "Kermadecs", Title: "Kermadec Islands", Bbox: "-179.5,-32,-177.5,-29"},
+	{Name: "ChathamIsland", Title: "Chatham Islands", Bbox: "-177.2,-44.5,-175.8,-43.5"},
+	{Name: "Pacific", Title: "South West Pacific", Bbox: "160,-48,-165,-10"},
+}
+
+func init() {
+	if err := mapBboxRules(os.Getenv("MTR_MAP_BBOXES")); err != nil {
+		log.Printf("ERROR: problem with map bbox config: %s", err)
+	}
+}
+
+// mapRegion returns the map180 region from MTR_MAP_REGION.
+func mapRegion() map180.Region {
+	if s := os.Getenv("MTR_MAP_REGION"); s != "" {
+		return map180.Region(s)
+	}
+
+	return map180.NewZealand
+}
+
+// mapBboxRules adds the presets in s to mapBboxes.  A preset with the same name as
+// an existing preset replaces it.
+func mapBboxRules(s string) error {
+	for _, p := range strings.Split(s, ";") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.Contains(kv[0], ",") {
+			return fmt.Errorf("invalid bbox preset: %s", p)
+		}
+
+		c := strings.Split(kv[1], ",")
+		if len(c) != 4 {
+			return fmt.Errorf("invalid bbox for preset %s: %s", kv[0], kv[1])
+		}
+
+		for _, v := range c {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Errorf("invalid bbox for preset %s: %s", kv[0], kv[1])
+			}
+		}
+
+		m := mapBbox{Name: kv[0], Title: kv[0], Bbox: kv[1]}
+
+		var found bool
+
+		for i := range mapBboxes {
+			if mapBboxes[i].Name == m.Name {
+				mapBboxes[i] = m
+				found = true
+			}
+		}
+
+		if !found {
+			mapBboxes = append(mapBboxes, m)
+		}
+	}
+
+	return nil
+}
+
+// mapBboxLookup returns the bbox for the preset name.  If name is not a preset it
+// is returned unchanged.
+func mapBboxLookup(name string) string {
+	for _, m := range mapBboxes {
+		if m.Name == name {
+			return m.Bbox
+		}
+	}
+
+	return name
+}
+
+func (m *mapBbox) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	var mr mtrpb.MapBboxResult
+
+	for _, v := range mapBboxes {
+		mr.Result = append(mr.Result, &mtrpb.MapBbox{Name: v.Name, Title: v.Title, Bbox: v.Bbox})
+	}
+
+	by, err := proto.Marshal(&mr)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+func (m *mapBbox) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	by, err := json.Marshal(mapBboxes)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
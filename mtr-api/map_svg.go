@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/GeoNet/map180"
+	"html"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// mtrUiUrl is used to link map markers to the mtr-ui plot pages.
+// If it's not set the links are relative to the server root.
+var mtrUiUrl = strings.TrimSuffix(os.Getenv("MTR_UI_URL"), "/")
+
+// markers closer than this (pixels) are drawn as a cluster.
+const clusterDistance = 10.0
+
+type point struct {
+	latitude, longitude float64
+	x, y                float64
+	status              string // good, bad, late, unknown, impacted
+	title               string // the tooltip for the marker
+	link                string // the link for the marker
+}
+
+// project converts the EPSG3857 x,y for p to the SVG coordinates for raw.
+// Does not handle crossing the equator.
+func (p *point) project(raw map180.Raw) {
+	switch {
+	case raw.CrossesCentral && p.longitude > -180.0 && p.longitude < 0.0:
+		p.x = (p.x + map180.Width3857 - raw.LLX) * raw.DX
+		p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
+	case p.longitude > 0.0:
+		p.x = (p.x - math.Abs(raw.XShift)) * raw.DX
+		p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
+	default:
+		p.x = (p.x + math.Abs(raw.XShift)) * raw.DX
+		p.y = (p.y - math.Abs(raw.YShift)) * raw.DX
+	}
+}
+
+// plotLink returns a link to the mtr-ui plot page for path e.g., /field/plot
+func plotLink(path string, v url.Values) string {
+	return mtrUiUrl + path + "?" + v.Encode()
+}
+
+// age returns the time since t for display e.g., 3m20s ago
+func age(t time.Time) string {
+	return time.Since(t).Truncate(time.Second).String() + " ago"
+}
+
+type statusStyle struct {
+	status, label, colour string
+	r                     int
+}
+
+// statusStyles in the order they are drawn.  Worse status is drawn on top.
+var statusStyles = []statusStyle{
+	{status: "unknown", label: "no threshold", colour: "#377eb8", r: 5}, // blueish
+	{status: "good", label: "good", colour: "#4daf4a", r: 5},            // greenish
+	{status: "impacted", label: "impacted", colour: "#ff7f00", r: 5},    // orange
+	{status: "bad", label: "bad", colour: "#e41a1c", r: 6},              // red
+	{status: "late", label: "late", colour: "#984ea3", r: 6},            // purple
+}
+
+// cluster is a group of points that would overlap on the map.
+type cluster struct {
+	x, y   float64
+	status string
+	points []point
+}
+
+// clusters groups pts that are within clusterDistance pixels of the first point
+// in a cluster.  The cluster status is the worst status of its points.
+func clusters(pts []point) []cluster {
+	var c []cluster
+
+	for _, p := range pts {
+		var found bool
+
+		for i := range c {
+			if math.Hypot(c[i].x-p.x, c[i].y-p.y) < clusterDistance {
+				c[i].points = append(c[i].points, p)
+				c[i].status = worstStatus(c[i].status, p.status)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			c = append(c, cluster{x: p.x, y: p.y, status: p.status, points: []point{p}})
+		}
+	}
+
+	return c
+}
+
+// statusMapSVG writes an SVG map of points coloured by status to b.  Each marker has a
+// tooltip and a link.  Overlapping markers are drawn as a cluster with a count.
+// A legend shows the count of points for each status.
+func statusMapSVG(raw map180.Raw, pts []point, b *bytes.Buffer) {
+	b.WriteString(`<?xml version="1.0"?>`)
+	b.WriteString(fmt.Sprintf("<svg  viewBox=\"0 0 %d %d\"  xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\">",
+		raw.Width, raw.Height))
+	b.WriteString(fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" style=\"fill: azure\"/>", raw.Width, raw.Height))
+	b.WriteString(fmt.Sprintf("<path style=\"fill: wheat; stroke-width: 1; stroke-linejoin: round; stroke: lightslategrey\" d=\"%s\"/>", raw.Land))
+	b.WriteString(fmt.Sprintf("<path style=\"fill: azure; stroke-width: 1; stroke-linejoin: round; stroke: lightslategrey\" d=\"%s\"/>", raw.Lakes))
+
+	c := clusters(pts)
+	count := make(map[string]int)
+
+	for _, p := range pts {
+		count[p.status]++
+	}
+
+	for _, s := range statusStyles {
+		b.WriteString(fmt.Sprintf("<g style=\"stroke: %s; fill: %s; \">", s.colour, s.colour))
+		for _, v := range c {
+			if v.status != s.status {
+				continue
+			}
+
+			if len(v.points) == 1 {
+				p := v.points[0]
+				b.WriteString(fmt.Sprintf("<a xlink:href=\"%s\" target=\"_top\"><circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"><title>%s</title></circle></a>",
+					html.EscapeString(p.link), p.x, p.y, s.r, html.EscapeString(p.title)))
+				continue
+			}
+
+			var t []string
+			for _, p := range v.points {
+				t = append(t, p.title)
+			}
+
+			b.WriteString(fmt.Sprintf("<g><title>%s</title><circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\"/>",
+				html.EscapeString(strings.Join(t, "\n")), v.x, v.y, s.r+4))
+			b.WriteString(fmt.Sprintf("<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\" font-family=\"Arial\" font-size=\"9\" style=\"stroke: none; fill: white\">%d</text></g>",
+				v.x, v.y+3.0, len(v.points)))
+		}
+		b.WriteString("</g>")
+	}
+
+	// legend
+	h := len(statusStyles)*18 + 8
+	b.WriteString(fmt.Sprintf("<g transform=\"translate(10,%d)\">", raw.Height-h-10))
+	b.WriteString(fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"130\" height=\"%d\" style=\"fill: white; fill-opacity: 0.8; stroke: lightslategrey\"/>", h))
+	for i, s := range statusStyles {
+		y := i*18 + 14
+		b.WriteString(fmt.Sprintf("<circle cx=\"12\" cy=\"%d\" r=\"5\" style=\"stroke: %s; fill: %s\"/>", y, s.colour, s.colour))
+		b.WriteString(fmt.Sprintf("<text x=\"24\" y=\"%d\" font-family=\"Arial\" font-size=\"12\">%s (%d)</text>", y+4, s.label, count[s.status]))
+	}
+	b.WriteString("</g>")
+
+	b.WriteString("</svg>")
+}
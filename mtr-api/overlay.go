@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/GeoNet/mtr/ts"
+	"github.com/GeoNet/weft"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// overlayKind is the tables for overlay plots of field metrics or data latencies.
+type overlayKind struct {
+	values     string // the table of values e.g., field.metric
+	value      string // the column for the value e.g., value
+	ids        string // the table of devices or sites e.g., field.device
+	pkCol      string // the device or site pk column e.g., devicePK
+	idCol      string // the device or site id column e.g., deviceID
+	tags       string // the tag table e.g., field.metric_tag
+	thresholds string // the threshold table e.g., field.threshold
+}
+
+var fieldOverlay = overlayKind{values: "field.metric", value: "value", ids: "field.device", pkCol: "devicePK", idCol: "deviceID",
+	tags: "field.metric_tag", thresholds: "field.threshold"}
+var dataOverlay = overlayKind{values: "data.latency", value: "mean", ids: "data.site", pkCol: "sitePK", idCol: "siteID",
+	tags: "data.latency_tag", thresholds: "data.latency_threshold"}
+
+// overlaySeries is the values for one device or site.
+type overlaySeries struct {
+	id           string
+	pts          []ts.Point
+	lower, upper int
+	threshold    bool // true if lower and upper are set for the metric
+}
+
+// unknown returns a bad request naming the ids (comma separated) that are not devices or sites.
+func (k overlayKind) unknown(ids string) *weft.Result {
+	rows, err := dbR.Query(`SELECT id FROM unnest(string_to_array($1, ',')) AS id
+		WHERE NOT EXISTS (SELECT 1 FROM `+k.ids+` WHERE `+k.idCol+` = id)`, ids)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var u []string
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		u = append(u, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if len(u) > 0 {
+		return weft.BadRequest("unknown " + k.idCol + " " + strings.Join(u, ", "))
+	}
+
+	return &weft.StatusOK
+}
+
+// series returns the averaged values at resolution for the type of metric for each of ids (comma separated) and
+// each device or site with the metric tagged with tag.  The series are in id order.  It is a bad request if
+// any of ids are unknown.
+func (k overlayKind) series(typePK int, ids, tag, resolution string, scale float64) ([]overlaySeries, *weft.Result) {
+	bucket, ok := resolutionBucket[resolution]
+	if !ok {
+		return nil, weft.BadRequest("invalid resolution")
+	}
+
+	if ids != "" {
+		if res := k.unknown(ids); !res.Ok {
+			return nil, res
+		}
+	}
+
+	rows, err := dbR.Query(`SELECT i.`+k.idCol+`, `+bucket.sql+` AS t, avg(`+k.value+`),
+		th.lower, th.upper
+		FROM `+k.values+` m JOIN `+k.ids+` i USING (`+k.pkCol+`)
+		LEFT OUTER JOIN `+k.thresholds+` th ON (th.`+k.pkCol+` = m.`+k.pkCol+` AND th.typePK = m.typePK)
+		WHERE m.typePK = $1 AND time > now() - $2 * interval '1 second'
+		AND (i.`+k.idCol+` = ANY(string_to_array($3, ','))
+			OR ($4 <> '' AND EXISTS (SELECT 1 FROM `+k.tags+` JOIN mtr.tag USING (tagPK)
+				WHERE `+k.pkCol+` = m.`+k.pkCol+` AND typePK = m.typePK AND tag = $4)))
+		GROUP BY i.`+k.idCol+`, t, th.lower, th.upper
+		ORDER BY i.`+k.idCol+`, t`, typePK, bucket.window.Seconds(), ids, tag)
+	if err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var l []overlaySeries
+
+	for rows.Next() {
+		var id string
+		var t time.Time
+		var avg float64
+		var lower, upper *int
+
+		if err = rows.Scan(&id, &t, &avg, &lower, &upper); err != nil {
+			return nil, weft.InternalServerError(err)
+		}
+
+		if len(l) == 0 || l[len(l)-1].id != id {
+			s := overlaySeries{id: id}
+
+			if lower != nil && upper != nil && !(*lower == 0 && *upper == 0) {
+				s.lower, s.upper, s.threshold = *lower, *upper, true
+			}
+
+			l = append(l, s)
+		}
+
+		l[len(l)-1].pts = append(l[len(l)-1].pts, ts.Point{DateTime: t, Value: avg * scale})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, weft.InternalServerError(err)
+	}
+
+	return l, &weft.StatusOK
+}
+
+// commonThreshold returns the threshold for the series if they all have the same threshold.  differ is
+// true if at least one of the series has a threshold and they are not all the same.
+func commonThreshold(l []overlaySeries) (lower, upper int, ok, differ bool) {
+	var set bool
+
+	ok = len(l) > 0
+
+	for i, s := range l {
+		set = set || s.threshold
+
+		if !s.threshold || (i > 0 && (s.lower != l[0].lower || s.upper != l[0].upper)) {
+			ok = false
+		}
+	}
+
+	if !ok {
+		return 0, 0, false, set
+	}
+
+	return l[0].lower, l[0].upper, true, false
+}
+
+/*
+overlay draws the series on one plot with a colour and label for each device or site.  The threshold
+band is only drawn if the thresholds all agree.  The sub title notes when they differ.
+*/
+func overlay(l []overlaySeries, title, subTitle, unit string, scale float64, resolution string, b *bytes.Buffer) *weft.Result {
+	var p ts.Plot
+
+	bucket := resolutionBucket[resolution]
+
+	p.SetUnit(unit)
+	p.SetTitle(title)
+	p.SetXAxis(time.Now().UTC().Add(-bucket.window), time.Now().UTC())
+	p.SetXLabel(bucket.label)
+
+	lower, upper, ok, differ := commonThreshold(l)
+
+	switch {
+	case ok:
+		p.SetThreshold(float64(lower)*scale, float64(upper)*scale)
+	case differ:
+		subTitle += " (thresholds differ)"
+	}
+
+	p.SetSubTitle(subTitle)
+
+	if len(l) == 0 {
+		// there is nothing to auto range the y axis on.
+		p.SetYAxis(0, 1)
+	}
+
+	var labels ts.Labels
+
+	for i, s := range l {
+		c := colours[i%len(colours)]
+
+		p.AddSeries(ts.Series{Colour: c, Points: s.pts})
+		labels = append(labels, ts.Label{Colour: c, Label: s.id})
+	}
+
+	p.SetLabels(labels)
+
+	if err := ts.Line.Draw(p, b); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// overlaySubTitle describes the devices or sites selected by ids and tag.
+func overlaySubTitle(name, ids, tag string) string {
+	var s []string
+
+	if ids != "" {
+		s = append(s, name+": "+strings.Replace(ids, ",", ", ", -1))
+	}
+
+	if tag != "" {
+		s = append(s, "Tag: "+tag)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+/*
+overlay draws the field metric for the comma separated deviceIDs and the devices with the metric tagged
+with tag on one plot.
+*/
+func (f *fieldMetric) overlay(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"typeID", "plot"}, []string{"deviceID", "tag", "resolution"}); !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	if v.Get("deviceID") == "" && v.Get("tag") == "" {
+		return weft.BadRequest("deviceID or tag is required")
+	}
+
+	var res *weft.Result
+
+	if f.fieldType, res = loadFieldType(v.Get("typeID")); !res.Ok {
+		return res
+	}
+
+	resolution := v.Get("resolution")
+	if resolution == "" {
+		resolution = "minute"
+	}
+
+	l, res := fieldOverlay.series(f.fieldType.typePK, v.Get("deviceID"), v.Get("tag"), resolution, f.fieldType.Scale)
+	if !res.Ok {
+		return res
+	}
+
+	if res = overlay(l, fmt.Sprintf("Devices - %s", strings.Title(f.fieldType.Name)), overlaySubTitle("Devices", v.Get("deviceID"), v.Get("tag")),
+		f.fieldType.Unit, f.fieldType.Scale, resolution, b); !res.Ok {
+		return res
+	}
+
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
+
+/*
+overlay draws the data latency for the comma separated siteIDs and the sites with the latency tagged
+with tag on one plot.
+*/
+func (d *dataLatency) overlay(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"typeID", "plot"}, []string{"siteID", "tag", "resolution"}); !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	if v.Get("siteID") == "" && v.Get("tag") == "" {
+		return weft.BadRequest("siteID or tag is required")
+	}
+
+	if res := d.dataType.load(r); !res.Ok {
+		return res
+	}
+
+	resolution := v.Get("resolution")
+	if resolution == "" {
+		resolution = "minute"
+	}
+
+	l, res := dataOverlay.series(d.dataType.typePK, v.Get("siteID"), v.Get("tag"), resolution, d.dataType.Scale)
+	if !res.Ok {
+		return res
+	}
+
+	if res = overlay(l, fmt.Sprintf("Sites - %s", strings.Title(d.dataType.Name)), overlaySubTitle("Sites", v.Get("siteID"), v.Get("tag")),
+		d.dataType.Unit, d.dataType.Scale, resolution, b); !res.Ok {
+		return res
+	}
+
+	h.Set("Content-Type", "image/svg+xml")
+
+	return &weft.StatusOK
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"github.com/GeoNet/map180"
 	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/mtr/ts"
 	wt "github.com/GeoNet/weft/wefttest"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -68,6 +78,42 @@ var routes = wt.Requests{
 	{ID: wt.L(), URL: "/field/device?deviceID=gps-taupoairport", Method: "DELETE"},
 	{ID: wt.L(), URL: "/field/device?deviceID=gps-taupoairport&modelID=Trimble+NetR9&latitude=-38.74270&longitude=176.08100", Method: "PUT"},
 
+	// Device lifecycle state.  Only active devices are included in summaries and maps unless
+	// the state query param is used.  Changes are logged with the time and user.
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=maintenance&changedBy=ops", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=active&changedBy=ops", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=retired&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-nodevice&state=active&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	// changedBy is required.
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=active", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=active&changedBy=", Method: "PUT", Status: http.StatusBadRequest},
+
+	// Device location history.  Moves are recorded with an effective time (default now).
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupoairport&latitude=-38.74270&longitude=176.08100&time=2015-05-01T00:00:00Z&changedBy=ops", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupoairport&latitude=-91&longitude=176.08100&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-nodevice&latitude=-38.7&longitude=176.1&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+
+	// Rename a device.  The device must exist and the new deviceID must be different.
+	{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-nodevice&newDeviceID=gps-taupo&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport&newDeviceID=gps-taupoairport&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	// changedBy is required.
+	{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport&newDeviceID=gps-taupo", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupoairport&latitude=-38.7&longitude=176.1", Method: "PUT", Status: http.StatusBadRequest},
+
+	// Device dependencies.  The device depends on the parent.
+	{ID: wt.L(), URL: "/field/device/dependency?deviceID=gps-taupoairport&parentID=gps-taupoairport", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/dependency?deviceID=gps-taupoairport&parentID=gps-nodevice", Method: "PUT", Status: http.StatusBadRequest},
+
+	// Device and model attributes.  Repeated PUT updates the value.  Well known keys are validated.
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=serial&value=5036K70213", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=installed&value=2015-05-14", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=installed&value=14-05-2015", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-nodevice&key=serial&value=1", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=owner&value=ops", Method: "DELETE"},
+	{ID: wt.L(), URL: "/field/model/attribute?modelID=Trimble+NetR9&key=manufacturer&value=Trimble", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/model/attribute?modelID=Trimble+NetR9&key=expectedMetrics&value=voltage,clock", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/model/attribute?modelID=Trimble+NetR9&key=expectedMetrics&value=voltage,notatype", Method: "PUT", Status: http.StatusBadRequest},
+
 	// Delete all metrics typeID for a device
 	{ID: wt.L(), URL: "/field/metric?deviceID=gps-taupoairport&typeID=voltage", Method: "DELETE"},
 
@@ -88,6 +134,9 @@ var routes = wt.Requests{
 	{ID: wt.L(), URL: "/field/metric/tag?deviceID=gps-taupoairport&typeID=voltage&tag=TAUP", Method: "PUT"},
 	{ID: wt.L(), URL: "/field/metric/tag?deviceID=gps-taupoairport&typeID=voltage&tag=LINZ", Method: "PUT"},
 
+	// Overlay plots for the devices with a tagged metric.
+	{ID: wt.L(), URL: "/field/metric?plot=overlay&typeID=voltage&tag=TAUP", Content: "image/svg+xml"},
+
 	// Delete a tag on a metric
 	{ID: wt.L(), URL: "/field/metric/tag?deviceID=gps-taupoairport&typeID=voltage&tag=LINZ", Method: "DELETE"},
 
@@ -109,6 +158,26 @@ var routes = wt.Requests{
 	// protobuf of all tagged field metrics
 	{ID: wt.L(), URL: "/field/metric/tag", Accept: "application/x-protobuf"},
 
+	// Tag namespaces.  Namespaced tags are namespace:value e.g., site:TAUP
+	// The namespace must exist before a namespaced tag can be created.
+	{ID: wt.L(), URL: "/tag/nonamespace:TAUP", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/namespace", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/tag?namespace=site", Accept: "application/x-protobuf"},
+
+	// Search metrics with a tag query.  AND, OR, NOT, parentheses, and * wildcards.
+	{ID: wt.L(), URL: "/search?query=TAUP+AND+NOT+LINZ", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/search?query=TAU*+OR+(DAGG+AND+NOT+FRED)", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/search?query=TAUP+AND", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/search?query=TAUP&state=all", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/search?query=TAUP&state=retired", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+
+	// Bulk add (PUT) or remove (DELETE) a tag for all metrics matching a selector.
+	// GET reports the changes without making them.
+	{ID: wt.L(), URL: "/bulk/tag?tag=LINZ&modelID=Trimble+NetR9", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/bulk/tag?tag=LINZ&devicePrefix=gps-&typeID=voltage", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/bulk/tag?tag=LINZ", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/bulk/tag?tag=LINZ&typeID=nope", Method: "PUT", Status: http.StatusBadRequest},
+
 	// Thresholds
 	// Create a threshold on a metric
 	{ID: wt.L(), URL: "/field/metric/threshold?deviceID=gps-taupoairport&typeID=voltage&lower=12000&upper=15000", Method: "PUT"},
@@ -120,13 +189,44 @@ var routes = wt.Requests{
 	{ID: wt.L(), URL: "/field/metric/threshold?deviceID=gps-taupoairport&typeID=voltage", Method: "DELETE"},
 	{ID: wt.L(), URL: "/field/metric/threshold?deviceID=gps-taupoairport&typeID=voltage&lower=12000&upper=45000", Method: "PUT"},
 
+	// Expected intervals for a type and a device.  The device interval overrides the type.
+	{ID: wt.L(), URL: "/field/metric/interval?typeID=voltage&seconds=300", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/metric/interval?typeID=voltage&seconds=600", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/metric/interval?deviceID=gps-taupoairport&typeID=voltage&seconds=60", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/metric/interval?deviceID=gps-taupoairport&typeID=conn&seconds=60", Method: "PUT"},
+	{ID: wt.L(), URL: "/field/metric/interval?deviceID=gps-taupoairport&typeID=conn", Method: "DELETE"},
+	{ID: wt.L(), URL: "/field/metric/interval?typeID=voltage&seconds=0", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/metric/interval?typeID=nope&seconds=60", Method: "PUT", Status: http.StatusBadRequest},
+
 	// GET requests
 	// Non specific Accept headers return svg.
 	// Model
 	{ID: wt.L(), URL: "/field/model", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/model", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/model/attribute?modelID=Trimble+NetR9", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/model/attribute?modelID=Trimble+NetR9", Accept: "application/x-protobuf"},
 
 	// Device
 	{ID: wt.L(), URL: "/field/device", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device?deviceID=gps-taupoairport", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device?state=all", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device?state=decommissioned", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupoairport", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupoairport", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device/dependency", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device/dependency", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device/dependency", Content: "image/svg+xml"},
+	{ID: wt.L(), URL: "/field/device?latitude=-38.7&longitude=176.1&radius=50", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/device?latitude=-38.7&longitude=176.1&nearest=5", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device?latitude=-38.7&longitude=176.1", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/device?latitude=-38.7&longitude=176.1&radius=50&nearest=5", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
 
 	// Metrics.  Resolution is optional on plots.  Resolution is fixed for sparks.
 	// Options for the plot parameter:
@@ -158,15 +258,50 @@ var routes = wt.Requests{
 	// Latest voltage metrics
 	{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/x-protobuf"},
 
+	// Latest metrics as GeoJSON
+	{ID: wt.L(), URL: "/field/metric/summary", Accept: "application/vnd.geo+json", Content: "application/vnd.geo+json"},
+	{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/vnd.geo+json", Content: "application/vnd.geo+json"},
+
 	// Thresholds
 	{ID: wt.L(), URL: "/field/metric/threshold", Accept: "application/json;version=1"},
 
 	// All field metric thresholds as protobuf
 	{ID: wt.L(), URL: "/field/metric/threshold", Accept: "application/x-protobuf"},
 
+	// Expected intervals and completeness
+	{ID: wt.L(), URL: "/field/metric/interval", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/metric/interval", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/metric/completeness", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/metric/completeness?deviceID=gps-taupoairport&typeID=voltage&hours=48", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/metric/completeness?hours=0", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+
+	// Forecasts
+	{ID: wt.L(), URL: "/field/metric/forecast", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/field/metric/forecast?deviceID=gps-taupoairport&typeID=voltage&days=14", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/metric/forecast?days=40", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/metric/summary?breachDays=30", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/metric/summary?breachDays=30", Accept: "application/vnd.geo+json", Content: "application/vnd.geo+json"},
+	{ID: wt.L(), URL: "/field/metric/summary?breachDays=soon", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/metric?deviceID=gps-taupoairport&typeID=voltage&forecast=true", Content: "image/svg+xml"},
+
+	// Overlay plots of a metric for several devices.
+	{ID: wt.L(), URL: "/field/metric?plot=overlay&typeID=voltage&deviceID=gps-taupoairport,gps-wgtn", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/metric?plot=overlay&typeID=voltage&deviceID=gps-taupoairport&resolution=hour", Content: "image/svg+xml"},
+	{ID: wt.L(), URL: "/field/metric?plot=overlay&typeID=voltage", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/field/metric?plot=overlay&typeID=voltage&deviceID=gps-taupoairport&resolution=day", Status: http.StatusBadRequest},
+
 	// Metric types
 	{ID: wt.L(), URL: "/field/type", Accept: "application/json;version=1"},
 
+	// Dashboards
+	{ID: wt.L(), URL: "/dashboard", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/dashboard", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/dashboard?name=nosuchboard", Accept: "application/x-protobuf"},
+
+	// Map bbox presets.  A preset name can be used for any bbox query parameter.
+	{ID: wt.L(), URL: "/map/bbox", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/map/bbox", Accept: "application/x-protobuf"},
+
 	// Data latency
 
 	// Delete site - cascades to latency values
@@ -183,6 +318,21 @@ var routes = wt.Requests{
 	{ID: wt.L(), URL: "/data/site?siteID=TAUP", Method: "DELETE"},
 	{ID: wt.L(), URL: "/data/site?siteID=TAUP&latitude=-38.74270&longitude=176.08100", Method: "PUT"},
 
+	// Site lifecycle state.
+	{ID: wt.L(), URL: "/data/site/state?siteID=TAUP&state=planned&changedBy=ops", Method: "PUT"},
+	{ID: wt.L(), URL: "/data/site/state?siteID=TAUP&state=active&changedBy=ops", Method: "PUT"},
+	{ID: wt.L(), URL: "/data/site/state?siteID=TAUP&state=retired&changedBy=ops", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/site/location?siteID=TAUP&latitude=-38.74270&longitude=176.08100&changedBy=ops", Method: "PUT"},
+
+	// Link field devices to a site with a role.  Repeated requests noop.
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=datalogger", Method: "PUT"},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=datalogger", Method: "PUT"},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=power", Method: "PUT"},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=power", Method: "DELETE"},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-nodevice&role=comms", Method: "PUT", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/site/device?siteID=NOSITE&deviceID=gps-taupoairport&role=comms", Method: "PUT", Status: http.StatusBadRequest},
+
 	// Should get a rate limit error for sends in the same minute
 	{ID: wt.L(), URL: "/data/latency?siteID=TAUP&typeID=latency.strong&time=2015-05-14T21:40:30Z&mean=10000", Method: "PUT"},
 	{ID: wt.L(), URL: "/data/latency?siteID=TAUP&typeID=latency.strong&time=2015-05-14T21:40:30Z&mean=14100", Status: http.StatusTooManyRequests, Method: "PUT"},
@@ -193,6 +343,35 @@ var routes = wt.Requests{
 
 	// All data sites as protobuf
 	{ID: wt.L(), URL: "/data/site", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site?state=all", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site?state=planned,maintenance", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site?state=retired", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/site/state?siteID=TAUP", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site/state?siteID=TAUP", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site/rename?siteID=TAUP", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site/location?siteID=TAUP", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site/device", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site/device?siteID=TAUP", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site/device?deviceID=gps-taupoairport", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/field/device?siteID=TAUP", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/site?siteID=TAUP", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/site/TAUP", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/site/TAUP", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/site/NOSITE", Accept: "application/x-protobuf", Status: http.StatusNotFound},
+	{ID: wt.L(), URL: "/incident", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/incident?deviceID=gps-taupoairport&typeID=voltage", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/incident?siteID=TAUP&since=2015-05-14T00:00:00Z", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/incident?since=yesterday", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/incident?siteID=TAUP&deviceID=gps-taupoairport", Accept: "application/x-protobuf", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14&groupBy=tag", Accept: "text/csv"},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14&groupBy=site&siteID=TAUP", Content: "text/html; charset=utf-8"},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-13", Accept: "application/json;version=1", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14&groupBy=region", Accept: "application/json;version=1", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14", Accept: "application/x-protobuf", Status: http.StatusNotAcceptable},
+	{ID: wt.L(), URL: "/data/site?latitude=-38.7&longitude=176.1&radius=50", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/site?latitude=-38.7&longitude=176.1&nearest=1", Accept: "application/json;version=1"},
 
 	// min, max, fifty, ninety are optional latency values
 	{ID: wt.L(), URL: "/data/latency?siteID=WGTN&typeID=latency.strong&time=2015-05-14T23:40:30Z&mean=10000&min=10&max=100000&fifty=9000&ninety=12000", Method: "PUT"},
@@ -214,6 +393,21 @@ var routes = wt.Requests{
 	// protobuf of all latency thresholds
 	{ID: wt.L(), URL: "/data/latency/threshold", Accept: "application/x-protobuf"},
 
+	// Latest latency as GeoJSON
+	{ID: wt.L(), URL: "/data/latency/summary", Accept: "application/vnd.geo+json", Content: "application/vnd.geo+json"},
+	{ID: wt.L(), URL: "/data/latency/summary?typeID=latency.strong", Accept: "application/vnd.geo+json", Content: "application/vnd.geo+json"},
+
+	// Network latency across sites
+	{ID: wt.L(), URL: "/data/latency/network", Accept: "application/json;version=1"},
+	{ID: wt.L(), URL: "/data/latency/network?typeID=latency.strong&resolution=hour&query=site:TAU*", Accept: "application/x-protobuf"},
+	{ID: wt.L(), URL: "/data/latency/network?typeID=latency.strong", Content: "image/svg+xml"},
+	{ID: wt.L(), URL: "/data/latency/network?typeID=latency.strong&resolution=five_minutes", Content: "image/svg+xml"},
+	{ID: wt.L(), URL: "/data/latency/network", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/latency/network?typeID=latency.strong&resolution=day", Status: http.StatusBadRequest},
+
+	// Latest latency as SVG map.  Only passes with the map180 data in the DB.
+	// {ID: wt.L(), URL: "/data/latency/summary?bbox=NewZealand&width=800&typeID=latency.strong", Content: "image/svg+xml"},
+
 	// Tags
 
 	{ID: wt.L(), URL: "/tag/FRED", Method: "DELETE"},
@@ -245,6 +439,9 @@ var routes = wt.Requests{
 	{ID: wt.L(), URL: "/data/latency?siteID=TAUP&typeID=latency.strong&resolution=minute"},
 	{ID: wt.L(), URL: "/data/latency?siteID=TAUP&typeID=latency.strong&resolution=hour"},
 	{ID: wt.L(), URL: "/data/latency?siteID=TAUP&typeID=latency.strong&plot=spark"},
+	{ID: wt.L(), URL: "/data/latency?plot=overlay&typeID=latency.strong&siteID=TAUP,WGTN", Content: "image/svg+xml"},
+	{ID: wt.L(), URL: "/data/latency?plot=overlay&typeID=latency.strong", Status: http.StatusBadRequest},
+	{ID: wt.L(), URL: "/data/latency?plot=overlay&typeID=latency.strong&siteID=TAUP,NOSITE", Status: http.StatusBadRequest},
 
 	// Tags
 
@@ -862,3 +1059,2220 @@ func TestDataLatencyThreshold(t *testing.T) {
 		t.Errorf("expected 12000 got %d", d.Lower)
 	}
 }
+
+func TestApplicationPrometheus(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	wr := promWriteRequest{
+		Timeseries: []*promTimeSeries{
+			{
+				Labels: []*promLabel{
+					{Name: "__name__", Value: "go_goroutines"},
+					{Name: "job", Value: "test-prometheus"},
+					{Name: "instance", Value: "test-instance"},
+				},
+				Samples: []*promSample{
+					{Value: 12, Timestamp: 1463262030000},
+					{Value: 13, Timestamp: 1463262090000},
+				},
+			},
+			// not a mapped metric name - skipped.
+			{
+				Labels: []*promLabel{
+					{Name: "__name__", Value: "http_requests_total"},
+					{Name: "job", Value: "test-prometheus"},
+					{Name: "instance", Value: "test-instance"},
+				},
+				Samples: []*promSample{
+					{Value: 1, Timestamp: 1463262030000},
+				},
+			},
+			// mapped name but no instance label - skipped and reported.
+			{
+				Labels: []*promLabel{
+					{Name: "__name__", Value: "go_goroutines"},
+					{Name: "job", Value: "test-prometheus"},
+				},
+				Samples: []*promSample{
+					{Value: 1, Timestamp: 1463262030000},
+				},
+			},
+		},
+	}
+
+	by, err := proto.Marshal(&wr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", testServer.URL+"/application/prometheus", bytes.NewReader(snappy.Encode(nil, by)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(userW, keyW)
+
+	res, err := wt.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// the series without an instance label is skipped, the rest are saved.
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 got %d", res.StatusCode)
+	}
+
+	var n int
+	if err = db.QueryRow(`SELECT count(*) FROM app.metric JOIN app.application USING (applicationpk)
+		WHERE applicationID = 'test-prometheus'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("expected 2 metrics got %d", n)
+	}
+
+	// Not snappy encoded.
+	req, err = http.NewRequest("POST", testServer.URL+"/application/prometheus", bytes.NewReader(by))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(userW, keyW)
+
+	if res, err = wt.Client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 got %d", res.StatusCode)
+	}
+
+	if _, err = db.Exec(`DELETE FROM app.application WHERE applicationID = 'test-prometheus'`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckPromTypes(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	promTypes["test_not_a_type"] = 32000
+
+	if err := checkPromTypes(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := promTypes["test_not_a_type"]; ok {
+		t.Error("expected unknown type rule to be removed")
+	}
+
+	if _, ok := promTypes["go_goroutines"]; !ok {
+		t.Error("expected go_goroutines type rule")
+	}
+}
+
+func TestFieldMetricInflux(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	lines := fmt.Sprintf("mtr,deviceID=gps-taupoairport voltage=12.5 %d\n", now.Add(time.Minute*-10).UnixNano()) +
+		fmt.Sprintf("mtr,deviceID=gps-taupoairport voltage=12.6,clock=90i %d\n", now.Add(time.Minute*-9).UnixNano()) +
+		fmt.Sprintf("mtr,deviceID=no-such-device voltage=12.6 %d\n", now.Add(time.Minute*-9).UnixNano()) +
+		fmt.Sprintf("weather,deviceID=gps-taupoairport temp=12.6 %d\n", now.Add(time.Minute*-9).UnixNano())
+
+	req, err := http.NewRequest("POST", testServer.URL+"/write?db=mtr", bytes.NewBufferString(lines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(userW, keyW)
+
+	res, err := wt.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// the two bad lines are reported.
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 got %d", res.StatusCode)
+	}
+
+	// a good write has no content.
+	lines = fmt.Sprintf("mtr,deviceID=gps-taupoairport voltage=12.7 %d\n", now.Add(time.Minute*-8).UnixNano())
+
+	if req, err = http.NewRequest("POST", testServer.URL+"/write?db=mtr", bytes.NewBufferString(lines)); err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(userW, keyW)
+
+	r2, err := wt.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Body.Close()
+
+	if r2.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204 got %d", r2.StatusCode)
+	}
+
+	var v int
+	if err = db.QueryRow(`SELECT value FROM field.metric JOIN field.device USING (devicepk)
+		WHERE deviceID = 'gps-taupoairport' AND typePK = 1 AND time = $1`, now.Add(time.Minute*-10)).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != 12500 {
+		t.Errorf("expected 12500 got %d", v)
+	}
+
+	if err = db.QueryRow(`SELECT value FROM field.metric JOIN field.device USING (devicepk)
+		WHERE deviceID = 'gps-taupoairport' AND typePK = 2 AND time = $1`, now.Add(time.Minute*-9)).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != 90 {
+		t.Errorf("expected 90 got %d", v)
+	}
+}
+
+func TestStatsd(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	m := newStatsdMinute()
+
+	for _, l := range []string{
+		"test-statsd.MsgRx:2|c",
+		"test-statsd.1201:1|c|@0.5",
+		"test-statsd.test.host.1100:10|g",
+		"test-statsd.test.host.1100:+2|g",
+		"test-statsd.pkg.func:10|ms",
+		"test-statsd.pkg.func:20|ms",
+	} {
+		if err := m.add(l); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, l := range []string{
+		"test-statsd",
+		"test-statsd:1|x",
+		"test-statsd.a.b:1|c",
+	} {
+		if err := m.add(l); err == nil {
+			t.Errorf("expected error for %s", l)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	m.save(now)
+
+	var n int
+
+	if err := db.QueryRow(`SELECT count FROM app.counter JOIN app.application USING (applicationpk)
+		WHERE applicationID = 'test-statsd' AND typePK = 1201 AND time = $1`, now).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 4 {
+		t.Errorf("expected count 4 got %d", n)
+	}
+
+	if err := db.QueryRow(`SELECT value FROM app.metric JOIN app.application USING (applicationpk)
+		JOIN app.instance USING (instancepk)
+		WHERE applicationID = 'test-statsd' AND instanceID = 'test.host' AND typePK = 1100 AND time = $1`, now).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 12 {
+		t.Errorf("expected value 12 got %d", n)
+	}
+
+	var c, a, f, ni int
+
+	if err := db.QueryRow(`SELECT count, average, fifty, ninety FROM app.timer JOIN app.application USING (applicationpk)
+		JOIN app.source USING (sourcepk)
+		WHERE applicationID = 'test-statsd' AND sourceID = 'pkg.func' AND time = $1`, now).Scan(&c, &a, &f, &ni); err != nil {
+		t.Fatal(err)
+	}
+
+	if c != 2 || a != 15 || f != 15 || ni != 20 {
+		t.Errorf("unexpected timer values count %d average %d fifty %d ninety %d", c, a, f, ni)
+	}
+
+	if _, err := db.Exec(`DELETE FROM app.application WHERE applicationID = 'test-statsd'`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScrape(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 42
+http_requests_total{code="200",method="get"} 10
+http_requests_total{code="500",method="get"} 2
+`))
+	}))
+	defer prom.Close()
+
+	expvar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cmdline": ["app"], "memstats": {"HeapAlloc": 1024, "HeapSys": 2048}}`))
+	}))
+	defer expvar.Close()
+
+	targets := []scrapeTarget{
+		{
+			URL:           prom.URL,
+			Format:        "prometheus",
+			ApplicationID: "test-scrape",
+			InstanceID:    "test-prom",
+			Metrics: map[string]string{
+				"go_goroutines":       "Routines",
+				"http_requests_total": "1",
+			},
+		},
+		{
+			URL:           expvar.URL,
+			Format:        "expvar",
+			ApplicationID: "test-scrape",
+			InstanceID:    "test-expvar",
+			Metrics: map[string]string{
+				"memstats.HeapAlloc": "1001",
+			},
+		},
+		{
+			URL:           prom.URL + "/no-such-path",
+			Format:        "expvar",
+			ApplicationID: "test-scrape",
+			InstanceID:    "test-err",
+		},
+	}
+
+	for i, v := range targets {
+		err := v.scrape()
+		if i < 2 && err != nil {
+			t.Error(err)
+		}
+		if i == 2 && err == nil {
+			t.Error("expected error for invalid expvar")
+		}
+	}
+
+	values := map[string]int{
+		"test-prom":   0,
+		"test-expvar": 0,
+	}
+
+	rows, err := db.Query(`SELECT instanceID, typePK, value FROM app.metric JOIN app.application USING (applicationpk)
+		JOIN app.instance USING (instancepk) WHERE applicationID = 'test-scrape'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i string
+		var typePK, v int
+
+		if err = rows.Scan(&i, &typePK, &v); err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case i == "test-prom" && typePK == 1100 && v == 42:
+		case i == "test-prom" && typePK == 1 && v == 12:
+		case i == "test-expvar" && typePK == 1001 && v == 1024:
+		default:
+			t.Errorf("unexpected metric %s %d %d", i, typePK, v)
+		}
+
+		values[i]++
+	}
+
+	if values["test-prom"] != 2 || values["test-expvar"] != 1 {
+		t.Errorf("unexpected number of metrics %v", values)
+	}
+
+	var ok, bad int
+
+	if err = db.QueryRow(`SELECT COALESCE(sum(count) FILTER (WHERE typePK = 1301), 0), COALESCE(sum(count) FILTER (WHERE typePK = 1302), 0)
+		FROM app.counter JOIN app.application USING (applicationpk) WHERE applicationID = 'test-scrape'`).Scan(&ok, &bad); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok != 2 || bad != 1 {
+		t.Errorf("expected 2 ok and 1 error scrapes got %d %d", ok, bad)
+	}
+
+	if _, err = db.Exec(`DELETE FROM app.application WHERE applicationID = 'test-scrape'`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpatialQuery(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/device?latitude=-38.7&longitude=176.1&radius=50", Accept: "application/x-protobuf"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var f mtrpb.FieldDeviceResult
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 1 {
+		t.Fatalf("expected 1 device got %d", len(f.Result))
+	}
+
+	d := f.Result[0]
+
+	if d.DeviceID != "gps-taupoairport" {
+		t.Errorf("expected gps-taupoairport got %s", d.DeviceID)
+	}
+
+	if d.Distance <= 0 || d.Distance > 50 {
+		t.Errorf("expected distance less than 50 km got %f", d.Distance)
+	}
+
+	if len(d.Metrics) == 0 {
+		t.Error("expected metrics for gps-taupoairport")
+	}
+
+	// Wellington is more than 50 km from Taupo.
+	r = wt.Request{ID: wt.L(), URL: "/field/device?latitude=-41.3&longitude=174.8&radius=50", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	f.Reset()
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 0 {
+		t.Errorf("expected 0 devices got %d", len(f.Result))
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/data/site?latitude=-41.3&longitude=174.8&nearest=1", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var s mtrpb.DataSiteResult
+
+	if err = proto.Unmarshal(b, &s); err != nil {
+		t.Error(err)
+	}
+
+	if len(s.Result) != 1 {
+		t.Fatalf("expected 1 site got %d", len(s.Result))
+	}
+
+	if s.Result[0].Distance == 0 {
+		t.Error("expected non zero distance")
+	}
+
+	switch s.Result[0].Status {
+	case "good", "bad", "late", "unknown":
+	default:
+		t.Errorf("unexpected status %s", s.Result[0].Status)
+	}
+}
+
+// TestSpatialNearestState checks nearest selects from the devices in the state filter.
+func TestSpatialNearestState(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	// A device in maintenance nearer to the point than gps-taupoairport.
+	in := wt.Requests{
+		{ID: wt.L(), URL: "/field/device?deviceID=gps-nearest&modelID=Trimble+NetR9&latitude=-38.7&longitude=176.1", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/state?deviceID=gps-nearest&state=maintenance&changedBy=ops", Method: "PUT"},
+	}
+
+	for _, r := range in {
+		r.User = userW
+		r.Password = keyW
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func() {
+		r := wt.Request{ID: wt.L(), URL: "/field/device?deviceID=gps-nearest", Method: "DELETE", User: userW, Password: keyW}
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, v := range []struct {
+		url, deviceID string
+	}{
+		{"/field/device?latitude=-38.7&longitude=176.1&nearest=1", "gps-taupoairport"},
+		{"/field/device?latitude=-38.7&longitude=176.1&nearest=1&state=maintenance", "gps-nearest"},
+		{"/field/device?latitude=-38.7&longitude=176.1&nearest=1&state=all", "gps-nearest"},
+	} {
+		r := wt.Request{ID: wt.L(), URL: v.url, Accept: "application/x-protobuf"}
+
+		b, err := r.Do(testServer.URL)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		var f mtrpb.FieldDeviceResult
+
+		if err = proto.Unmarshal(b, &f); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(f.Result) != 1 || f.Result[0].DeviceID != v.deviceID {
+			t.Errorf("%s: expected %s got %v", v.url, v.deviceID, f.Result)
+		}
+	}
+}
+
+func TestFieldMetricsSummaryGeoJSON(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/vnd.geo+json"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var g struct {
+		Type     string
+		Features []struct {
+			Type     string
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties fieldLatestProperties
+		}
+	}
+
+	if err = json.Unmarshal(b, &g); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Type != "FeatureCollection" {
+		t.Errorf("expected FeatureCollection got %s", g.Type)
+	}
+
+	if len(g.Features) != 1 {
+		t.Fatalf("expected 1 feature got %d", len(g.Features))
+	}
+
+	f := g.Features[0]
+
+	if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) != 2 {
+		t.Errorf("expected Point geometry got %s %v", f.Geometry.Type, f.Geometry.Coordinates)
+	}
+
+	if f.Properties.DeviceID != "gps-taupoairport" {
+		t.Errorf("expected gps-taupoairport got %s", f.Properties.DeviceID)
+	}
+
+	if f.Properties.TypeID != "voltage" {
+		t.Errorf("expected voltage got %s", f.Properties.TypeID)
+	}
+
+	if f.Properties.Unit != "V" {
+		t.Errorf("expected unit V got %s", f.Properties.Unit)
+	}
+
+	if f.Properties.Tags == nil {
+		t.Error("expected non nil tags")
+	}
+}
+
+func TestMapBbox(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	r := wt.Request{ID: wt.L(), URL: "/map/bbox", Accept: "application/x-protobuf"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var mr mtrpb.MapBboxResult
+
+	if err = proto.Unmarshal(b, &mr); err != nil {
+		t.Error(err)
+	}
+
+	if len(mr.Result) != len(mapBboxes) {
+		t.Fatalf("expected %d presets got %d", len(mapBboxes), len(mr.Result))
+	}
+
+	if mr.Result[0].Name != "NewZealand" || mr.Result[0].Bbox != "165,-48,179,-34" {
+		t.Errorf("unexpected first preset %v", mr.Result[0])
+	}
+
+	defer func(m []mapBbox) { mapBboxes = m }(append([]mapBbox{}, mapBboxes...))
+
+	if err = mapBboxRules("Tonga=-176.5,-22.5,-173.5,-15.5;Kermadecs=-180,-32,-177,-29"); err != nil {
+		t.Error(err)
+	}
+
+	if mapBboxLookup("Tonga") != "-176.5,-22.5,-173.5,-15.5" {
+		t.Errorf("unexpected bbox for Tonga %s", mapBboxLookup("Tonga"))
+	}
+
+	if mapBboxLookup("Kermadecs") != "-180,-32,-177,-29" {
+		t.Errorf("unexpected bbox for Kermadecs %s", mapBboxLookup("Kermadecs"))
+	}
+
+	if mapBboxLookup("165,-48,179,-34") != "165,-48,179,-34" {
+		t.Error("expected a bbox that isn't a preset to be unchanged")
+	}
+
+	for _, s := range []string{"Tonga", "Tonga=1,2,3", "Tonga=a,b,c,d", "=1,2,3,4"} {
+		if err = mapBboxRules(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestStatusMapSVG(t *testing.T) {
+	pts := []point{
+		{x: 100, y: 100, status: "good", title: "a", link: "/field/plot?deviceID=a&typeID=voltage"},
+		{x: 104, y: 103, status: "bad", title: "b", link: "/field/plot?deviceID=b&typeID=voltage"},
+		{x: 300, y: 300, status: "late", title: "c <late>", link: "/field/plot?deviceID=c&typeID=voltage"},
+	}
+
+	c := clusters(pts)
+
+	if len(c) != 2 {
+		t.Fatalf("expected 2 clusters got %d", len(c))
+	}
+
+	if len(c[0].points) != 2 || c[0].status != "bad" {
+		t.Errorf("expected 2 points with status bad got %d %s", len(c[0].points), c[0].status)
+	}
+
+	var b bytes.Buffer
+
+	statusMapSVG(map180.Raw{Width: 800, Height: 600}, pts, &b)
+
+	svg := b.String()
+
+	d := xml.NewDecoder(&b)
+
+	for {
+		if _, err := d.Token(); err != nil {
+			if err != io.EOF {
+				t.Errorf("invalid SVG: %s", err)
+			}
+			break
+		}
+	}
+
+	for _, s := range []string{"bad (1)", "good (1)", "late (1)", "no threshold (0)", "c &lt;late&gt;", "deviceID=c&amp;typeID=voltage"} {
+		if !strings.Contains(svg, s) {
+			t.Errorf("expected SVG to contain %s", s)
+		}
+	}
+}
+
+func TestTagNamespace(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	in := wt.Requests{
+		{ID: wt.L(), URL: "/namespace?namespace=site", Method: "DELETE"},
+		{ID: wt.L(), URL: "/namespace?namespace=site&description=Site+code", Method: "PUT"},
+		// Repeat PUT updates the description.
+		{ID: wt.L(), URL: "/namespace?namespace=site&description=Site+code+e.g.,+TAUP", Method: "PUT"},
+		{ID: wt.L(), URL: "/namespace?namespace=site:bad", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/tag/site:TAUP", Method: "PUT"},
+		{ID: wt.L(), URL: "/tag/site:", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/field/metric/tag?deviceID=gps-taupoairport&typeID=voltage&tag=site:TAUP", Method: "PUT"},
+	}
+
+	for _, r := range in {
+		r.User = userW
+		r.Password = keyW
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/namespace", Accept: "application/x-protobuf"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var nr mtrpb.TagNamespaceResult
+
+	if err = proto.Unmarshal(b, &nr); err != nil {
+		t.Error(err)
+	}
+
+	if len(nr.Result) != 1 || nr.Result[0].Namespace != "site" || nr.Result[0].Description != "Site code e.g., TAUP" {
+		t.Errorf("unexpected namespaces %v", nr.Result)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/tag?namespace=site", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var tr mtrpb.TagResult
+
+	if err = proto.Unmarshal(b, &tr); err != nil {
+		t.Error(err)
+	}
+
+	if len(tr.Result) != 1 {
+		t.Fatalf("expected 1 tag got %d", len(tr.Result))
+	}
+
+	if tr.Result[0].Tag != "site:TAUP" || tr.Result[0].Namespace != "site" || tr.Result[0].Value != "TAUP" {
+		t.Errorf("unexpected tag %v", tr.Result[0])
+	}
+
+	// Deleting the namespace deletes its tags.
+	r = wt.Request{ID: wt.L(), URL: "/namespace?namespace=site", Method: "DELETE", User: userW, Password: keyW}
+
+	if _, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/tag/site:TAUP", Accept: "application/x-protobuf", Status: http.StatusBadRequest}
+
+	if _, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseTagQuery(t *testing.T) {
+	in := []struct {
+		q, where string
+		args     []interface{}
+	}{
+		{"TAUP", "E$1", []interface{}{"TAUP"}},
+		{"TAU*", "E$1", []interface{}{"TAU%"}},
+		{"site_1%", "E$1", []interface{}{`site\_1\%`}},
+		{"owner:ops AND region:taranaki AND NOT decommissioned", "((E$1 AND E$2) AND (NOT E$3))",
+			[]interface{}{"owner:ops", "region:taranaki", "decommissioned"}},
+		{"a b", "(E$1 AND E$2)", []interface{}{"a", "b"}},
+		{"a OR b AND c", "(E$1 OR (E$2 AND E$3))", []interface{}{"a", "b", "c"}},
+		{"(a OR b) AND NOT (c OR d)", "((E$1 OR E$2) AND (NOT (E$3 OR E$4)))", []interface{}{"a", "b", "c", "d"}},
+	}
+
+	for _, v := range in {
+		e, err := parseTagQuery(v.q)
+		if err != nil {
+			t.Errorf("%s: %s", v.q, err)
+			continue
+		}
+
+		var args []interface{}
+
+		if w := e.sql("E$%d", &args); w != v.where {
+			t.Errorf("%s: expected %s got %s", v.q, v.where, w)
+		}
+
+		if fmt.Sprint(args) != fmt.Sprint(v.args) {
+			t.Errorf("%s: expected args %v got %v", v.q, v.args, args)
+		}
+	}
+
+	for _, q := range []string{"", "AND", "a AND", "a OR", "NOT", "(a", "a)", "()", "a AND OR b"} {
+		if _, err := parseTagQuery(q); err == nil {
+			t.Errorf("expected error for %s", q)
+		}
+	}
+}
+
+func TestTagBulk(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	// The report after method for the selector.
+	in := []struct {
+		id, method, selector      string
+		matched, created, removed int64
+	}{
+		// gps-taupoairport voltage is tagged TAUP but not LINZ.
+		{wt.L(), "GET", "tag=LINZ&typeID=voltage&query=TAUP", 1, 1, 0},
+		{wt.L(), "PUT", "tag=LINZ&typeID=voltage&query=TAUP", 1, 0, 1},
+		// Repeat is a noop
+		{wt.L(), "PUT", "tag=LINZ&typeID=voltage&query=TAUP", 1, 0, 1},
+		{wt.L(), "DELETE", "tag=LINZ&typeID=voltage&devicePrefix=gps-taupo", 1, 1, 0},
+		{wt.L(), "PUT", "tag=LINZ&devicePrefix=gps-nope", 0, 0, 0},
+	}
+
+	for _, v := range in {
+		testTagBulk(t, v.id, v.method, v.selector, v.matched, v.created, v.removed)
+	}
+
+	// A new metric is selected after the summary views are refreshed.
+	r := wt.Request{ID: wt.L(), URL: "/field/metric?deviceID=gps-taupoairport&typeID=satellites&time=2015-05-14T21:40:30Z&value=9",
+		Method: "PUT", User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	testTagBulk(t, wt.L(), "PUT", "tag=LINZ&typeID=satellites", 1, 0, 1)
+}
+
+// testTagBulk makes a bulk tag request, unless method is GET, and then checks the report for selector.
+func testTagBulk(t *testing.T, id, method, selector string, matched, created, removed int64) {
+	if method != "GET" {
+		r := wt.Request{ID: id, URL: "/bulk/tag?" + selector, Method: method, User: userW, Password: keyW}
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	r := wt.Request{ID: id, URL: "/bulk/tag?" + selector, Accept: "application/json;version=1"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var rep tagBulkReport
+
+	if err = json.Unmarshal(b, &rep); err != nil {
+		t.Errorf("%s %s", id, err)
+		return
+	}
+
+	c := rep.FieldMetric
+
+	if c.Matched != matched || c.Created != created || c.Removed != removed {
+		t.Errorf("%s expected matched %d created %d removed %d got %+v", id, matched, created, removed, c)
+	}
+
+	if rep.DataLatency.Matched != 0 {
+		t.Errorf("%s expected no data latency matches got %d", id, rep.DataLatency.Matched)
+	}
+}
+
+func TestFieldAttribute(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	in := wt.Requests{
+		{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=firmware&value=4.85", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=firmware&value=5.01", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=rack&value=B2", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/attribute?deviceID=gps-taupoairport&key=rack", Method: "DELETE"},
+	}
+
+	for _, r := range in {
+		r.User = userW
+		r.Password = keyW
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/device?deviceID=gps-taupoairport", Accept: "application/x-protobuf"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var dr mtrpb.FieldDeviceResult
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 1 {
+		t.Fatalf("expected 1 device got %d", len(dr.Result))
+	}
+
+	a := make(map[string]string)
+
+	for _, v := range dr.Result[0].Attributes {
+		a[v.Key] = v.Value
+	}
+
+	expected := map[string]string{"firmware": "5.01", "installed": "2015-05-14", "serial": "5036K70213"}
+
+	if len(a) != len(expected) {
+		t.Errorf("expected %d attributes got %d", len(expected), len(a))
+	}
+
+	for k, v := range expected {
+		if a[k] != v {
+			t.Errorf("expected %s for %s got %s", v, k, a[k])
+		}
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/model", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var mr mtrpb.FieldModelResult
+
+	if err = proto.Unmarshal(b, &mr); err != nil {
+		t.Error(err)
+	}
+
+	if len(mr.Result) != 1 {
+		t.Fatalf("expected 1 model got %d", len(mr.Result))
+	}
+
+	if len(mr.Result[0].Attributes) != 2 {
+		t.Fatalf("expected 2 model attributes got %d", len(mr.Result[0].Attributes))
+	}
+
+	// attributes are sorted by key
+	if mr.Result[0].Attributes[0].Key != "expectedMetrics" || mr.Result[0].Attributes[0].Value != "voltage,clock" {
+		t.Errorf("unexpected model attribute %v", mr.Result[0].Attributes[0])
+	}
+
+	if mr.Result[0].Attributes[1].Key != "manufacturer" || mr.Result[0].Attributes[1].Value != "Trimble" {
+		t.Errorf("unexpected model attribute %v", mr.Result[0].Attributes[1])
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport&state=decommissioned&changedBy=ops", Method: "PUT",
+		User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	// Repeating the same state is not logged.
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	var b []byte
+	var err error
+
+	for _, v := range []struct {
+		id       string
+		url      string
+		expected int
+	}{
+		{id: wt.L(), url: "/field/metric/summary", expected: 0},
+		{id: wt.L(), url: "/field/metric/summary?state=all", expected: 1},
+		{id: wt.L(), url: "/field/metric/summary?state=decommissioned", expected: 1},
+		{id: wt.L(), url: "/field/metric/summary?state=active,maintenance", expected: 0},
+	} {
+		r = wt.Request{ID: v.id, URL: v.url, Accept: "application/x-protobuf"}
+
+		if b, err = r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+
+		var f mtrpb.FieldMetricSummaryResult
+
+		if err = proto.Unmarshal(b, &f); err != nil {
+			t.Error(err)
+		}
+
+		if len(f.Result) != v.expected {
+			t.Errorf("%s expected %d results got %d", v.id, v.expected, len(f.Result))
+		}
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device?state=decommissioned", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var dr mtrpb.FieldDeviceResult
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 1 || dr.Result[0].State != "decommissioned" {
+		t.Errorf("expected 1 decommissioned device got %v", dr.Result)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device/state?deviceID=gps-taupoairport", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var lr mtrpb.LifecycleStateResult
+
+	if err = proto.Unmarshal(b, &lr); err != nil {
+		t.Error(err)
+	}
+
+	// maintenance and active from the routes then decommissioned.
+	if len(lr.Result) != 3 {
+		t.Fatalf("expected 3 state changes got %d", len(lr.Result))
+	}
+
+	s := lr.Result[2]
+
+	if s.State != "decommissioned" {
+		t.Errorf("expected decommissioned got %s", s.State)
+	}
+
+	if s.ChangedBy != "ops" {
+		t.Errorf("expected changed by ops got %s", s.ChangedBy)
+	}
+
+	if s.Seconds == 0 {
+		t.Error("expected non zero time for state change")
+	}
+}
+
+func TestRename(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport&newDeviceID=gps-taupo&changedBy=ops", Method: "PUT",
+		User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupo&latitude=-38.70000&longitude=176.10000&changedBy=ops", Method: "PUT",
+		User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	var b []byte
+	var err error
+
+	// Metrics, tags, and thresholds are kept with the new deviceID.
+	r = wt.Request{ID: wt.L(), URL: "/field/metric/summary", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var f mtrpb.FieldMetricSummaryResult
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 1 || f.Result[0].DeviceID != "gps-taupo" {
+		t.Errorf("expected 1 metric for gps-taupo got %v", f.Result)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/metric/tag", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var tr mtrpb.FieldMetricTagResult
+
+	if err = proto.Unmarshal(b, &tr); err != nil {
+		t.Error(err)
+	}
+
+	if len(tr.Result) == 0 {
+		t.Error("expected tags for gps-taupo")
+	}
+
+	for _, v := range tr.Result {
+		if v.DeviceID != "gps-taupo" {
+			t.Errorf("expected tag for gps-taupo got %s", v.DeviceID)
+		}
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/metric/threshold", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var thr mtrpb.FieldMetricThresholdResult
+
+	if err = proto.Unmarshal(b, &thr); err != nil {
+		t.Error(err)
+	}
+
+	if len(thr.Result) != 1 || thr.Result[0].DeviceID != "gps-taupo" {
+		t.Errorf("expected 1 threshold for gps-taupo got %v", thr.Result)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupo", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var rr mtrpb.RenameResult
+
+	if err = proto.Unmarshal(b, &rr); err != nil {
+		t.Error(err)
+	}
+
+	if len(rr.Result) != 1 {
+		t.Fatalf("expected 1 rename got %d", len(rr.Result))
+	}
+
+	if rr.Result[0].OldID != "gps-taupoairport" || rr.Result[0].NewID != "gps-taupo" {
+		t.Errorf("unexpected rename %v", rr.Result[0])
+	}
+
+	if rr.Result[0].ChangedBy != "ops" {
+		t.Errorf("expected changed by ops got %s", rr.Result[0].ChangedBy)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device/location?deviceID=gps-taupo", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var lr mtrpb.LocationResult
+
+	if err = proto.Unmarshal(b, &lr); err != nil {
+		t.Error(err)
+	}
+
+	// The location from the routes then the move.
+	if len(lr.Result) < 2 {
+		t.Fatalf("expected at least 2 locations got %d", len(lr.Result))
+	}
+
+	l := lr.Result[len(lr.Result)-1]
+
+	if l.Latitude != -38.7 || l.Longitude != 176.1 {
+		t.Errorf("expected latest location -38.7 176.1 got %f %f", l.Latitude, l.Longitude)
+	}
+
+	if l.ChangedBy != "ops" {
+		t.Errorf("expected location changed by ops got %s", l.ChangedBy)
+	}
+
+	// The device location is the latest location.
+	r = wt.Request{ID: wt.L(), URL: "/field/device", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var dr mtrpb.FieldDeviceResult
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 1 || dr.Result[0].Latitude != -38.7 {
+		t.Errorf("expected 1 device at -38.7 got %v", dr.Result)
+	}
+}
+
+func TestSiteDevice(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport&role=comms", Method: "PUT",
+		User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/site/TAUP", Accept: "application/x-protobuf"}
+
+	var b []byte
+	var err error
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var s mtrpb.Site
+
+	if err = proto.Unmarshal(b, &s); err != nil {
+		t.Error(err)
+	}
+
+	if s.Site == nil || s.Site.SiteID != "TAUP" {
+		t.Fatalf("expected site TAUP got %v", s.Site)
+	}
+
+	if len(s.Site.Latency) == 0 {
+		t.Error("expected latency summaries for TAUP")
+	}
+
+	// datalogger from the routes and comms.
+	if len(s.Links) != 2 {
+		t.Errorf("expected 2 links got %d", len(s.Links))
+	}
+
+	for i, role := range []string{"comms", "datalogger"} {
+		if i < len(s.Links) && (s.Links[i].Role != role || s.Links[i].DeviceID != "gps-taupoairport") {
+			t.Errorf("expected %s link for gps-taupoairport got %v", role, s.Links[i])
+		}
+	}
+
+	if len(s.Devices) != 1 {
+		t.Fatalf("expected 1 device got %d", len(s.Devices))
+	}
+
+	if s.Devices[0].DeviceID != "gps-taupoairport" {
+		t.Errorf("expected gps-taupoairport got %s", s.Devices[0].DeviceID)
+	}
+
+	if len(s.Devices[0].Metrics) == 0 {
+		t.Error("expected metrics for gps-taupoairport")
+	}
+
+	// Deleting without a role removes all links.
+	r = wt.Request{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=gps-taupoairport", Method: "DELETE",
+		User: userW, Password: keyW}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/data/site/device?siteID=TAUP", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var sr mtrpb.SiteDeviceResult
+
+	if err = proto.Unmarshal(b, &sr); err != nil {
+		t.Error(err)
+	}
+
+	if len(sr.Result) != 0 {
+		t.Errorf("expected no links got %d", len(sr.Result))
+	}
+}
+
+func TestTopologyStatus(t *testing.T) {
+	// rad-a <- rad-b <- gps-c, TAUP is linked to gps-c.  rad-d is reachable with a bad metric e.g., a full disk.
+	tp := topology{
+		parents: map[string][]string{"rad-b": {"rad-a"}, "gps-c": {"rad-b"}, "gps-e": {"rad-d"}},
+		sites:   map[string][]string{"TAUP": {"gps-c"}},
+		device:  map[string]string{"rad-a": "good", "rad-b": "late", "gps-c": "bad", "rad-d": "bad"},
+		reach:   map[string]string{"rad-a": "good", "rad-b": "late", "gps-c": "bad", "rad-d": "good"},
+	}
+
+	in := []struct {
+		id, deviceID, status string
+		expected             string
+		cause                []string
+	}{
+		{id: wt.L(), deviceID: "gps-c", status: "bad", expected: "impacted", cause: []string{"rad-b"}},
+		{id: wt.L(), deviceID: "gps-c", status: "good", expected: "good"},
+		{id: wt.L(), deviceID: "rad-b", status: "late", expected: "late"},
+		{id: wt.L(), deviceID: "rad-a", status: "bad", expected: "bad"},
+		{id: wt.L(), deviceID: "gps-e", status: "late", expected: "late"},
+	}
+
+	for _, v := range in {
+		s, c := tp.fieldStatus(v.deviceID, v.status)
+
+		if s != v.expected {
+			t.Errorf("%s expected status %s got %s", v.id, v.expected, s)
+		}
+
+		if strings.Join(c, ",") != strings.Join(v.cause, ",") {
+			t.Errorf("%s expected root cause %v got %v", v.id, v.cause, c)
+		}
+	}
+
+	// The root cause is the unreachable device furthest upstream.
+	tp.reach["rad-a"] = "bad"
+
+	if s, c := tp.dataStatus("TAUP", "late"); s != "impacted" || strings.Join(c, ",") != "rad-a" {
+		t.Errorf("expected TAUP impacted by rad-a got %s %v", s, c)
+	}
+
+	if s, c := tp.dataStatus("WGTN", "late"); s != "late" || c != nil {
+		t.Errorf("expected WGTN late got %s %v", s, c)
+	}
+}
+
+func TestDependency(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	// A repeater with a late conn metric that gps-taupoairport and TAUP depend on.
+	for _, r := range []wt.Request{
+		{ID: wt.L(), URL: "/field/device?deviceID=rad-taupo&modelID=Trimble+NetR9&latitude=-38.7&longitude=176.1", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/metric?deviceID=rad-taupo&typeID=voltage&time=2015-05-14T21:40:30Z&value=14100", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/metric?deviceID=rad-taupo&typeID=conn&time=2015-05-14T21:40:30Z&value=1000", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/dependency?deviceID=gps-taupoairport&parentID=rad-taupo", Method: "PUT"},
+		{ID: wt.L(), URL: "/field/device/dependency?deviceID=gps-taupoairport&parentID=rad-taupo", Method: "PUT"},
+		{ID: wt.L(), URL: "/data/site/device?siteID=TAUP&deviceID=rad-taupo&role=comms", Method: "PUT"},
+	} {
+		r.User = userW
+		r.Password = keyW
+
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A cycle is an error.
+	r := wt.Request{ID: wt.L(), URL: "/field/device/dependency?deviceID=rad-taupo&parentID=gps-taupoairport", Method: "PUT",
+		User: userW, Password: keyW, Status: http.StatusBadRequest}
+
+	if _, err := r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	var b []byte
+	var err error
+
+	// The test metrics are old so they are late.
+	r = wt.Request{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var f mtrpb.FieldMetricSummaryResult
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 2 {
+		t.Fatalf("expected 2 results got %d", len(f.Result))
+	}
+
+	for _, v := range f.Result {
+		switch v.DeviceID {
+		case "gps-taupoairport":
+			if v.Status != "impacted" || len(v.RootCause) != 1 || v.RootCause[0] != "rad-taupo" {
+				t.Errorf("expected gps-taupoairport impacted by rad-taupo got %s %v", v.Status, v.RootCause)
+			}
+		case "rad-taupo":
+			if v.Status != "late" || len(v.RootCause) != 0 {
+				t.Errorf("expected rad-taupo late got %s %v", v.Status, v.RootCause)
+			}
+		}
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/data/latency/summary", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var d mtrpb.DataLatencySummaryResult
+
+	if err = proto.Unmarshal(b, &d); err != nil {
+		t.Error(err)
+	}
+
+	for _, v := range d.Result {
+		if v.SiteID == "TAUP" && (v.Status != "impacted" || len(v.RootCause) != 1 || v.RootCause[0] != "rad-taupo") {
+			t.Errorf("expected TAUP impacted by rad-taupo got %s %v", v.Status, v.RootCause)
+		}
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/device/dependency", Content: "image/svg+xml"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	for _, s := range []string{"rad-taupo", "gps-taupoairport", "site:TAUP"} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected SVG to contain %s", s)
+		}
+	}
+
+	// A decommissioned repeater is not a root cause.
+	r = wt.Request{ID: wt.L(), URL: "/field/device/state?deviceID=rad-taupo&state=decommissioned&changedBy=ops", Method: "PUT",
+		User: userW, Password: keyW}
+
+	if _, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err = refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	r = wt.Request{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 1 || f.Result[0].Status != "late" || len(f.Result[0].RootCause) != 0 {
+		t.Errorf("expected gps-taupoairport late with no root cause got %+v", f.Result)
+	}
+}
+
+func TestIncidentSpans(t *testing.T) {
+	now := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := func(min int) time.Time { return now.Add(time.Minute * time.Duration(min)) }
+
+	// voltage between 12000 and 15000
+	pts := []incidentPoint{
+		{t: m(-300), value: 13000, lower: 12000, upper: 15000},
+		{t: m(-290), value: 11000, lower: 12000, upper: 15000},
+		{t: m(-280), value: 10000, lower: 12000, upper: 15000},
+		{t: m(-270), value: 11500, lower: 12000, upper: 15000},
+		{t: m(-260), value: 13000, lower: 12000, upper: 15000},
+		// a gap longer than lateAfter.
+		{t: m(-10), value: 16000, lower: 12000, upper: 15000},
+	}
+
+	s := incidentSpans(pts, now)
+
+	if len(s) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(s))
+	}
+
+	if !s[0].start.Equal(m(-290)) || !s[0].end.Equal(m(-260)) || s[0].worst != 10000 || s[0].status != "bad" {
+		t.Errorf("unexpected first span %+v", s[0])
+	}
+
+	// the late gap and the bad value are one ongoing span.
+	if !s[1].start.Equal(m(-260).Add(lateAfter)) || !s[1].end.IsZero() || s[1].worst != 16000 || s[1].status != "bad" {
+		t.Errorf("unexpected second span %+v", s[1])
+	}
+
+	// no thresholds and no recent values is only late.
+	s = incidentSpans([]incidentPoint{{t: m(-600), value: 20000}}, now)
+
+	if len(s) != 1 || !s[0].start.Equal(m(-600).Add(lateAfter)) || s[0].worst != 20000 || s[0].status != "late" {
+		t.Errorf("unexpected late span %+v", s)
+	}
+
+	if s = incidentSpans([]incidentPoint{{t: m(-5), value: 20000}}, now); len(s) != 0 {
+		t.Errorf("expected no spans got %+v", s)
+	}
+}
+
+func TestGroupIncidents(t *testing.T) {
+	in := []*mtrpb.Incident{
+		{DeviceID: "gps-a", TypeID: "voltage", Start: 100, Duration: 100},
+		{SiteID: "TAUP", TypeID: "latency.gnss.1hz", Start: 150, Duration: 10, Tags: []string{"TAUP"}},
+		{DeviceID: "gps-a", TypeID: "clock", Start: 90, Duration: 70, Tags: []string{"TAUP"}},
+		{DeviceID: "gps-b", TypeID: "voltage", Start: 120, Duration: 10},
+		{DeviceID: "gps-a", TypeID: "voltage", Start: 500, Duration: 10},
+	}
+
+	groupIncidents(in)
+
+	for i, g := range []int32{1, 1, 1, 2, 3} {
+		if in[i].Group != g {
+			t.Errorf("incident %d expected group %d got %d", i, g, in[i].Group)
+		}
+	}
+}
+
+func TestIncident(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	// The test metrics are old so every metric is late.
+	if err := scanIncidents(); err != nil {
+		t.Error(err)
+	}
+
+	var before, after int
+
+	if err := db.QueryRow(`SELECT max(incidentPK) FROM field.incident`).Scan(&before); err != nil {
+		t.Error(err)
+	}
+
+	// A second scan extends the incidents and doesn't add more.
+	if err := scanIncidents(); err != nil {
+		t.Error(err)
+	}
+
+	// The incidents are updated in place.
+	if err := db.QueryRow(`SELECT max(incidentPK) FROM field.incident`).Scan(&after); err != nil {
+		t.Error(err)
+	}
+
+	if before != after {
+		t.Errorf("expected incidentPK %d got %d", before, after)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/incident?deviceID=gps-taupoairport&typeID=voltage&since=2015-01-01T00:00:00Z", Accept: "application/x-protobuf"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var n mtrpb.IncidentResult
+
+	if err = proto.Unmarshal(b, &n); err != nil {
+		t.Error(err)
+	}
+
+	if len(n.Result) != 1 {
+		t.Fatalf("expected 1 incident got %d", len(n.Result))
+	}
+
+	if n.Result[0].End != 0 || n.Result[0].Duration == 0 || n.Result[0].Group != 1 {
+		t.Errorf("unexpected incident %+v", n.Result[0])
+	}
+}
+
+func TestMetricAvailability(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := func(hours int) time.Time { return start.Add(time.Hour * time.Duration(hours)) }
+
+	// voltage between 12000 and 15000 expected every hour.  A value's status lasts 2 hours.
+	pts := []incidentPoint{
+		{t: h(2), value: 13000, lower: 12000, upper: 15000},
+		{t: h(4), value: 11000, lower: 12000, upper: 15000},
+		{t: h(5), value: 13000, lower: 12000, upper: 15000},
+		// 19 hours to the end of the day, 2 good, 1 missing, and 16 late.
+	}
+
+	a := metricAvailability(nil, pts, start, start.Add(day), time.Hour)
+
+	if a.missing != time.Hour*3 || a.good != time.Hour*4 || a.bad != time.Hour || a.late != time.Hour*16 {
+		t.Errorf("unexpected availability %+v", a)
+	}
+
+	if a.total() != day {
+		t.Errorf("expected total %s got %s", day, a.total())
+	}
+
+	// The latest value before the start was 2 hours earlier and bad.
+	prev := incidentPoint{t: h(-2), value: 16000, lower: 12000, upper: 15000}
+
+	a = metricAvailability(&prev, nil, start, h(4), time.Hour)
+
+	if a.bad != 0 || a.late != time.Hour*3 || a.good != 0 || a.missing != time.Hour {
+		t.Errorf("unexpected availability with prev %+v", a)
+	}
+
+	// Values every minute with a 31 minute gap.  The gap is missing after the 2 minutes
+	// the last value before it lasts.
+	pts = nil
+
+	for i := 0; i < 60; i++ {
+		if i < 10 || i >= 40 {
+			pts = append(pts, incidentPoint{t: start.Add(time.Minute * time.Duration(i)), value: 13000, lower: 12000, upper: 15000})
+		}
+	}
+
+	a = metricAvailability(nil, pts, start, h(1), time.Minute)
+
+	if a.good != time.Minute*31 || a.missing != time.Minute*29 || a.bad != 0 || a.late != 0 {
+		t.Errorf("unexpected availability with a gap %+v", a)
+	}
+
+	if percent(time.Minute*20, time.Hour) != 33.33 {
+		t.Errorf("expected 33.33 got %f", percent(time.Minute*20, time.Hour))
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := rollupAvailability(time.Date(2015, 5, 15, 1, 0, 0, 0, time.UTC)); err != nil {
+		t.Error(err)
+	}
+
+	// The report for a rolled up day is the same as from the raw values.
+	for _, q := range []string{"deviceID=gps-taupoairport&typeID=voltage", "siteID=TAUP&typeID=latency.strong"} {
+		r := wt.Request{ID: wt.L(), URL: "/report/availability?from=2015-05-14&to=2015-05-14&" + q, Accept: "application/json;version=1"}
+
+		b, err := r.Do(testServer.URL)
+		if err != nil {
+			t.Error(err)
+		}
+
+		var rp availabilityReport
+
+		if err = json.Unmarshal(b, &rp); err != nil {
+			t.Error(err)
+		}
+
+		if len(rp.Rows) != 1 {
+			t.Fatalf("%s expected 1 row got %d", q, len(rp.Rows))
+		}
+
+		v := rp.Rows[0]
+
+		if v.Minutes != 1440 {
+			t.Errorf("%s expected 1440 minutes got %d", q, v.Minutes)
+		}
+
+		if s := v.Good + v.Bad + v.Late + v.Missing; s < 99.9 || s > 100.1 {
+			t.Errorf("%s expected percentages to add to 100 got %f", q, s)
+		}
+	}
+
+	// A day that was not rolled up before the raw values were deleted is missing.
+	r := wt.Request{ID: wt.L(), URL: "/report/availability?from=2015-03-01&to=2015-03-01&deviceID=gps-taupoairport&typeID=voltage", Accept: "application/json;version=1"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var rp availabilityReport
+
+	if err = json.Unmarshal(b, &rp); err != nil {
+		t.Error(err)
+	}
+
+	if len(rp.Rows) != 1 || rp.Rows[0].Missing != 100 {
+		t.Errorf("expected the day to be missing got %+v", rp.Rows)
+	}
+}
+
+func TestMetricGaps(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := func(min int) time.Time { return start.Add(time.Minute * time.Duration(min)) }
+
+	// values every minute with a 20 minute gap.  The end of the window is 2 minutes after
+	// the last value which is not a gap.
+	var times []time.Time
+
+	for i := 0; i < 10; i++ {
+		times = append(times, m(i))
+	}
+
+	for i := 30; i < 59; i++ {
+		times = append(times, m(i))
+	}
+
+	g := metricGaps(times, start, m(60), time.Minute)
+
+	if len(g) != 1 {
+		t.Fatalf("expected 1 gap got %d", len(g))
+	}
+
+	if !g[0].start.Equal(m(9)) || !g[0].end.Equal(m(30)) || g[0].missing != 20 {
+		t.Errorf("unexpected gap %+v", g[0])
+	}
+
+	e, c := completeness(len(times), start, m(60), time.Minute)
+
+	if e != 60 || c != 65 {
+		t.Errorf("expected 60 values and 65%% got %d %f", e, c)
+	}
+
+	// no values is one gap for the window.
+	g = metricGaps(nil, start, m(60), time.Minute*5)
+
+	if len(g) != 1 || !g[0].start.Equal(start) || !g[0].end.Equal(m(60)) || g[0].missing != 11 {
+		t.Errorf("unexpected gaps for no values %+v", g)
+	}
+
+	// more values than expected is complete.
+	if _, c = completeness(len(times), start, m(60), time.Minute*5); c != 100 {
+		t.Errorf("expected 100%% got %f", c)
+	}
+
+	if plotGap(time.Minute*10, time.Minute) != time.Minute*20 || plotGap(time.Minute, time.Hour) != time.Hour*2 {
+		t.Error("unexpected plot gap")
+	}
+}
+
+func TestFieldCompleteness(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/metric/completeness?deviceID=gps-taupoairport&typeID=voltage", Accept: "application/x-protobuf"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var c mtrpb.FieldMetricCompletenessResult
+
+	if err = proto.Unmarshal(b, &c); err != nil {
+		t.Error(err)
+	}
+
+	if len(c.Result) != 1 {
+		t.Fatalf("expected 1 result got %d", len(c.Result))
+	}
+
+	// The test metrics are old so the window is one gap.  The device interval is used.
+	v := c.Result[0]
+
+	if v.Interval != 60 || v.Expected != 1440 || v.Received != 0 || v.Completeness != 0 || len(v.Gaps) != 1 {
+		t.Errorf("unexpected completeness %+v", v)
+	}
+}
+
+func TestAnomalyScore(t *testing.T) {
+	b := baseline{median: 13000, mad: 100}
+
+	if s := b.score(13674.5); s < 4.54 || s > 4.56 {
+		t.Errorf("expected score 4.55 got %f", s)
+	}
+
+	// more than half the values are the median so the mean absolute deviation is used.
+	if s := (baseline{median: 13000, meanAD: 100}).score(13626.657); s < 4.99 || s > 5.01 {
+		t.Errorf("expected score 5 for zero mad got %f", s)
+	}
+
+	// a flat metric is scored with the minimum deviation (0.5% of the median, 65).
+	if s := (baseline{median: 13000}).score(13000); s != 0 {
+		t.Errorf("expected 0 score for the median of a flat metric got %f", s)
+	}
+
+	if s := (baseline{median: 13000}).score(13130); s != 2 {
+		t.Errorf("expected score 2 for a flat metric got %f", s)
+	}
+
+	t0 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if anomalous(map[int]baseline{0: {median: 13000}}, t0, 13001) {
+		t.Error("expected a small change in a flat metric not to be anomalous")
+	}
+
+	if !anomalous(map[int]baseline{0: {median: 13000}}, t0, 14000) {
+		t.Error("expected a large change in a flat metric to be anomalous")
+	}
+
+	// the minimum deviation is one stored unit for a metric that is flat at 0.
+	if !anomalous(map[int]baseline{0: {}}, t0, 4) || anomalous(map[int]baseline{0: {}}, t0, 3) {
+		t.Error("expected a change of more than 3.5 to be anomalous for a metric flat at 0")
+	}
+
+	// no baseline is never anomalous.
+	if s, a := anomaly(20000, sql.NullFloat64{}, sql.NullFloat64{}, sql.NullFloat64{}); s != 0 || a {
+		t.Errorf("expected no anomaly without a baseline got %f %t", s, a)
+	}
+
+	if s, a := anomaly(12000, sql.NullFloat64{Float64: 13000, Valid: true}, sql.NullFloat64{Float64: 100, Valid: true}, sql.NullFloat64{Float64: 120, Valid: true}); s > -6.7 || !a {
+		t.Errorf("expected an anomaly got %f %t", s, a)
+	}
+
+	// baselines are by UTC hour of day.
+	bl := map[int]baseline{3: b}
+
+	if !anomalous(bl, time.Date(2016, 1, 1, 3, 30, 0, 0, time.UTC), 14000) {
+		t.Error("expected anomalous at 03:30")
+	}
+
+	if anomalous(bl, time.Date(2016, 1, 1, 4, 30, 0, 0, time.UTC), 14000) {
+		t.Error("expected no baseline at 04:30")
+	}
+
+	m := anomalyMarkers(bl, []ts.Point{
+		{DateTime: time.Date(2016, 1, 1, 3, 0, 0, 0, time.UTC), Value: 13.0},
+		{DateTime: time.Date(2016, 1, 1, 3, 1, 0, 0, time.UTC), Value: 14.0},
+	}, 0.001)
+
+	if len(m.Points) != 1 || m.Points[0].Value != 14.0 || m.Colour != anomalyColour {
+		t.Errorf("unexpected markers %+v", m)
+	}
+}
+
+func TestAnomaly(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := refreshViews(); err != nil {
+		t.Error(err)
+	}
+
+	// The test metrics are too old for a baseline.
+	if err := refreshBaselines(); err != nil {
+		t.Error(err)
+	}
+
+	// A baseline for the hour of the latest voltage (21:40 UTC) that 14100 is well above.
+	if _, err := db.Exec(`INSERT INTO field.metric_baseline(devicePK, typePK, hour, median, mad, meanad)
+		SELECT devicePK, typePK, 21, 13000, 100, 120 FROM field.device, field.type
+		WHERE deviceID = 'gps-taupoairport' AND typeID = 'voltage'`); err != nil {
+		t.Fatal(err)
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/field/metric/summary?typeID=voltage", Accept: "application/x-protobuf"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var f mtrpb.FieldMetricSummaryResult
+
+	if err = proto.Unmarshal(b, &f); err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Result) != 1 {
+		t.Fatalf("expected 1 result got %d", len(f.Result))
+	}
+
+	if d := f.Result[0]; !d.Anomalous || d.Score < 7.4 || d.Score > 7.5 {
+		t.Errorf("expected anomalous with score 7.42 got %t %f", d.Anomalous, d.Score)
+	}
+
+	// The data latency has no baseline.
+	r = wt.Request{ID: wt.L(), URL: "/data/latency/summary", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	var d mtrpb.DataLatencySummaryResult
+
+	if err = proto.Unmarshal(b, &d); err != nil {
+		t.Error(err)
+	}
+
+	for _, v := range d.Result {
+		if v.Anomalous || v.Score != 0 {
+			t.Errorf("expected no anomaly for %s got %t %f", v.SiteID, v.Anomalous, v.Score)
+		}
+	}
+}
+
+func TestTheilSen(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := func(hours int) time.Time { return start.Add(time.Hour * time.Duration(hours)) }
+
+	// disk use increasing 1 per hour with a spike that should not move the trend.
+	var pts []trendPoint
+
+	for i := 0; i < 24; i++ {
+		pts = append(pts, trendPoint{t: h(i), value: 50 + float64(i)})
+	}
+
+	pts[10].value = 99
+
+	if _, ok := theilSen(pts[:forecastMinPoints-1]); ok {
+		t.Error("expected no trend for too few points")
+	}
+
+	tr, ok := theilSen(pts)
+	if !ok {
+		t.Fatal("expected a trend")
+	}
+
+	if s := tr.slope * 3600; s < 0.999 || s > 1.001 {
+		t.Errorf("expected slope 1 per hour got %f", s)
+	}
+
+	if v := tr.at(h(23)); v < 72.99 || v > 73.01 {
+		t.Errorf("expected 73 at the last point got %f", v)
+	}
+
+	// reaches the upper threshold of 90 in 17 hours.
+	b, ok := tr.breach(h(23), 0, 90)
+	if !ok || !b.Equal(h(40)) {
+		t.Errorf("expected breach at %s got %s %t", h(40), b, ok)
+	}
+
+	f := metricTrend{deviceID: "a", typeID: "disk.hd1", upper: 90, trend: tr}.forecast(h(23))
+	if !f.Breach || f.Days != 0.71 || f.Slope < 23.99 || f.Slope > 24.01 {
+		t.Errorf("unexpected forecast %+v", f)
+	}
+
+	// already past the threshold.
+	if b, ok = tr.breach(h(23), 0, 60); !ok || !b.Equal(h(23)) {
+		t.Errorf("expected breach now got %s %t", b, ok)
+	}
+
+	// long histories are subsampled for the slopes.
+	pts = nil
+
+	for i := 0; i < forecastMaxPoints*3; i++ {
+		pts = append(pts, trendPoint{t: h(i), value: 50 + float64(i)})
+	}
+
+	if s := subsample(pts, forecastMaxPoints); len(s) != forecastMaxPoints || s[0] != pts[0] || s[len(s)-1] != pts[len(pts)-1] {
+		t.Errorf("unexpected subsample of %d points", len(s))
+	}
+
+	if tr, ok = theilSen(pts); !ok || tr.slope*3600 < 0.999 || tr.slope*3600 > 1.001 {
+		t.Errorf("expected slope 1 per hour for a long history got %f", tr.slope*3600)
+	}
+
+	// moving away from the lower threshold and the upper is beyond the horizon.
+	if _, ok = tr.breach(h(23), 10, 100000); ok {
+		t.Error("expected no breach within the horizon")
+	}
+
+	if _, ok = tr.breach(h(23), 0, 0); ok {
+		t.Error("expected no breach without thresholds")
+	}
+
+	// voltage declining.
+	down := trend{t: start, value: 12500, slope: -100 / 3600.0}
+
+	if b, ok = down.breach(start, 12000, 15000); !ok || !b.Equal(h(5)) {
+		t.Errorf("expected breach at %s got %s %t", h(5), b, ok)
+	}
+}
+
+func TestDataLatencyNetwork(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	// Recent latencies for two sites in the same minute.  The WGTN ninety is unknown.
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	for _, v := range []struct {
+		siteID       string
+		mean, ninety int
+	}{{"TAUP", 1000, 2000}, {"WGTN", 3000, 0}} {
+		if _, err := db.Exec(`INSERT INTO data.latency(sitePK, typePK, rate_limit, time, mean, min, max, fifty, ninety)
+			SELECT sitePK, typePK, $2, $3, $4, 0, 0, 0, $5 FROM data.site, data.type
+			WHERE siteID = $1 AND typeID = 'latency.strong'`, v.siteID, now.Unix(), now, v.mean, v.ninety); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/data/latency/network?typeID=latency.strong", Accept: "application/x-protobuf"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var n mtrpb.DataLatencyNetworkResult
+
+	if err = proto.Unmarshal(b, &n); err != nil {
+		t.Error(err)
+	}
+
+	if len(n.Result) != 1 {
+		t.Fatalf("expected 1 result got %d", len(n.Result))
+	}
+
+	if v := n.Result[0]; v.Seconds != now.Unix() || v.Sites != 2 || v.Mean != 2000 || v.Ninety != 2000 {
+		t.Errorf("unexpected network latency %+v", v)
+	}
+
+	// No sites match the tag query.
+	r.URL = "/data/latency/network?typeID=latency.strong&query=nosuchtag"
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	n.Reset()
+
+	if err = proto.Unmarshal(b, &n); err != nil {
+		t.Error(err)
+	}
+
+	if len(n.Result) != 0 {
+		t.Errorf("expected no results for nosuchtag got %d", len(n.Result))
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	setup(t)
+	defer teardown()
+
+	// Load test data.
+	if err := routes.DoAllStatusOk(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	in := wt.Requests{
+		{ID: wt.L(), URL: "/dashboard?name=tsunami&owner=ops", Method: "PUT"},
+		// Repeat PUT by the owner updates the description and can give the dashboard to a new owner.
+		{ID: wt.L(), URL: "/dashboard?name=tsunami&owner=ops&newOwner=tsunami-team&description=Gauges+and+comms", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard?name=tsunami&owner=ops&description=Mine", Method: "PUT", Status: http.StatusForbidden},
+		{ID: wt.L(), URL: "/dashboard?name=tsunami&owner=ops", Method: "DELETE", Status: http.StatusForbidden},
+		{ID: wt.L(), URL: "/dashboard?name=bad/name&owner=ops", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=1&siteID=TAUP&typeID=latency.strong", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=2&deviceID=gps-taupoairport&typeID=voltage&window=12h&resolution=five_minutes", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=3&applicationID=test-app&group=timers&window=28d", Method: "PUT"},
+		// Repeat PUT replaces the panel at the position.
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=3&applicationID=test-app&group=memory&window=48h", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=4&deviceID=gps-taupoairport&typeID=voltage", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=ops&position=4", Method: "DELETE", Status: http.StatusForbidden},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=4", Method: "DELETE"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=ops&position=5&deviceID=gps-taupoairport&typeID=voltage", Method: "PUT", Status: http.StatusForbidden},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=5&applicationID=test-app&group=memory&resolution=five_minutes", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=5&deviceID=gps-taupoairport&typeID=voltage&window=28d&resolution=minute", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=5&deviceID=nosuchdevice&typeID=voltage", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard/panel?name=tsunami&owner=tsunami-team&position=5&typeID=voltage", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard/panel?name=nosuchboard&owner=ops&position=1&deviceID=gps-taupoairport&typeID=voltage", Method: "PUT", Status: http.StatusBadRequest},
+		{ID: wt.L(), URL: "/dashboard?name=other&owner=ops", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard/panel?name=other&owner=ops&position=1&deviceID=gps-taupoairport&typeID=voltage", Method: "PUT"},
+		{ID: wt.L(), URL: "/dashboard?name=other&owner=ops", Method: "DELETE"},
+		// Renaming a device updates the panels for it.
+		{ID: wt.L(), URL: "/field/device/rename?deviceID=gps-taupoairport&newDeviceID=gps-taupo&changedBy=ops", Method: "PUT"},
+	}
+
+	for _, r := range in {
+		r.User = userW
+		r.Password = keyW
+		if _, err := r.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}
+
+	r := wt.Request{ID: wt.L(), URL: "/dashboard", Accept: "application/x-protobuf"}
+
+	b, err := r.Do(testServer.URL)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var dr mtrpb.DashboardResult
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 1 || dr.Result[0].Name != "tsunami" || dr.Result[0].Owner != "tsunami-team" ||
+		dr.Result[0].Description != "Gauges and comms" || len(dr.Result[0].Panels) != 0 {
+		t.Errorf("unexpected dashboards %v", dr.Result)
+	}
+
+	r.URL = "/dashboard?name=tsunami"
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	dr.Reset()
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 1 {
+		t.Fatalf("expected 1 dashboard got %d", len(dr.Result))
+	}
+
+	expected := []string{
+		"/data/latency?resolution=minute&siteID=TAUP&typeID=latency.strong",
+		"/field/metric?deviceID=gps-taupo&plot=spark&typeID=voltage",
+		"/app/metric?applicationID=test-app&group=memory&resolution=five_minutes",
+	}
+
+	if len(dr.Result[0].Panels) != len(expected) {
+		t.Fatalf("expected %d panels got %d", len(expected), len(dr.Result[0].Panels))
+	}
+
+	for i, p := range dr.Result[0].Panels {
+		if p.Plot != expected[i] {
+			t.Errorf("panel %d expected plot %s got %s", p.Position, expected[i], p.Plot)
+		}
+
+		// The plots are valid.
+		pr := wt.Request{ID: wt.L(), URL: p.Plot, Content: "image/svg+xml"}
+
+		if _, err = pr.Do(testServer.URL); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The deleted dashboard is not found.
+	r = wt.Request{ID: wt.L(), URL: "/dashboard?name=other", Accept: "application/x-protobuf"}
+
+	if b, err = r.Do(testServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	dr.Reset()
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		t.Error(err)
+	}
+
+	if len(dr.Result) != 0 {
+		t.Errorf("expected no dashboards got %d", len(dr.Result))
+	}
+}
+
+func TestCommonThreshold(t *testing.T) {
+	in := []struct {
+		id           string
+		l            []overlaySeries
+		lower, upper int
+		ok, differ   bool
+	}{
+		{id: wt.L(), l: nil},
+		{id: wt.L(), l: []overlaySeries{{id: "a"}}},
+		// no thresholds don't differ.
+		{id: wt.L(), l: []overlaySeries{{id: "a"}, {id: "b"}}},
+		{id: wt.L(), l: []overlaySeries{{id: "a", lower: 1, upper: 5, threshold: true}}, lower: 1, upper: 5, ok: true},
+		{id: wt.L(), l: []overlaySeries{{id: "a", lower: 1, upper: 5, threshold: true}, {id: "b", lower: 1, upper: 5, threshold: true}}, lower: 1, upper: 5, ok: true},
+		{id: wt.L(), l: []overlaySeries{{id: "a", lower: 1, upper: 5, threshold: true}, {id: "b", lower: 2, upper: 5, threshold: true}}, differ: true},
+		{id: wt.L(), l: []overlaySeries{{id: "a", lower: 1, upper: 5, threshold: true}, {id: "b"}}, differ: true},
+		{id: wt.L(), l: []overlaySeries{{id: "a"}, {id: "b", lower: 1, upper: 5, threshold: true}}, differ: true},
+	}
+
+	for _, v := range in {
+		lower, upper, ok, differ := commonThreshold(v.l)
+
+		if ok != v.ok || differ != v.differ || lower != v.lower || upper != v.upper {
+			t.Errorf("%s expected %d %d %t %t got %d %d %t %t", v.id, v.lower, v.upper, v.ok, v.differ, lower, upper, ok, differ)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/GeoNet/mtr/internal"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Scraping pulls metrics from services that can't send metrics to mtr.  Set
+MTR_SCRAPE_CONFIG to the name of a JSON file that lists the scrape targets e.g.,
+
+    [
+      {
+        "url": "http://localhost:9090/metrics",
+        "format": "prometheus",
+        "applicationID": "prometheus",
+        "instanceID": "localhost",
+        "interval": "1m",
+        "metrics": {
+          "go_goroutines": "Routines",
+          "process_resident_memory_bytes": "MemSys"
+        }
+      },
+      {
+        "url": "http://localhost:8080/debug/vars",
+        "format": "expvar",
+        "applicationID": "my-app",
+        "instanceID": "localhost",
+        "metrics": {
+          "memstats.HeapAlloc": "1001"
+        }
+      }
+    ]
+
+format is prometheus (the text exposition format) or expvar (Go expvar JSON).
+interval defaults to one minute and must be positive.  metrics maps the scraped name to an app.type typeID
+(the number or name).  For prometheus the name can be the metric name, which sums all
+series for the metric, or a series as it appears in the scrape e.g., http_requests_total{code="200"}.
+Nested expvar values are named by joining keys with a dot.
+
+Each scrape increments the ScrapeOK or ScrapeErr counter for the applicationID.
+*/
+
+type scrapeTarget struct {
+	URL           string            `json:"url"`
+	Format        string            `json:"format"`
+	ApplicationID string            `json:"applicationID"`
+	InstanceID    string            `json:"instanceID"`
+	Interval      string            `json:"interval"`
+	Metrics       map[string]string `json:"metrics"`
+	interval      time.Duration
+}
+
+var scrapeClient = &http.Client{Timeout: 30 * time.Second}
+
+// readScrapeConfig reads the scrape targets from the JSON file name.
+func readScrapeConfig(name string) ([]scrapeTarget, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []scrapeTarget
+
+	if err = json.NewDecoder(f).Decode(&targets); err != nil {
+		return nil, err
+	}
+
+	for i := range targets {
+		t := &targets[i]
+
+		switch t.Format {
+		case "prometheus", "expvar":
+		default:
+			return nil, fmt.Errorf("invalid format for %s: %s", t.URL, t.Format)
+		}
+
+		if t.URL == "" || t.ApplicationID == "" || t.InstanceID == "" {
+			return nil, fmt.Errorf("url, applicationID, and instanceID are required for all targets")
+		}
+
+		t.interval = time.Minute
+
+		if t.Interval != "" {
+			// time.NewTicker panics for a non positive interval.
+			if t.interval, err = time.ParseDuration(t.Interval); err != nil || t.interval <= 0 {
+				return nil, fmt.Errorf("invalid interval for %s: %s", t.URL, t.Interval)
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// scrapeTimed scrapes the targets in the file name at the interval for each target.
+func scrapeTimed(name string) {
+	targets, err := readScrapeConfig(name)
+	if err != nil {
+		log.Printf("ERROR: problem with scrape config: %s", err)
+		return
+	}
+
+	for _, t := range targets {
+		go func(t scrapeTarget) {
+			ticker := time.NewTicker(t.interval).C
+			for {
+				select {
+				case <-ticker:
+					if err := t.scrape(); err != nil {
+						log.Printf("scrape %s: %s", t.URL, err)
+					}
+				}
+			}
+		}(t)
+	}
+}
+
+// scrape scrapes the target and saves the mapped values as app metrics.
+// The scrape success or failure is saved as a counter.
+func (t scrapeTarget) scrape() error {
+	now := time.Now().UTC()
+
+	values, err := t.fetch()
+
+	var c applicationCounter
+	c.applicationID = t.ApplicationID
+	c.t = now.Truncate(time.Minute)
+	c.c = 1
+	c.typePK = int(internal.ScrapeOK)
+
+	if err != nil {
+		c.typePK = int(internal.ScrapeErr)
+	}
+
+	if res := c.application.load(); !res.Ok {
+		return fmt.Errorf("%s", res.Msg)
+	}
+
+	if res := c.insert(); !res.Ok {
+		return fmt.Errorf("%s", res.Msg)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var a applicationMetric
+	a.application = c.application
+	a.instanceID = t.InstanceID
+	a.t = now
+
+	if res := a.applicationInstance.load(); !res.Ok {
+		return fmt.Errorf("%s", res.Msg)
+	}
+
+	types := make(map[string]int)
+
+	for k, typeID := range t.Metrics {
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+
+		if a.typePK, err = appTypePK(typeID, types); err != nil {
+			return err
+		}
+
+		a.value = int64(math.Floor(v + 0.5))
+
+		if res := a.insert(); !res.Ok {
+			return fmt.Errorf("%s", res.Msg)
+		}
+	}
+
+	return nil
+}
+
+// fetch returns the values from the target.
+func (t scrapeTarget) fetch() (map[string]float64, error) {
+	res, err := scrapeClient.Get(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non 200 response: %d", res.StatusCode)
+	}
+
+	switch t.Format {
+	case "prometheus":
+		return parsePrometheusText(res.Body)
+	case "expvar":
+		return parseExpvar(res.Body)
+	default:
+		return nil, fmt.Errorf("invalid format: %s", t.Format)
+	}
+}
+
+/*
+parsePrometheusText parses the Prometheus text exposition format.  Values are returned
+for each series e.g., http_requests_total{code="200"} and also summed by metric name
+e.g., http_requests_total.
+*/
+func parsePrometheusText(r io.Reader) (map[string]float64, error) {
+	values := make(map[string]float64)
+
+	s := bufio.NewScanner(r)
+
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var series, rest string
+
+		if i := strings.LastIndex(line, "}"); i != -1 {
+			series, rest = line[:i+1], line[i+1:]
+		} else {
+			f := strings.SplitN(line, " ", 2)
+			if len(f) != 2 {
+				return nil, fmt.Errorf("invalid line: %s", line)
+			}
+			series, rest = f[0], f[1]
+		}
+
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+
+		v, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %s", line)
+		}
+
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+
+		values[series] = v
+
+		if i := strings.Index(series, "{"); i != -1 {
+			values[series[:i]] += v
+		}
+	}
+
+	return values, s.Err()
+}
+
+// parseExpvar parses Go expvar JSON.  Nested values are named by joining keys with a dot.
+func parseExpvar(r io.Reader) (map[string]float64, error) {
+	var m map[string]interface{}
+
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64)
+
+	flattenExpvar("", m, values)
+
+	return values, nil
+}
+
+func flattenExpvar(prefix string, m map[string]interface{}, values map[string]float64) {
+	for k, v := range m {
+		switch t := v.(type) {
+		case float64:
+			values[prefix+k] = t
+		case map[string]interface{}:
+			flattenExpvar(prefix+k+".", t, values)
+		}
+	}
+}
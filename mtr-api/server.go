@@ -25,23 +25,48 @@ func init() {
 	mux.HandleFunc("/", weft.MakeHandlerAPI(home))
 	mux.HandleFunc("/tag/", weft.MakeHandlerAPI(tagHandler))
 	mux.HandleFunc("/tag", weft.MakeHandlerAPI(tagsHandler))
+	mux.HandleFunc("/bulk/tag", weft.MakeHandlerAPI(tagBulkHandler))
+	mux.HandleFunc("/search", weft.MakeHandlerAPI(tagQueryHandler))
+	mux.HandleFunc("/namespace", weft.MakeHandlerAPI(tagNamespaceHandler))
+	mux.HandleFunc("/dashboard", weft.MakeHandlerAPI(dashboardHandler))
+	mux.HandleFunc("/dashboard/panel", weft.MakeHandlerAPI(dashboardPanelHandler))
 	mux.HandleFunc("/field/model", weft.MakeHandlerAPI(fieldModelHandler))
+	mux.HandleFunc("/field/model/attribute", weft.MakeHandlerAPI(fieldModelAttributeHandler))
 	mux.HandleFunc("/field/device", weft.MakeHandlerAPI(fieldDeviceHandler))
+	mux.HandleFunc("/field/device/attribute", weft.MakeHandlerAPI(fieldDeviceAttributeHandler))
+	mux.HandleFunc("/field/device/state", weft.MakeHandlerAPI(fieldDeviceStateHandler))
+	mux.HandleFunc("/field/device/rename", weft.MakeHandlerAPI(fieldDeviceRenameHandler))
+	mux.HandleFunc("/field/device/location", weft.MakeHandlerAPI(fieldDeviceLocationHandler))
+	mux.HandleFunc("/field/device/dependency", weft.MakeHandlerAPI(fieldDeviceDependencyHandler))
 	mux.HandleFunc("/field/type", weft.MakeHandlerAPI(fieldTypeHandler))
 	mux.HandleFunc("/field/metric", weft.MakeHandlerAPI(fieldMetricHandler))
 	mux.HandleFunc("/field/metric/summary", weft.MakeHandlerAPI(fieldMetricLatestHandler))
 	mux.HandleFunc("/field/metric/threshold", weft.MakeHandlerAPI(fieldThresholdHandler))
+	mux.HandleFunc("/field/metric/interval", weft.MakeHandlerAPI(fieldIntervalHandler))
+	mux.HandleFunc("/field/metric/completeness", weft.MakeHandlerAPI(fieldCompletenessHandler))
+	mux.HandleFunc("/field/metric/forecast", weft.MakeHandlerAPI(fieldForecastHandler))
 	mux.HandleFunc("/field/metric/tag", weft.MakeHandlerAPI(fieldMetricTagHandler))
 	mux.HandleFunc("/health", health)
+	mux.HandleFunc("/write", weft.MakeHandlerAPI(fieldMetricInfluxHandler))
+	mux.HandleFunc("/map/bbox", weft.MakeHandlerAPI(mapBboxHandler))
 	mux.HandleFunc("/data/site", weft.MakeHandlerAPI(dataSiteHandler))
+	mux.HandleFunc("/data/site/state", weft.MakeHandlerAPI(dataSiteStateHandler))
+	mux.HandleFunc("/data/site/rename", weft.MakeHandlerAPI(dataSiteRenameHandler))
+	mux.HandleFunc("/data/site/location", weft.MakeHandlerAPI(dataSiteLocationHandler))
+	mux.HandleFunc("/data/site/device", weft.MakeHandlerAPI(dataSiteDeviceHandler))
+	mux.HandleFunc("/site/", weft.MakeHandlerAPI(siteHandler))
+	mux.HandleFunc("/incident", weft.MakeHandlerAPI(incidentHandler))
+	mux.HandleFunc("/report/availability", weft.MakeHandlerAPI(availabilityHandler))
 	mux.HandleFunc("/data/latency", weft.MakeHandlerAPI(dataLatencyHandler))
 	mux.HandleFunc("/data/latency/summary", weft.MakeHandlerAPI(dataLatencySummaryHandler))
+	mux.HandleFunc("/data/latency/network", weft.MakeHandlerAPI(dataLatencyNetworkHandler))
 	mux.HandleFunc("/data/latency/tag", weft.MakeHandlerAPI(dataLatencyTagHandler))
 	mux.HandleFunc("/data/latency/threshold", weft.MakeHandlerAPI(dataLatencyThresholdHandler))
 	mux.HandleFunc("/app/metric", weft.MakeHandlerAPI(appMetricHandler))
 	mux.HandleFunc("/application/metric", weft.MakeHandlerAPI(applicationMetricHandler))
 	mux.HandleFunc("/application/counter", weft.MakeHandlerAPI(applicationCounterHandler))
 	mux.HandleFunc("/application/timer", weft.MakeHandlerAPI(applicationTimerHandler))
+	mux.HandleFunc("/application/prometheus", weft.MakeHandlerAPI(applicationPrometheusHandler))
 }
 
 func main() {
@@ -76,14 +101,33 @@ func main() {
 		log.Println("ERROR: problem pinging DB - is it up and contactable? 500s will be served")
 	}
 
-	// For map zoom regions other than NZ will need to read some config from somewhere.
-	wm, err = map180.Init(dbR, map180.Region(`newzealand`), 256000000)
+	wm, err = map180.Init(dbR, mapRegion(), 256000000)
 	if err != nil {
-		log.Println("ERROR: problem with map180 config: %s", err)
+		log.Printf("ERROR: problem with map180 config: %s", err)
+	}
+
+	if err = checkPromTypes(); err != nil {
+		log.Printf("ERROR: problem checking Prometheus types: %s", err)
 	}
 
 	go deleteMetrics()
 	go refreshViewsTimed()
+	go incidentsTimed()
+	go availabilityTimed()
+	go baselinesTimed()
+	go trendsTimed()
+
+	if name := os.Getenv("MTR_SCRAPE_CONFIG"); name != "" {
+		go scrapeTimed(name)
+	}
+
+	if addr := os.Getenv("MTR_STATSD_ADDR"); addr != "" {
+		go func() {
+			if err := statsd(addr); err != nil {
+				log.Printf("ERROR: problem with statsd listener: %s", err)
+			}
+		}()
+	}
 
 	log.Println("starting server")
 	log.Fatal(http.ListenAndServe(":8080", inbound(mux)))
@@ -136,11 +180,11 @@ func deleteMetrics() {
 	for {
 		select {
 		case <-ticker:
-			if _, err = db.Exec(`DELETE FROM field.metric WHERE time < now() - interval '40 days'`); err != nil {
+			if _, err = db.Exec(`DELETE FROM field.metric WHERE time < $1`, time.Now().UTC().Add(-availabilityRetention)); err != nil {
 				log.Println(err)
 			}
 
-			if _, err = db.Exec(`DELETE FROM data.latency WHERE time < now() - interval '40 days'`); err != nil {
+			if _, err = db.Exec(`DELETE FROM data.latency WHERE time < $1`, time.Now().UTC().Add(-availabilityRetention)); err != nil {
 				log.Println(err)
 			}
 
@@ -180,5 +224,5 @@ func refreshViews() error {
 		return err
 	}
 
-	return nil
+	return refreshTopology()
 }
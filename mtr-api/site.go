@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// site is the combined view for a data site; the latency summaries for the site
+// and the field metric summaries for every device linked to it.  Sites and devices
+// in any lifecycle state are included.
+type site struct {
+	result mtrpb.Site
+}
+
+// load loads the site for the siteID in the URL path e.g., /site/TAUP
+func (s *site) load(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	siteID := strings.TrimPrefix(r.URL.Path, "/site/")
+
+	if siteID == "" || strings.Contains(siteID, "/") {
+		return &weft.NotFound
+	}
+
+	q := url.Values{}
+	q.Set("siteID", siteID)
+	q.Set("state", "all")
+
+	var d dataSite
+
+	sites, res := d.sites(withQuery(r, q))
+	if !res.Ok {
+		return res
+	}
+
+	if len(sites) == 0 {
+		return &weft.NotFound
+	}
+
+	s.result.Site = sites[0]
+
+	var f fieldDevice
+
+	if s.result.Devices, res = f.devices(withQuery(r, q)); !res.Ok {
+		return res
+	}
+
+	var err error
+
+	if s.result.Links, err = loadSiteDevices(siteID, ""); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// withQuery returns a shallow copy of r with the URL query replaced by q.
+func withQuery(r *http.Request, q url.Values) *http.Request {
+	u := *r.URL
+	u.RawQuery = q.Encode()
+
+	c := *r
+	c.URL = &u
+
+	return &c
+}
+
+func (s *site) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := s.load(r); !res.Ok {
+		return res
+	}
+
+	by, err := proto.Marshal(&s.result)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+type siteJSON struct {
+	Site    dataSiteJSON
+	Links   []siteDeviceJSON
+	Devices []fieldDeviceJSON
+}
+
+func (s *site) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := s.load(r); !res.Ok {
+		return res
+	}
+
+	j := siteJSON{
+		Site:    dataSiteJSONs([]*mtrpb.DataSite{s.result.Site})[0],
+		Links:   siteDeviceJSONs(s.result.Links),
+		Devices: fieldDeviceJSONs(s.result.Devices),
+	}
+
+	by, err := json.Marshal(j)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GeoNet/map180"
+	"github.com/GeoNet/weft"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// metrics older than this are late.
+const lateAfter = time.Hour * 3
+
+// spatialQuery selects field devices or data sites by location.
+// Use one of bbox; latitude, longitude, and radius (km); or latitude, longitude, and nearest (n).
+type spatialQuery struct {
+	bbox                string
+	latitude, longitude float64
+	radius              float64
+	nearest             int
+}
+
+// spatialParams are the optional query parameters for a spatialQuery.
+var spatialParams = []string{"bbox", "latitude", "longitude", "radius", "nearest"}
+
+func (s *spatialQuery) read(r *http.Request) *weft.Result {
+	v := r.URL.Query()
+
+	s.bbox = mapBboxLookup(v.Get("bbox"))
+
+	switch {
+	case s.bbox != "" && (v.Get("latitude") != "" || v.Get("longitude") != "" || v.Get("radius") != "" || v.Get("nearest") != ""):
+		return weft.BadRequest("bbox can't be combined with latitude, longitude, radius, or nearest")
+	case s.bbox != "":
+		if err := map180.ValidBbox(s.bbox); err != nil {
+			return weft.BadRequest(err.Error())
+		}
+		return &weft.StatusOK
+	case v.Get("latitude") == "" && v.Get("longitude") == "" && v.Get("radius") == "" && v.Get("nearest") == "":
+		return &weft.StatusOK
+	case v.Get("radius") != "" && v.Get("nearest") != "":
+		return weft.BadRequest("use one of radius or nearest")
+	case v.Get("radius") == "" && v.Get("nearest") == "":
+		return weft.BadRequest("radius or nearest is required with latitude and longitude")
+	}
+
+	var err error
+
+	if s.latitude, err = strconv.ParseFloat(v.Get("latitude"), 64); err != nil || s.latitude < -90 || s.latitude > 90 {
+		return weft.BadRequest("invalid latitude")
+	}
+
+	if s.longitude, err = strconv.ParseFloat(v.Get("longitude"), 64); err != nil || s.longitude < -180 || s.longitude > 360 {
+		return weft.BadRequest("invalid longitude")
+	}
+
+	if v.Get("radius") != "" {
+		if s.radius, err = strconv.ParseFloat(v.Get("radius"), 64); err != nil || s.radius <= 0 {
+			return weft.BadRequest("invalid radius")
+		}
+	}
+
+	if v.Get("nearest") != "" {
+		if s.nearest, err = strconv.Atoi(v.Get("nearest")); err != nil || s.nearest <= 0 {
+			return weft.BadRequest("invalid nearest")
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+/*
+selectSQL returns a query selecting pk, distance (km) from table.  table must have a PostGIS
+geography column geom.  distance is 0 unless a point was used for the query.  where is an SQL
+boolean expression on the columns of table (use TRUE for all rows) with its args already in
+args.  It is applied before nearest is limited so the query has the n nearest rows that match
+where.  The args for the spatial query are appended to args.
+*/
+func (s *spatialQuery) selectSQL(table, pk, where string, args *[]interface{}) (string, error) {
+	switch {
+	case s.bbox != "":
+		p, err := map180.BboxToWKTPolygon(s.bbox)
+		if err != nil {
+			return "", err
+		}
+
+		// POLYGON((llx lly,llx ury,urx ury,urx lly,llx lly))
+		var llx, lly, ury, urx float64
+		if _, err = fmt.Sscanf(p, "POLYGON((%f %f,%f %f,%f", &llx, &lly, &llx, &ury, &urx); err != nil {
+			return "", err
+		}
+
+		*args = append(*args, p)
+		n := strconv.Itoa(len(*args))
+
+		// For a bbox that crosses 180 shift longitudes to 0-360 for both the polygon and the points.
+		if llx > urx {
+			return fmt.Sprintf(`SELECT %s, 0.0 AS distance FROM %s
+				WHERE ST_Within(ST_Shift_Longitude(geom::geometry), ST_Shift_Longitude(ST_GeomFromText($%s, 4326)))
+				AND (%s)`, pk, table, n, where), nil
+		}
+
+		return fmt.Sprintf(`SELECT %s, 0.0 AS distance FROM %s
+			WHERE ST_Within(geom::geometry, ST_GeomFromText($%s, 4326)) AND (%s)`, pk, table, n, where), nil
+	case s.radius > 0:
+		*args = append(*args, s.point(), s.radius*1000.0)
+		n := strconv.Itoa(len(*args) - 1)
+		m := strconv.Itoa(len(*args))
+
+		return fmt.Sprintf(`SELECT %s, ST_Distance(geom, ST_GeogFromText($%s)) / 1000.0 AS distance FROM %s
+			WHERE ST_DWithin(geom, ST_GeogFromText($%s), $%s) AND (%s)`, pk, n, table, n, m, where), nil
+	case s.nearest > 0:
+		*args = append(*args, s.point(), s.nearest)
+		n := strconv.Itoa(len(*args) - 1)
+		m := strconv.Itoa(len(*args))
+
+		return fmt.Sprintf(`SELECT %s, ST_Distance(geom, ST_GeogFromText($%s)) / 1000.0 AS distance FROM %s
+			WHERE %s
+			ORDER BY distance ASC LIMIT $%s`, pk, n, table, where, m), nil
+	default:
+		return fmt.Sprintf(`SELECT %s, 0.0 AS distance FROM %s WHERE %s`, pk, table, where), nil
+	}
+}
+
+func (s *spatialQuery) point() string {
+	return fmt.Sprintf("SRID=4326;POINT(%f %f)", s.longitude, s.latitude)
+}
+
+// statusRank orders status from best to worst.
+var statusRank = map[string]int{
+	"good":     0,
+	"unknown":  1,
+	"impacted": 2,
+	"late":     3,
+	"bad":      4,
+}
+
+// worstStatus returns the worse of status a and b.
+func worstStatus(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// fieldStatus returns the status (good, bad, late, unknown) for a field metric.
+func fieldStatus(t time.Time, value, lower, upper int32) string {
+	switch {
+	case t.Before(time.Now().UTC().Add(-lateAfter)):
+		return "late"
+	case lower == 0 && upper == 0:
+		return "unknown"
+	case value < lower || value > upper:
+		return "bad"
+	}
+	return "good"
+}
+
+// dataStatus returns the status (good, bad, late, unknown) for a data latency metric.
+// fifty and ninety are only used when they are known (not 0).
+func dataStatus(t time.Time, mean, fifty, ninety, lower, upper int32) string {
+	switch {
+	case t.Before(time.Now().UTC().Add(-lateAfter)):
+		return "late"
+	case lower == 0 && upper == 0:
+		return "unknown"
+	case mean < lower || mean > upper:
+		return "bad"
+	case fifty != 0 && (fifty < lower || fifty > upper):
+		return "bad"
+	case ninety != 0 && (ninety < lower || ninety > upper):
+		return "bad"
+	}
+	return "good"
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GeoNet/mtr/internal"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+An optional StatsD listener for application metrics.  Set MTR_STATSD_ADDR e.g., :8125
+to listen for StatsD over UDP.  Values are aggregated per minute and saved in the same
+way as metrics sent by mtrapp.
+
+Buckets are named using the application and instance conventions from mtrapp:
+
+    counters  <applicationID>.<typeID>:<count>|c[|@<sample rate>]
+    gauges    <applicationID>.<instanceID>.<typeID>:<value>|g
+    timers    <applicationID>.<sourceID>:<milliseconds>|ms
+
+typeID is the numeric type or the name from app.type e.g., MsgRx or 1201.  instanceID
+and sourceID may contain dots e.g., a host name or package.func.
+
+Counters are summed, gauges keep the last value, and timers are aggregated to count,
+average, fifty, and ninety percentiles like mtrapp.
+*/
+
+type statsdCounter struct {
+	applicationID, typeID string
+}
+
+type statsdGauge struct {
+	applicationID, instanceID, typeID string
+}
+
+type statsdTimer struct {
+	applicationID, sourceID string
+}
+
+type statsdMinute struct {
+	counters map[statsdCounter]float64
+	gauges   map[statsdGauge]float64
+	timers   map[statsdTimer][]int
+}
+
+func newStatsdMinute() statsdMinute {
+	return statsdMinute{
+		counters: make(map[statsdCounter]float64),
+		gauges:   make(map[statsdGauge]float64),
+		timers:   make(map[statsdTimer][]int),
+	}
+}
+
+// statsd listens for StatsD packets on addr.  Does not return unless there is an error.
+func statsd(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lines := make(chan string, 1000)
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				log.Printf("statsd: %s", err)
+				continue
+			}
+
+			for _, l := range strings.Split(string(buf[:n]), "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					select {
+					case lines <- l:
+					default:
+						// drop metrics rather than block the reader.
+					}
+				}
+			}
+		}
+	}()
+
+	// flush on minute boundaries so that each save covers the minute it is stamped with.
+	m := newStatsdMinute()
+	last := time.Now().UTC().Truncate(time.Minute)
+	flush := time.After(last.Add(time.Minute).Sub(time.Now().UTC()))
+
+	log.Printf("listening for statsd on %s", addr)
+
+	for {
+		select {
+		case l := <-lines:
+			if err := m.add(l); err != nil {
+				log.Printf("statsd: %s", err)
+			}
+		case <-flush:
+			go m.save(last)
+			m = newStatsdMinute()
+			last = time.Now().UTC().Truncate(time.Minute)
+			flush = time.After(last.Add(time.Minute).Sub(time.Now().UTC()))
+		}
+	}
+}
+
+// add parses a StatsD line and adds it to the minute.
+func (m *statsdMinute) add(line string) error {
+	i := strings.LastIndex(line, ":")
+	if i == -1 {
+		return fmt.Errorf("invalid line %s", line)
+	}
+
+	bucket := line[:i]
+	parts := strings.Split(line[i+1:], "|")
+
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid line %s", line)
+	}
+
+	v, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %s", line)
+	}
+
+	rate := 1.0
+	if len(parts) > 2 && strings.HasPrefix(parts[2], "@") {
+		if rate, err = strconv.ParseFloat(parts[2][1:], 64); err != nil || rate <= 0 || rate > 1 {
+			return fmt.Errorf("invalid sample rate %s", line)
+		}
+	}
+
+	b := strings.Split(bucket, ".")
+
+	switch parts[1] {
+	case "c":
+		if len(b) != 2 || b[0] == "" || b[1] == "" {
+			return fmt.Errorf("invalid counter bucket %s", bucket)
+		}
+		m.counters[statsdCounter{applicationID: b[0], typeID: b[1]}] += v / rate
+	case "g":
+		if len(b) < 3 || b[0] == "" || b[len(b)-1] == "" {
+			return fmt.Errorf("invalid gauge bucket %s", bucket)
+		}
+		k := statsdGauge{
+			applicationID: b[0],
+			instanceID:    strings.Join(b[1:len(b)-1], "."),
+			typeID:        b[len(b)-1],
+		}
+		// a sign indicates a change to the current value.
+		if strings.HasPrefix(parts[0], "+") || strings.HasPrefix(parts[0], "-") {
+			m.gauges[k] += v
+		} else {
+			m.gauges[k] = v
+		}
+	case "ms":
+		if len(b) < 2 || b[0] == "" {
+			return fmt.Errorf("invalid timer bucket %s", bucket)
+		}
+		k := statsdTimer{applicationID: b[0], sourceID: strings.Join(b[1:], ".")}
+		m.timers[k] = append(m.timers[k], int(v))
+	default:
+		return fmt.Errorf("unsupported type %s", line)
+	}
+
+	return nil
+}
+
+// save saves the aggregated values for the minute starting at t.
+func (m statsdMinute) save(t time.Time) {
+	types := make(map[string]int)
+
+	for k, v := range m.counters {
+		var a applicationCounter
+		var err error
+
+		if a.typePK, err = appTypePK(k.typeID, types); err != nil {
+			log.Printf("statsd: %s", err)
+			continue
+		}
+
+		a.applicationID = k.applicationID
+		a.t = t
+		a.c = int(math.Floor(v + 0.5))
+
+		if res := a.application.load(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+			continue
+		}
+
+		if res := a.insert(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+		}
+	}
+
+	for k, v := range m.gauges {
+		var a applicationMetric
+		var err error
+
+		if a.typePK, err = appTypePK(k.typeID, types); err != nil {
+			log.Printf("statsd: %s", err)
+			continue
+		}
+
+		a.applicationID = k.applicationID
+		a.instanceID = k.instanceID
+		a.t = t
+		a.value = int64(math.Floor(v + 0.5))
+
+		if res := a.application.load(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+			continue
+		}
+
+		if res := a.applicationInstance.load(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+			continue
+		}
+
+		if res := a.insert(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+		}
+	}
+
+	for k, v := range m.timers {
+		var a applicationTimer
+
+		a.applicationID = k.applicationID
+		a.sourceID = k.sourceID
+		a.t = t
+		a.count = len(v)
+
+		var sum int
+		for _, i := range v {
+			sum += i
+		}
+
+		a.average = sum / a.count
+		a.fifty = internal.Percentile(0.5, v)
+		a.ninety = internal.Percentile(0.9, v)
+
+		if res := a.application.load(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+			continue
+		}
+
+		if res := a.applicationSource.load(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+			continue
+		}
+
+		if res := a.insert(); !res.Ok {
+			log.Printf("statsd: %s", res.Msg)
+		}
+	}
+}
@@ -23,8 +23,26 @@ func (t *tag) save(r *http.Request) *weft.Result {
 		return res
 	}
 
-	if _, err := db.Exec(`INSERT INTO mtr.tag(tag) VALUES($1)`,
-		strings.TrimPrefix(r.URL.Path, "/tag/")); err != nil {
+	tg := strings.TrimPrefix(r.URL.Path, "/tag/")
+
+	// namespaced tags must use a known namespace.
+	var namespacePK sql.NullInt64
+
+	if namespace, value := splitTag(tg); namespace != "" {
+		if value == "" {
+			return weft.BadRequest("empty tag value")
+		}
+
+		pk, res := tagNamespacePK(namespace)
+		if !res.Ok {
+			return res
+		}
+
+		namespacePK = sql.NullInt64{Int64: int64(pk), Valid: true}
+	}
+
+	if _, err := db.Exec(`INSERT INTO mtr.tag(tag, namespacePK) VALUES($1, $2)`,
+		tg, namespacePK); err != nil {
 		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
 			//	no-op.  Nothing to update.
 		} else {
@@ -74,15 +92,24 @@ func (t *tag) loadPK(r *http.Request) *weft.Result {
 	return t.pk
 }
 
+// all returns all tags.  The optional query parameter namespace limits the tags to
+// those in the namespace.
 func (t *tag) all(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
-	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+	if res := weft.CheckQuery(r, []string{}, []string{"namespace"}); !res.Ok {
 		return res
 	}
 
 	var err error
 	var rows *sql.Rows
 
-	if rows, err = dbR.Query(`SELECT tag FROM mtr.tag ORDER BY TAG ASC`); err != nil {
+	switch namespace := r.URL.Query().Get("namespace"); namespace {
+	case "":
+		rows, err = dbR.Query(`SELECT tag FROM mtr.tag ORDER BY TAG ASC`)
+	default:
+		rows, err = dbR.Query(`SELECT tag FROM mtr.tag JOIN mtr.tag_namespace USING (namespacePK)
+			WHERE namespace = $1 ORDER BY TAG ASC`, namespace)
+	}
+	if err != nil {
 		return weft.InternalServerError(err)
 	}
 	defer rows.Close()
@@ -96,6 +123,8 @@ func (t *tag) all(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result
 			return weft.InternalServerError(err)
 		}
 
+		t.Namespace, t.Value = splitTag(t.Tag)
+
 		ts.Result = append(ts.Result, &t)
 	}
 
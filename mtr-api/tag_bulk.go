@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/GeoNet/weft"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+tagBulk adds (PUT) or removes (DELETE) a tag for all field metrics and data latencies
+matching a selector.  The selector is one or more of the query parameters:
+
+	modelID      - field metrics for devices with the model.
+	typeID       - field metrics or data latencies with the type.
+	devicePrefix - field metrics for devices with deviceID starting with the prefix.
+	sitePrefix   - data latencies for sites with siteID starting with the prefix.
+	bbox         - field metrics or data latencies in the bbox.
+	query        - field metrics or data latencies matching a tag query e.g., owner:ops AND NOT decommissioned
+
+Only metrics for active devices and sites are selected unless the state query parameter is used.
+
+All changes are made in one transaction.  GET, with the same selector, is a dry run.  The
+response is a JSON report of the number of metrics matched and the tag links that PUT would
+create and DELETE would remove.
+*/
+type tagBulk struct {
+	tag
+	field, data bool // true if the selector applies to field metrics, data latencies.
+	spatial     spatialQuery
+	state       stateFilter
+	expr        tagExpr
+	report      tagBulkReport
+}
+
+type tagBulkCount struct {
+	Matched int64 `json:"matched"`
+	Created int64 `json:"created"`
+	Removed int64 `json:"removed"`
+}
+
+type tagBulkReport struct {
+	Tag         string       `json:"tag"`
+	FieldMetric tagBulkCount `json:"fieldMetric"`
+	DataLatency tagBulkCount `json:"dataLatency"`
+}
+
+// read reads the selector from the request.
+func (t *tagBulk) read(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"tag"}, []string{"modelID", "typeID", "devicePrefix",
+		"sitePrefix", "bbox", "query", "state"}); !res.Ok {
+		return res
+	}
+
+	v := r.URL.Query()
+
+	if v.Get("modelID") == "" && v.Get("typeID") == "" && v.Get("devicePrefix") == "" &&
+		v.Get("sitePrefix") == "" && v.Get("bbox") == "" && v.Get("query") == "" {
+		return weft.BadRequest("at least one of modelID, typeID, devicePrefix, sitePrefix, bbox, or query is required")
+	}
+
+	var err error
+
+	t.field = v.Get("sitePrefix") == ""
+	t.data = v.Get("modelID") == "" && v.Get("devicePrefix") == ""
+
+	if typeID := v.Get("typeID"); typeID != "" {
+		_, f := fieldTypes[typeID]
+		_, d := dataTypes[typeID]
+
+		if !f && !d {
+			return weft.BadRequest("invalid type " + typeID)
+		}
+
+		t.field = t.field && f
+		t.data = t.data && d
+	}
+
+	if res := t.spatial.read(r); !res.Ok {
+		return res
+	}
+
+	if res := t.state.read(r); !res.Ok {
+		return res
+	}
+
+	if q := v.Get("query"); q != "" {
+		if t.expr, err = parseTagQuery(q); err != nil {
+			return weft.BadRequest(err.Error())
+		}
+	}
+
+	return t.tag.loadPK(r)
+}
+
+func (t *tagBulk) save(r *http.Request) *weft.Result {
+	if res := t.read(r); !res.Ok {
+		return res
+	}
+
+	metrics, err := t.metrics(r)
+	if err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	for _, m := range metrics {
+		if res := t.apply(txn, r.Method, m); !res.Ok {
+			txn.Rollback()
+			return res
+		}
+	}
+
+	if err = txn.Commit(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// jsonV1 writes a report of the metrics matching the selector and the tag links that
+// PUT would create and DELETE would remove.  Nothing is changed.
+func (t *tagBulk) jsonV1(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := t.read(r); !res.Ok {
+		return res
+	}
+
+	metrics, err := t.metrics(r)
+	if err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	t.report.Tag = r.URL.Query().Get("tag")
+
+	for _, m := range metrics {
+		args := append(m.args, t.tagPK)
+		tagged := `EXISTS (SELECT 1 FROM ` + m.tagTable + ` x
+			WHERE x.` + m.pkCol + ` = m.` + m.pkCol + ` AND x.typePK = m.typePK AND x.tagPK = $` + strconv.Itoa(len(args)) + `::integer)`
+
+		if err = dbR.QueryRow(`SELECT count(*), count(*) FILTER (WHERE NOT `+tagged+`), count(*) FILTER (WHERE `+tagged+`)
+			FROM (`+m.q+`) m`, args...).Scan(&m.count.Matched, &m.count.Created, &m.count.Removed); err != nil {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	by, err := json.Marshal(t.report)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/json;version=1")
+
+	return &weft.StatusOK
+}
+
+// tagBulkMetrics is the query, and its args, for the field metrics or data latencies matching a selector.
+type tagBulkMetrics struct {
+	tagTable string // the tag table e.g., field.metric_tag
+	pkCol    string // the device or site pk column in the tag table.
+	q        string
+	args     []interface{}
+	count    *tagBulkCount
+}
+
+// metrics returns the queries for the field metrics and data latencies that the selector in r applies to.
+func (t *tagBulk) metrics(r *http.Request) ([]tagBulkMetrics, error) {
+	v := r.URL.Query()
+
+	var metrics []tagBulkMetrics
+
+	if t.field {
+		m := tagBulkMetrics{tagTable: "field.metric_tag", pkCol: "devicePK", count: &t.report.FieldMetric}
+
+		var err error
+
+		if m.q, m.args, err = t.selectSQL(fieldMetrics, "devicePK, typePK", "modelID", v.Get("modelID"),
+			"deviceID", v.Get("devicePrefix"), v.Get("typeID"),
+			`EXISTS (SELECT 1 FROM field.metric_tag JOIN mtr.tag USING (tagPK)
+			WHERE devicePK = s.devicePK AND typePK = s.typePK AND tag LIKE $%d)`); err != nil {
+			return nil, err
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	if t.data {
+		m := tagBulkMetrics{tagTable: "data.latency_tag", pkCol: "sitePK", count: &t.report.DataLatency}
+
+		var err error
+
+		if m.q, m.args, err = t.selectSQL(dataLatencies, "sitePK, typePK", "", "",
+			"siteID", v.Get("sitePrefix"), v.Get("typeID"),
+			`EXISTS (SELECT 1 FROM data.latency_tag JOIN mtr.tag USING (tagPK)
+			WHERE sitePK = s.sitePK AND typePK = s.typePK AND tag LIKE $%d)`); err != nil {
+			return nil, err
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	return metrics, nil
+}
+
+// fieldMetrics selects the field metrics, with the device and type.  Metrics are found from the
+// summary view and the tag table; a metric saved since the view was last refreshed (every
+// 20 seconds) is selected after the next refresh.
+const fieldMetrics = `SELECT devicePK, typePK, deviceID, modelID, typeID, geom, state
+	FROM (SELECT devicePK, typePK FROM field.metric_summary
+		UNION SELECT devicePK, typePK FROM field.metric_tag) m
+	JOIN field.device USING (devicePK) JOIN field.model USING (modelPK) JOIN field.type USING (typePK)`
+
+// dataLatencies selects the data latencies, with the site and type, from the summary view and the tag table.
+const dataLatencies = `SELECT sitePK, typePK, siteID, typeID, geom, state
+	FROM (SELECT sitePK, typePK FROM data.latency_summary
+		UNION SELECT sitePK, typePK FROM data.latency_tag) m
+	JOIN data.site USING (sitePK) JOIN data.type USING (typePK)`
+
+// selectSQL returns a query selecting pk from the metrics selected by metrics for metrics matching the selector.
+// The selector is applied in the spatial query so nearest selects from the matching metrics.
+func (t *tagBulk) selectSQL(metrics, pk, modelCol, modelID, idCol, prefix, typeID, exists string) (string, []interface{}, error) {
+	var args []interface{}
+
+	where := []string{t.state.sql("state", &args)}
+
+	if modelID != "" {
+		args = append(args, modelID)
+		where = append(where, modelCol+" = $"+strconv.Itoa(len(args)))
+	}
+
+	if prefix != "" {
+		args = append(args, strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)+"%")
+		where = append(where, idCol+" LIKE $"+strconv.Itoa(len(args)))
+	}
+
+	if typeID != "" {
+		args = append(args, typeID)
+		where = append(where, "typeID = $"+strconv.Itoa(len(args)))
+	}
+
+	if t.expr != nil {
+		where = append(where, t.expr.sql(exists, &args))
+	}
+
+	q, err := t.spatial.selectSQL("s", pk, strings.Join(where, " AND "), &args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return `WITH s AS (` + metrics + `)
+		SELECT ` + pk + ` FROM (` + q + `) sp`, args, nil
+}
+
+// apply adds (PUT) or removes (DELETE) the tag for the metrics in m.
+func (t *tagBulk) apply(txn *sql.Tx, method string, m tagBulkMetrics) *weft.Result {
+	args := append(m.args, t.tagPK)
+	tagArg := "$" + strconv.Itoa(len(args)) + "::integer"
+
+	var err error
+
+	switch method {
+	case "PUT":
+		_, err = txn.Exec(`INSERT INTO `+m.tagTable+`(`+m.pkCol+`, typePK, tagPK)
+			SELECT `+m.pkCol+`, typePK, `+tagArg+` FROM (`+m.q+`) m
+			WHERE NOT EXISTS (SELECT 1 FROM `+m.tagTable+` x
+			WHERE x.`+m.pkCol+` = m.`+m.pkCol+` AND x.typePK = m.typePK AND x.tagPK = `+tagArg+`)`, args...)
+	default:
+		_, err = txn.Exec(`DELETE FROM `+m.tagTable+` x USING (`+m.q+`) m
+			WHERE x.`+m.pkCol+` = m.`+m.pkCol+` AND x.typePK = m.typePK AND x.tagPK = `+tagArg, args...)
+	}
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
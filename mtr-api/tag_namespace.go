@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"net/http"
+	"strings"
+)
+
+// tagNamespace is a namespace for tags e.g., site.  Namespaced tags are namespace:value e.g., site:TAUP
+type tagNamespace struct {
+	namespace string
+}
+
+// splitTag returns the namespace and value for tag.  namespace is empty
+// if the tag is not namespaced.
+func splitTag(tag string) (namespace, value string) {
+	if i := strings.Index(tag, ":"); i != -1 {
+		return tag[:i], tag[i+1:]
+	}
+
+	return "", tag
+}
+
+func tagNamespacePK(namespace string) (int, *weft.Result) {
+	var pk int
+
+	if err := dbR.QueryRow(`SELECT namespacePK FROM mtr.tag_namespace WHERE namespace = $1`, namespace).Scan(&pk); err != nil {
+		if err == sql.ErrNoRows {
+			return pk, weft.BadRequest("unknown tag namespace " + namespace)
+		}
+		return pk, weft.InternalServerError(err)
+	}
+
+	return pk, &weft.StatusOK
+}
+
+// save creates the namespace or updates the description.
+func (t *tagNamespace) save(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"namespace"}, []string{"description"}); !res.Ok {
+		return res
+	}
+
+	t.namespace = r.URL.Query().Get("namespace")
+
+	if t.namespace == "" || strings.ContainsAny(t.namespace, ": ") {
+		return weft.BadRequest("invalid namespace")
+	}
+
+	description := r.URL.Query().Get("description")
+
+	if _, err := db.Exec(`INSERT INTO mtr.tag_namespace(namespace, description) VALUES($1, $2)`,
+		t.namespace, description); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == errorUniqueViolation {
+			if _, err := db.Exec(`UPDATE mtr.tag_namespace SET description=$2 WHERE namespace=$1`,
+				t.namespace, description); err != nil {
+				return weft.InternalServerError(err)
+			}
+		} else {
+			return weft.InternalServerError(err)
+		}
+	}
+
+	return &weft.StatusOK
+}
+
+// delete deletes the namespace.  This cascades to the tags in the namespace.
+func (t *tagNamespace) delete(r *http.Request) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"namespace"}, []string{}); !res.Ok {
+		return res
+	}
+
+	if _, err := db.Exec(`DELETE FROM mtr.tag_namespace WHERE namespace = $1`,
+		r.URL.Query().Get("namespace")); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+func (t *tagNamespace) proto(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	rows, err := dbR.Query(`SELECT namespace, description FROM mtr.tag_namespace ORDER BY namespace ASC`)
+	if err != nil {
+		return weft.InternalServerError(err)
+	}
+	defer rows.Close()
+
+	var tr mtrpb.TagNamespaceResult
+
+	for rows.Next() {
+		var n mtrpb.TagNamespace
+
+		if err = rows.Scan(&n.Namespace, &n.Description); err != nil {
+			return weft.InternalServerError(err)
+		}
+
+		tr.Result = append(tr.Result, &n)
+	}
+
+	var by []byte
+	if by, err = proto.Marshal(&tr); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
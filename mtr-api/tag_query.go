@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"strings"
+)
+
+/*
+A tag query is a boolean expression of tags e.g.,
+
+    owner:ops AND region:taranaki AND NOT decommissioned
+    TAU* OR (site:WEL* AND NOT path:KAKA)
+
+Operators are AND, OR, and NOT (upper case) and parentheses.  NOT binds tighter than AND
+which binds tighter than OR.  Terms next to each other are ANDed.  * in a tag matches
+any characters.
+*/
+
+// tagExpr is a parsed tag query.  sql returns an SQL boolean expression for the query.
+// exists is a format string for a correlated sub query that is true when a metric
+// has a tag LIKE the parameter number given to the format e.g., $1
+type tagExpr interface {
+	sql(exists string, args *[]interface{}) string
+}
+
+type tagTerm struct {
+	pattern string // a LIKE pattern
+}
+
+type tagNot struct {
+	e tagExpr
+}
+
+type tagOp struct {
+	op   string // AND or OR
+	l, r tagExpr
+}
+
+func (t tagTerm) sql(exists string, args *[]interface{}) string {
+	*args = append(*args, t.pattern)
+	return fmt.Sprintf(exists, len(*args))
+}
+
+func (t tagNot) sql(exists string, args *[]interface{}) string {
+	return "(NOT " + t.e.sql(exists, args) + ")"
+}
+
+func (t tagOp) sql(exists string, args *[]interface{}) string {
+	return "(" + t.l.sql(exists, args) + " " + t.op + " " + t.r.sql(exists, args) + ")"
+}
+
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseTagQuery parses the tag query q.
+func parseTagQuery(q string) (tagExpr, error) {
+	p := tagParser{tokens: tokenizeTagQuery(q)}
+
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty tag query")
+	}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s in tag query", p.tokens[p.pos])
+	}
+
+	return e, nil
+}
+
+func tokenizeTagQuery(q string) []string {
+	var tokens []string
+	var t []rune
+
+	for _, c := range q {
+		switch c {
+		case '(', ')', ' ', '\t', '\n':
+			if len(t) > 0 {
+				tokens = append(tokens, string(t))
+				t = t[:0]
+			}
+			if c == '(' || c == ')' {
+				tokens = append(tokens, string(c))
+			}
+		default:
+			t = append(t, c)
+		}
+	}
+
+	if len(t) > 0 {
+		tokens = append(tokens, string(t))
+	}
+
+	return tokens
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) or() (tagExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "OR" {
+		p.pos++
+
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l = tagOp{op: "OR", l: l, r: r}
+	}
+
+	return l, nil
+}
+
+func (p *tagParser) and() (tagExpr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek() {
+		case "AND":
+			p.pos++
+		case "", "OR", ")":
+			return l, nil
+		}
+
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+
+		l = tagOp{op: "AND", l: l, r: r}
+	}
+}
+
+func (p *tagParser) not() (tagExpr, error) {
+	if p.peek() == "NOT" {
+		p.pos++
+
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+
+		return tagNot{e: e}, nil
+	}
+
+	return p.primary()
+}
+
+func (p *tagParser) primary() (tagExpr, error) {
+	t := p.peek()
+
+	switch t {
+	case "":
+		return nil, fmt.Errorf("unexpected end of tag query")
+	case "AND", "OR", ")":
+		return nil, fmt.Errorf("unexpected %s in tag query", t)
+	case "(":
+		p.pos++
+
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in tag query")
+		}
+		p.pos++
+
+		return e, nil
+	}
+
+	p.pos++
+
+	// escape LIKE special characters then convert * to %
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+
+	return tagTerm{pattern: r.Replace(t)}, nil
+}
+
+type tagQuery struct {
+	expr      tagExpr
+	state     stateFilter
+	tagResult mtrpb.TagSearchResult
+}
+
+// search returns a TagSearchResult of the field metrics and data latencies matching the
+// query parameter query.  Only metrics for active devices and sites are included unless
+// the state query param is used.
+func (t *tagQuery) search(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	if res := weft.CheckQuery(r, []string{"query"}, []string{"state"}); !res.Ok {
+		return res
+	}
+
+	var err error
+
+	if t.expr, err = parseTagQuery(r.URL.Query().Get("query")); err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	if res := t.state.read(r); !res.Ok {
+		return res
+	}
+
+	c1 := t.fieldMetric()
+	c2 := t.dataLatency()
+
+	resFinal := &weft.StatusOK
+
+	for res := range merge(c1, c2) {
+		if !res.Ok {
+			resFinal = res
+		}
+	}
+
+	if !resFinal.Ok {
+		return resFinal
+	}
+
+	var by []byte
+	if by, err = proto.Marshal(&t.tagResult); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	b.Write(by)
+
+	h.Set("Content-Type", "application/x-protobuf")
+
+	return &weft.StatusOK
+}
+
+func (t *tagQuery) fieldMetric() <-chan *weft.Result {
+	out := make(chan *weft.Result)
+	go func() {
+		defer close(out)
+
+		var args []interface{}
+
+		w := t.expr.sql(`EXISTS (SELECT 1 FROM field.metric_tag JOIN mtr.tag USING (tagPK)
+			WHERE devicePK = s.devicePK AND typePK = s.typePK AND tag LIKE $%d)`, &args)
+
+		summaries, err := fieldSummaries(w, args, t.state)
+		if err != nil {
+			out <- weft.InternalServerError(err)
+			return
+		}
+
+		t.tagResult.FieldMetric = summaries
+
+		out <- &weft.StatusOK
+	}()
+	return out
+}
+
+func (t *tagQuery) dataLatency() <-chan *weft.Result {
+	out := make(chan *weft.Result)
+	go func() {
+		defer close(out)
+
+		var args []interface{}
+
+		w := t.expr.sql(`EXISTS (SELECT 1 FROM data.latency_tag JOIN mtr.tag USING (tagPK)
+			WHERE sitePK = s.sitePK AND typePK = s.typePK AND tag LIKE $%d)`, &args)
+
+		summaries, err := dataSummaries(w, args, t.state)
+		if err != nil {
+			out <- weft.InternalServerError(err)
+			return
+		}
+
+		t.tagResult.DataLatency = summaries
+
+		out <- &weft.StatusOK
+	}()
+	return out
+}
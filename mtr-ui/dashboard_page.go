@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type dashboardPage struct {
+	page
+	MtrApiUrl  string
+	Dashboards []*mtrpb.Dashboard
+	Dashboard  *mtrpb.Dashboard // the dashboard being shown, nil when listing dashboards.
+}
+
+// dashboardPageHandler lists the dashboards for /dashboard or shows the panels for /dashboard/{name}
+// using the SVG plots from the mtr-api.
+func dashboardPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var err error
+
+	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+		return res
+	}
+
+	p := dashboardPage{}
+	p.Border.Title = "GeoNet MTR - Dashboards"
+	p.MtrApiUrl = mtrApiUrl.String()
+
+	if err = p.populateTags(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/dashboard"), "/")
+
+	u := *mtrApiUrl
+	u.Path = "/dashboard"
+
+	if name != "" {
+		u.RawQuery = url.Values{"name": {name}}.Encode()
+	}
+
+	if p.Dashboards, err = getDashboards(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if name != "" {
+		if len(p.Dashboards) != 1 {
+			return &weft.NotFound
+		}
+
+		p.Dashboard = p.Dashboards[0]
+		p.Border.Title = "GeoNet MTR - " + p.Dashboard.Name
+	}
+
+	if err = dashboardTemplate.ExecuteTemplate(b, "border", p); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// getDashboards returns the dashboards from urlString.
+func getDashboards(urlString string) ([]*mtrpb.Dashboard, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var dr mtrpb.DashboardResult
+
+	if err = proto.Unmarshal(b, &dr); err != nil {
+		return nil, err
+	}
+
+	return dr.Result, nil
+}
@@ -401,6 +401,8 @@ func incDataCount(m map[string]idCount, r *mtrpb.DataLatencySummary) {
 
 func dataStatusString(r *mtrpb.DataLatencySummary) string {
 	switch {
+	case r.Status == "impacted":
+		return "impacted"
 	case r.Upper == 0 && r.Lower == 0:
 		return "unknown"
 	case allGood(r):
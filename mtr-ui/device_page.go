@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type fieldDevicePage struct {
+	page
+	MtrApiUrl string
+	Device    *mtrpb.FieldDevice
+	Model     *mtrpb.FieldModel
+	Metrics   []deviceMetric
+	Missing   []string // typeIDs expected for the model with no metrics for the device.
+	States    []deviceState
+	Renames   []deviceRename
+	Locations []deviceLocation
+}
+
+type deviceRename struct {
+	OldID     string
+	NewID     string
+	Time      string
+	ChangedBy string
+}
+
+type deviceLocation struct {
+	Latitude  float64
+	Longitude float64
+	Time      string
+	ChangedBy string
+}
+
+type deviceState struct {
+	State     string
+	Time      string
+	ChangedBy string
+}
+
+type deviceMetric struct {
+	TypeID string
+	Value  int32
+	Lower  int32
+	Upper  int32
+	Time   string
+}
+
+func fieldDevicePageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var err error
+
+	if res := weft.CheckQuery(r, []string{"deviceID"}, []string{}); !res.Ok {
+		return res
+	}
+
+	p := fieldDevicePage{}
+	p.MtrApiUrl = mtrApiUrl.String()
+	p.Border.Title = "GeoNet MTR"
+
+	if err = p.populateTags(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	u := *mtrApiUrl
+	u.Path = "/field/device"
+	u.RawQuery = url.Values{"deviceID": {r.URL.Query().Get("deviceID")}, "state": {"all"}}.Encode()
+
+	if p.Device, err = getFieldDevice(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if p.Device == nil {
+		return &weft.NotFound
+	}
+
+	u = *mtrApiUrl
+	u.Path = "/field/device/state"
+	u.RawQuery = url.Values{"deviceID": {p.Device.DeviceID}}.Encode()
+
+	if p.States, err = getDeviceStates(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	u.Path = "/field/device/rename"
+
+	if p.Renames, err = getDeviceRenames(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	u.Path = "/field/device/location"
+
+	if p.Locations, err = getDeviceLocations(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	u = *mtrApiUrl
+	u.Path = "/field/model"
+
+	if p.Model, err = getFieldModel(u.String(), p.Device.ModelID); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	found := make(map[string]bool)
+
+	for _, m := range p.Device.Metrics {
+		found[m.TypeID] = true
+		p.Metrics = append(p.Metrics, deviceMetric{
+			TypeID: m.TypeID,
+			Value:  m.Value,
+			Lower:  m.Lower,
+			Upper:  m.Upper,
+			Time:   time.Unix(m.Seconds, 0).UTC().Format(time.RFC3339),
+		})
+	}
+
+	if p.Model != nil {
+		for _, a := range p.Model.Attributes {
+			if a.Key != "expectedMetrics" {
+				continue
+			}
+			for _, t := range strings.Split(a.Value, ",") {
+				if !found[t] {
+					p.Missing = append(p.Missing, t)
+				}
+			}
+		}
+	}
+
+	if err = fieldDeviceTemplate.ExecuteTemplate(b, "border", p); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// getFieldDevice returns the first device from urlString or nil if there are none.
+func getFieldDevice(urlString string) (*mtrpb.FieldDevice, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var fdr mtrpb.FieldDeviceResult
+
+	if err = proto.Unmarshal(b, &fdr); err != nil {
+		return nil, err
+	}
+
+	if len(fdr.Result) == 0 {
+		return nil, nil
+	}
+
+	return fdr.Result[0], nil
+}
+
+// getDeviceStates returns the lifecycle state changes from urlString.
+func getDeviceStates(urlString string) ([]deviceState, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var lr mtrpb.LifecycleStateResult
+
+	if err = proto.Unmarshal(b, &lr); err != nil {
+		return nil, err
+	}
+
+	var s []deviceState
+
+	for _, v := range lr.Result {
+		s = append(s, deviceState{
+			State:     v.State,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		})
+	}
+
+	return s, nil
+}
+
+// getDeviceRenames returns the renames from urlString.
+func getDeviceRenames(urlString string) ([]deviceRename, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var rr mtrpb.RenameResult
+
+	if err = proto.Unmarshal(b, &rr); err != nil {
+		return nil, err
+	}
+
+	var n []deviceRename
+
+	for _, v := range rr.Result {
+		n = append(n, deviceRename{
+			OldID:     v.OldID,
+			NewID:     v.NewID,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		})
+	}
+
+	return n, nil
+}
+
+// getDeviceLocations returns the locations from urlString.
+func getDeviceLocations(urlString string) ([]deviceLocation, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var lr mtrpb.LocationResult
+
+	if err = proto.Unmarshal(b, &lr); err != nil {
+		return nil, err
+	}
+
+	var l []deviceLocation
+
+	for _, v := range lr.Result {
+		l = append(l, deviceLocation{
+			Latitude:  v.Latitude,
+			Longitude: v.Longitude,
+			Time:      time.Unix(v.Seconds, 0).UTC().Format(time.RFC3339),
+			ChangedBy: v.ChangedBy,
+		})
+	}
+
+	return l, nil
+}
+
+// getFieldModel returns modelID from the models at urlString or nil if it is not found.
+func getFieldModel(urlString, modelID string) (*mtrpb.FieldModel, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var fmr mtrpb.FieldModelResult
+
+	if err = proto.Unmarshal(b, &fmr); err != nil {
+		return nil, err
+	}
+
+	for _, m := range fmr.Result {
+		if m.ModelID == modelID {
+			return m, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -441,6 +441,8 @@ func incFieldCount(m map[string]idCount, r *mtrpb.FieldMetricSummary) {
 
 func fieldStatusString(r *mtrpb.FieldMetricSummary) string {
 	switch {
+	case r.Status == "impacted":
+		return "impacted"
 	case r.Upper == 0 && r.Lower == 0:
 		return "unknown"
 	case r.Value >= r.Lower && r.Value <= r.Upper:
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"github.com/GeoNet/mtr/mtrpb"
+	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type incidentPage struct {
+	page
+	Incidents []incidentRow
+}
+
+type incidentRow struct {
+	DeviceID string
+	SiteID   string
+	TypeID   string
+	Start    string
+	End      string // empty if the incident is ongoing.
+	Duration string
+	Worst    int32
+	Status   string
+	Group    int32
+	Tags     string
+}
+
+// incidentPageHandler lists incidents.  The optional deviceID, siteID, typeID, and since
+// query params are passed to the mtr-api.
+func incidentPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
+	var err error
+
+	if res := weft.CheckQuery(r, []string{}, []string{"deviceID", "siteID", "typeID", "since"}); !res.Ok {
+		return res
+	}
+
+	p := incidentPage{}
+	p.Border.Title = "GeoNet MTR - Incidents"
+
+	if err = p.populateTags(); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	q := url.Values{}
+
+	for _, k := range []string{"deviceID", "siteID", "typeID", "since"} {
+		if v := r.URL.Query().Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+
+	u := *mtrApiUrl
+	u.Path = "/incident"
+	u.RawQuery = q.Encode()
+
+	if p.Incidents, err = getIncidents(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if err = incidentTemplate.ExecuteTemplate(b, "border", p); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	return &weft.StatusOK
+}
+
+// getIncidents returns the incidents from urlString.
+func getIncidents(urlString string) ([]incidentRow, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var ir mtrpb.IncidentResult
+
+	if err = proto.Unmarshal(b, &ir); err != nil {
+		return nil, err
+	}
+
+	var n []incidentRow
+
+	for _, v := range ir.Result {
+		i := incidentRow{
+			DeviceID: v.DeviceID,
+			SiteID:   v.SiteID,
+			TypeID:   v.TypeID,
+			Start:    time.Unix(v.Start, 0).UTC().Format(time.RFC3339),
+			Duration: (time.Duration(v.Duration) * time.Second).String(),
+			Worst:    v.Worst,
+			Status:   v.Status,
+			Group:    v.Group,
+			Tags:     strings.Join(v.Tags, ", "),
+		}
+
+		if v.End != 0 {
+			i.End = time.Unix(v.End, 0).UTC().Format(time.RFC3339)
+		}
+
+		n = append(n, i)
+	}
+
+	return n, nil
+}
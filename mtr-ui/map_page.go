@@ -3,8 +3,12 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -12,13 +16,23 @@ type mapPage struct {
 	page
 	MtrApiUrl string
 	TypeID    string
+	Summary   string // the api path for the summary map e.g., field/metric/summary
+	Bbox      string // the bbox for the map e.g., 165,-48,179,-34
+	Preset    string // the name of the bbox preset if one is in use.
+	Presets   []*mtrpb.MapBbox
+	ZoomIn    string
+	ZoomOut   string
+	North     string
+	South     string
+	East      string
+	West      string
 }
 
 func mapPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Result {
 
 	var err error
 
-	if res := weft.CheckQuery(r, []string{}, []string{}); !res.Ok {
+	if res := weft.CheckQuery(r, []string{}, []string{"bbox"}); !res.Ok {
 		return res
 	}
 
@@ -34,15 +48,177 @@ func mapPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Resul
 	switch s {
 	case "":
 		p.TypeID = "voltage"
+		p.Summary = "field/metric/summary"
 	case "voltage", "conn", "ping":
 		p.TypeID = s
+		p.Summary = "field/metric/summary"
+	case "latency.strong", "latency.weak", "latency.gnss.1hz", "latency.tsunami":
+		p.TypeID = s
+		p.Summary = "data/latency/summary"
 	default:
 		return weft.InternalServerError(fmt.Errorf("Unknown map type"))
 	}
 
+	u := *mtrApiUrl
+	u.Path = "/map/bbox"
+	if p.Presets, err = getMapBboxes(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	if res := p.setBbox(r.URL.Query().Get("bbox")); !res.Ok {
+		return res
+	}
+
 	if err = mapTemplate.ExecuteTemplate(b, "border", p); err != nil {
 		return weft.InternalServerError(err)
 	}
 
 	return &weft.StatusOK
 }
+
+// setBbox sets the map bbox and the zoom and pan bboxes.  bbox can be the name of
+// a preset or llx,lly,urx,ury.  The first preset is used if bbox is empty.
+func (p *mapPage) setBbox(bbox string) *weft.Result {
+	if bbox == "" && len(p.Presets) > 0 {
+		bbox = p.Presets[0].Name
+	}
+
+	p.Bbox = bbox
+
+	for _, v := range p.Presets {
+		if v.Name == bbox {
+			p.Preset = v.Name
+			p.Bbox = v.Bbox
+		}
+	}
+
+	m, err := newMapBounds(p.Bbox)
+	if err != nil {
+		return weft.BadRequest(err.Error())
+	}
+
+	p.ZoomIn = m.zoom(0.5).String()
+	p.ZoomOut = m.zoom(2.0).String()
+	p.North = m.pan(0.0, 0.5).String()
+	p.South = m.pan(0.0, -0.5).String()
+	p.East = m.pan(0.5, 0.0).String()
+	p.West = m.pan(-0.5, 0.0).String()
+
+	return &weft.StatusOK
+}
+
+// mapBounds is a map bbox.  urx is always greater than llx, for a bbox that crosses
+// 180 urx is greater than 180.
+type mapBounds struct {
+	llx, lly, urx, ury float64
+}
+
+const (
+	maxLatitude = 85.0
+	maxWidth    = 180.0
+	minWidth    = 0.01
+)
+
+func newMapBounds(bbox string) (mapBounds, error) {
+	var m mapBounds
+
+	c := strings.Split(bbox, ",")
+	if len(c) != 4 {
+		return m, fmt.Errorf("invalid bbox: %s", bbox)
+	}
+
+	var v [4]float64
+
+	for i := range c {
+		var err error
+		if v[i], err = strconv.ParseFloat(c[i], 64); err != nil {
+			return m, fmt.Errorf("invalid bbox: %s", bbox)
+		}
+	}
+
+	m = mapBounds{llx: v[0], lly: v[1], urx: v[2], ury: v[3]}
+
+	if m.lly >= m.ury || m.lly < -maxLatitude || m.ury > maxLatitude {
+		return m, fmt.Errorf("invalid bbox: %s", bbox)
+	}
+
+	if m.urx <= m.llx {
+		m.urx += 360.0
+	}
+
+	return m, nil
+}
+
+// zoom returns m scaled by f around its centre.  f < 1 zooms in.
+func (m mapBounds) zoom(f float64) mapBounds {
+	w := math.Min(math.Max((m.urx-m.llx)*f, minWidth), maxWidth)
+	h := math.Min(math.Max((m.ury-m.lly)*f, minWidth), 2*maxLatitude)
+
+	x := m.llx + (m.urx-m.llx)/2.0
+	y := m.lly + (m.ury-m.lly)/2.0
+
+	return mapBounds{llx: x - w/2.0, lly: y - h/2.0, urx: x + w/2.0, ury: y + h/2.0}.normalise()
+}
+
+// pan returns m moved by the fraction x of its width and y of its height.
+func (m mapBounds) pan(x, y float64) mapBounds {
+	dx := (m.urx - m.llx) * x
+	dy := (m.ury - m.lly) * y
+
+	return mapBounds{llx: m.llx + dx, lly: m.lly + dy, urx: m.urx + dx, ury: m.ury + dy}.normalise()
+}
+
+// normalise keeps llx in -180 to 180 and the latitudes in the map range.
+func (m mapBounds) normalise() mapBounds {
+	for m.llx >= 180.0 {
+		m.llx -= 360.0
+		m.urx -= 360.0
+	}
+
+	for m.llx < -180.0 {
+		m.llx += 360.0
+		m.urx += 360.0
+	}
+
+	if m.lly < -maxLatitude {
+		m.ury += -maxLatitude - m.lly
+		m.lly = -maxLatitude
+	}
+
+	if m.ury > maxLatitude {
+		m.lly -= m.ury - maxLatitude
+		m.ury = maxLatitude
+	}
+
+	return m
+}
+
+// String returns m as llx,lly,urx,ury.  For a bbox that crosses 180 urx is in the
+// range -180 to 0.
+func (m mapBounds) String() string {
+	urx := m.urx
+	if urx > 180.0 {
+		urx -= 360.0
+	}
+
+	return strings.Join([]string{coord(m.llx), coord(m.lly), coord(urx), coord(m.ury)}, ",")
+}
+
+func coord(v float64) string {
+	return strconv.FormatFloat(math.Floor(v*1000.0+0.5)/1000.0, 'f', -1, 64)
+}
+
+func getMapBboxes(urlString string) ([]*mtrpb.MapBbox, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var mr mtrpb.MapBboxResult
+
+	if err = proto.Unmarshal(b, &mr); err != nil {
+		return nil, err
+	}
+
+	return mr.Result, nil
+}
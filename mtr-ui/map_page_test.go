@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMapBounds(t *testing.T) {
+	in := []struct {
+		bbox, zoomIn, zoomOut, north, east, west string
+	}{
+		{"165,-48,179,-34", "168.5,-44.5,175.5,-37.5", "158,-55,-174,-27", "165,-41,179,-27", "172,-48,-174,-34", "158,-48,172,-34"},
+		// crosses 180
+		{"165,-48,-175,-28", "170,-43,180,-33", "155,-58,-165,-18", "165,-38,-175,-18", "175,-48,-165,-28", "155,-48,175,-28"},
+		// zoom out is limited to 180 degrees wide and pan north to 85
+		{"100,0,-160,80", "125,20,175,60", "60,-75,-120,85", "100,5,-160,85", "150,0,-110,80", "50,0,150,80"},
+	}
+
+	for _, v := range in {
+		m, err := newMapBounds(v.bbox)
+		if err != nil {
+			t.Errorf("%s: %s", v.bbox, err)
+			continue
+		}
+
+		if s := m.zoom(0.5).String(); s != v.zoomIn {
+			t.Errorf("%s zoom in expected %s got %s", v.bbox, v.zoomIn, s)
+		}
+
+		if s := m.zoom(2.0).String(); s != v.zoomOut {
+			t.Errorf("%s zoom out expected %s got %s", v.bbox, v.zoomOut, s)
+		}
+
+		if s := m.pan(0.0, 0.5).String(); s != v.north {
+			t.Errorf("%s north expected %s got %s", v.bbox, v.north, s)
+		}
+
+		if s := m.pan(0.5, 0.0).String(); s != v.east {
+			t.Errorf("%s east expected %s got %s", v.bbox, v.east, s)
+		}
+
+		if s := m.pan(-0.5, 0.0).String(); s != v.west {
+			t.Errorf("%s west expected %s got %s", v.bbox, v.west, s)
+		}
+	}
+
+	for _, s := range []string{"", "NewZealand", "165,-48,179", "165,-34,179,-48", "165,-90,179,-34"} {
+		if _, err := newMapBounds(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
@@ -32,9 +32,12 @@ func newSearchPage(apiUrl *url.URL) (s *searchPage, err error) {
 	return s, nil
 }
 
+// matchingMetrics finds the metrics matching tagQuery.  tagQuery can be a tag or
+// a boolean expression of tags e.g., owner:ops AND NOT decommissioned
 func (s *searchPage) matchingMetrics(tagQuery string) (err error) {
 	u := *s.MtrApiUrl
-	u.Path = "/tag/" + tagQuery
+	u.Path = "/search"
+	u.RawQuery = url.Values{"query": {tagQuery}}.Encode()
 	if s.MatchingMetrics, err = getMatchingMetrics(u.String()); err != nil {
 		return err
 	}
@@ -38,6 +38,7 @@ func init() {
 	mux.HandleFunc("/field", weft.MakeHandlerPage(fieldPageHandler))
 	mux.HandleFunc("/field/metrics", weft.MakeHandlerPage(fieldMetricsPageHandler))
 	mux.HandleFunc("/field/devices", weft.MakeHandlerPage(fieldDevicesPageHandler))
+	mux.HandleFunc("/field/device", weft.MakeHandlerPage(fieldDevicePageHandler))
 	mux.HandleFunc("/field/plot", weft.MakeHandlerPage(fieldPlotPageHandler))
 	mux.HandleFunc("/data", weft.MakeHandlerPage(dataPageHandler))
 	mux.HandleFunc("/data/sites", weft.MakeHandlerPage(dataSitesPageHandler))
@@ -48,6 +49,9 @@ func init() {
 	mux.HandleFunc("/search", weft.MakeHandlerPage(searchHandler))
 	mux.HandleFunc("/tag", weft.MakeHandlerPage(tagPageHandler))
 	mux.HandleFunc("/tag/", weft.MakeHandlerPage(tagPageHandler))
+	mux.HandleFunc("/incident", weft.MakeHandlerPage(incidentPageHandler))
+	mux.HandleFunc("/dashboard", weft.MakeHandlerPage(dashboardPageHandler))
+	mux.HandleFunc("/dashboard/", weft.MakeHandlerPage(dashboardPageHandler))
 }
 
 func main() {
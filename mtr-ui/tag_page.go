@@ -3,16 +3,25 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/GeoNet/mtr/mtrpb"
 	"github.com/GeoNet/weft"
+	"github.com/golang/protobuf/proto"
 	"net/http"
 	"strings"
 )
 
 type tagPage struct {
 	page
-	Path    string
-	TagTabs []string
-	Tags    []string
+	Path       string
+	TagTabs    []string
+	Namespaces []*mtrpb.TagNamespace
+	Namespace  *mtrpb.TagNamespace // the namespace being shown, nil for tags without a namespace.
+	Tags       []tagLink
+}
+
+type tagLink struct {
+	Tag   string // the full tag e.g., site:TAUP
+	Value string // the tag for display without the namespace e.g., TAUP
 }
 
 var tagGrouper = []string{"ABC", "DEF", "GHI", "JKL", "MNO", "POR", "STU", "VWXYZ", "0123456789"}
@@ -37,6 +46,36 @@ func tagPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Resul
 		ph = ph[1:]
 	}
 
+	u := *mtrApiUrl
+	u.Path = "/namespace"
+	if p.Namespaces, err = getTagNamespaces(u.String()); err != nil {
+		return weft.InternalServerError(err)
+	}
+
+	namespaces := make(map[string]bool)
+
+	for _, n := range p.Namespaces {
+		namespaces[n.Namespace] = true
+		if ph == n.Namespace {
+			p.Namespace = n
+		}
+	}
+
+	if p.Namespace != nil {
+		p.Path = p.Namespace.Namespace
+
+		for _, t := range p.Border.TagList {
+			if strings.HasPrefix(t, p.Path+":") {
+				p.Tags = append(p.Tags, tagLink{Tag: t, Value: strings.TrimPrefix(t, p.Path+":")})
+			}
+		}
+
+		if err = tagPageTemplate.ExecuteTemplate(b, "border", p); err != nil {
+			return weft.InternalServerError(err)
+		}
+		return &weft.StatusOK
+	}
+
 	currTab := -1
 
 	// Create grouping tabs
@@ -56,9 +95,14 @@ func tagPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Resul
 	p.Path = p.TagTabs[currTab]
 
 	for _, t := range p.Border.TagList {
+		// namespaced tags are grouped by namespace.
+		if i := strings.Index(t, ":"); i != -1 && namespaces[t[:i]] {
+			continue
+		}
+
 		c := t[:1]
 		if strings.Contains(tagGrouper[currTab], c) {
-			p.Tags = append(p.Tags, t)
+			p.Tags = append(p.Tags, tagLink{Tag: t, Value: t})
 		}
 	}
 
@@ -68,3 +112,18 @@ func tagPageHandler(r *http.Request, h http.Header, b *bytes.Buffer) *weft.Resul
 	}
 	return &weft.StatusOK
 }
+
+func getTagNamespaces(urlString string) ([]*mtrpb.TagNamespace, error) {
+	b, err := getBytes(urlString, "application/x-protobuf")
+	if err != nil {
+		return nil, err
+	}
+
+	var nr mtrpb.TagNamespaceResult
+
+	if err = proto.Unmarshal(b, &nr); err != nil {
+		return nil, err
+	}
+
+	return nr.Result, nil
+}
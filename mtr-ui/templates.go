@@ -13,6 +13,9 @@ var (
 	metricDetailTemplate *template.Template
 	mapTemplate          *template.Template
 	tagPageTemplate      *template.Template
+	fieldDeviceTemplate  *template.Template
+	incidentTemplate     *template.Template
+	dashboardTemplate    *template.Template
 )
 
 func init() {
@@ -28,5 +31,8 @@ func loadTemplates() {
 	metricDetailTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/metric_detail.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
 	mapTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/map.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
 	tagPageTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/tag_page.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
+	fieldDeviceTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/device.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
+	incidentTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/incident.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
+	dashboardTemplate = template.Must(template.New("t").ParseFiles("assets/tmpl/dashboard.html", "assets/tmpl/tag_list.html", "assets/tmpl/border.html"))
 	log.Println("Done loading templates.")
 }
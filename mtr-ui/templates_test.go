@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"github.com/GeoNet/mtr/mtrpb"
 	"testing"
 )
 
@@ -24,4 +25,61 @@ func TestTemplates(t *testing.T) {
 		t.Error(err)
 	}
 
+	tp := tagPage{Path: "site", Namespace: &mtrpb.TagNamespace{Namespace: "site", Description: "Site code"},
+		Namespaces: []*mtrpb.TagNamespace{{Namespace: "site", Description: "Site code"}},
+		Tags:       []tagLink{{Tag: "site:TAUP", Value: "TAUP"}}}
+	if err := tagPageTemplate.ExecuteTemplate(&b, "border", tp); err != nil {
+		t.Error(err)
+	}
+
+	mp := mapPage{TypeID: "latency.strong", Summary: "data/latency/summary", Preset: "NewZealand",
+		Presets: []*mtrpb.MapBbox{{Name: "NewZealand", Title: "New Zealand", Bbox: "165,-48,179,-34"}}}
+	if r := mp.setBbox(""); !r.Ok {
+		t.Error(r.Msg)
+	}
+	if err := mapTemplate.ExecuteTemplate(&b, "border", mp); err != nil {
+		t.Error(err)
+	}
+
+	dp := fieldDevicePage{
+		Device: &mtrpb.FieldDevice{DeviceID: "gps-taupoairport", ModelID: "Trimble NetR9", Status: "good", State: "active",
+			Attributes: []*mtrpb.FieldAttribute{{Key: "serial", Value: "5036K70213"}}},
+		Model: &mtrpb.FieldModel{ModelID: "Trimble NetR9",
+			Attributes: []*mtrpb.FieldAttribute{{Key: "manufacturer", Value: "Trimble"}}},
+		Metrics:   []deviceMetric{{TypeID: "voltage", Value: 12000, Time: "2016-01-01T00:00:00Z"}},
+		Missing:   []string{"clock"},
+		States:    []deviceState{{State: "maintenance", Time: "2016-01-01T00:00:00Z", ChangedBy: "test"}},
+		Renames:   []deviceRename{{OldID: "gps-taupo", NewID: "gps-taupoairport", Time: "2016-01-01T00:00:00Z", ChangedBy: "test"}},
+		Locations: []deviceLocation{{Latitude: -38.7427, Longitude: 176.081, Time: "2016-01-01T00:00:00Z", ChangedBy: "test"}},
+	}
+	if err := fieldDeviceTemplate.ExecuteTemplate(&b, "border", dp); err != nil {
+		t.Error(err)
+	}
+
+	ip := incidentPage{Incidents: []incidentRow{
+		{DeviceID: "gps-taupoairport", TypeID: "voltage", Start: "2016-01-01T00:00:00Z", End: "2016-01-01T01:00:00Z",
+			Duration: "1h0m0s", Worst: 11000, Status: "bad", Group: 1, Tags: "TAUP"},
+		{SiteID: "TAUP", TypeID: "latency.gnss.1hz", Start: "2016-01-01T00:30:00Z", Duration: "2h0m0s",
+			Worst: 1200, Status: "late", Group: 1, Tags: "TAUP"},
+	}}
+	if err := incidentTemplate.ExecuteTemplate(&b, "border", ip); err != nil {
+		t.Error(err)
+	}
+
+	db := dashboardPage{Dashboards: []*mtrpb.Dashboard{{Name: "tsunami", Owner: "ops", Description: "Gauges"}}}
+	if err := dashboardTemplate.ExecuteTemplate(&b, "border", db); err != nil {
+		t.Error(err)
+	}
+
+	db.Dashboard = &mtrpb.Dashboard{Name: "tsunami", Owner: "ops", Panels: []*mtrpb.DashboardPanel{
+		{Position: 1, Kind: "field", SourceID: "gps-taupoairport", TypeID: "voltage", Window: "12h", Resolution: "minute",
+			Plot: "/field/metric?deviceID=gps-taupoairport&resolution=minute&typeID=voltage"},
+		{Position: 2, Kind: "data", SourceID: "TAUP", TypeID: "latency.strong", Window: "12h", Resolution: "five_minutes",
+			Plot: "/data/latency?plot=spark&siteID=TAUP&typeID=latency.strong"},
+		{Position: 3, Kind: "app", SourceID: "mtr-api", TypeID: "timers", Window: "28d", Resolution: "hour",
+			Plot: "/app/metric?applicationID=mtr-api&group=timers&resolution=hour"},
+	}}
+	if err := dashboardTemplate.ExecuteTemplate(&b, "border", db); err != nil {
+		t.Error(err)
+	}
 }
@@ -0,0 +1,174 @@
+// Code generated by protoc-gen-go.
+// source: dashboard.proto
+// DO NOT EDIT!
+
+/*
+Package mtrpb is a generated protocol buffer package.
+
+It is generated from these files:
+	dashboard.proto
+	data.proto
+	field.proto
+	incident.proto
+	lifecycle.proto
+	map.proto
+	site.proto
+	tag.proto
+
+It has these top-level messages:
+	DashboardPanel
+	Dashboard
+	DashboardResult
+	DataLatencySummary
+	DataLatencySummaryResult
+	DataSite
+	DataSiteResult
+	DataLatencyTag
+	DataLatencyTagResult
+	DataLatencyThreshold
+	DataLatencyThresholdResult
+	DataLatencyNetwork
+	DataLatencyNetworkResult
+	FieldMetricSummary
+	FieldMetricSummaryResult
+	FieldMetricTag
+	FieldMetricTagResult
+	FieldMetricThreshold
+	FieldMetricThresholdResult
+	FieldDevice
+	FieldDeviceResult
+	FieldAttribute
+	FieldAttributeResult
+	FieldModel
+	FieldModelResult
+	FieldDependency
+	FieldDependencyResult
+	FieldMetricInterval
+	FieldMetricIntervalResult
+	FieldGap
+	FieldMetricCompleteness
+	FieldMetricCompletenessResult
+	FieldMetricForecast
+	FieldMetricForecastResult
+	Incident
+	IncidentResult
+	LifecycleState
+	LifecycleStateResult
+	Rename
+	RenameResult
+	Location
+	LocationResult
+	MapBbox
+	MapBboxResult
+	SiteDevice
+	SiteDeviceResult
+	Site
+	Tag
+	TagResult
+	TagNamespace
+	TagNamespaceResult
+	TagSearchResult
+*/
+package mtrpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+const _ = proto.ProtoPackageIsVersion1
+
+// DashboardPanel is a plot on a dashboard.
+type DashboardPanel struct {
+	// The order of the panel on the dashboard.
+	Position int32 `protobuf:"varint,1,opt,name=position" json:"position,omitempty"`
+	// The kind of plot; one of field, data, or app.
+	Kind string `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	// The deviceID, siteID, or applicationID for the plot e.g., gps-taupoairport
+	SourceID string `protobuf:"bytes,3,opt,name=source_iD,json=sourceID" json:"source_iD,omitempty"`
+	// The typeID for field and data plots or the group for app plots e.g., voltage
+	TypeID string `protobuf:"bytes,4,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// The time window for the plot; one of 12h, 48h, or 28d.
+	Window string `protobuf:"bytes,5,opt,name=window" json:"window,omitempty"`
+	// The resolution for the plot; one of minute, five_minutes, or hour.
+	Resolution string `protobuf:"bytes,6,opt,name=resolution" json:"resolution,omitempty"`
+	// The path and query for the SVG plot on the mtr-api e.g., /field/metric?deviceID=gps-taupoairport&resolution=minute&typeID=voltage
+	Plot string `protobuf:"bytes,7,opt,name=plot" json:"plot,omitempty"`
+}
+
+func (m *DashboardPanel) Reset()                    { *m = DashboardPanel{} }
+func (m *DashboardPanel) String() string            { return proto.CompactTextString(m) }
+func (*DashboardPanel) ProtoMessage()               {}
+func (*DashboardPanel) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// Dashboard is a named board of plots.
+type Dashboard struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The team or person that owns the dashboard.
+	Owner       string `protobuf:"bytes,2,opt,name=owner" json:"owner,omitempty"`
+	Description string `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+	// The panels in position order.  Not set when listing dashboards.
+	Panels []*DashboardPanel `protobuf:"bytes,4,rep,name=panels" json:"panels,omitempty"`
+}
+
+func (m *Dashboard) Reset()                    { *m = Dashboard{} }
+func (m *Dashboard) String() string            { return proto.CompactTextString(m) }
+func (*Dashboard) ProtoMessage()               {}
+func (*Dashboard) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Dashboard) GetPanels() []*DashboardPanel {
+	if m != nil {
+		return m.Panels
+	}
+	return nil
+}
+
+type DashboardResult struct {
+	Result []*Dashboard `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *DashboardResult) Reset()                    { *m = DashboardResult{} }
+func (m *DashboardResult) String() string            { return proto.CompactTextString(m) }
+func (*DashboardResult) ProtoMessage()               {}
+func (*DashboardResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *DashboardResult) GetResult() []*Dashboard {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*DashboardPanel)(nil), "mtrpb.DashboardPanel")
+	proto.RegisterType((*Dashboard)(nil), "mtrpb.Dashboard")
+	proto.RegisterType((*DashboardResult)(nil), "mtrpb.DashboardResult")
+}
+
+var fileDescriptor0 = []byte{
+	// 280 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x91, 0xbf, 0x4e, 0xf3, 0x30,
+	0x14, 0xc5, 0xe5, 0xaf, 0x8d, 0xdb, 0xdc, 0x4a, 0x5f, 0xd1, 0x15, 0x7f, 0x2c, 0x90, 0x50, 0xd4,
+	0x29, 0x0b, 0x1d, 0x60, 0x64, 0x43, 0x59, 0xba, 0x21, 0x8f, 0x2c, 0x28, 0x6d, 0x2c, 0x61, 0x91,
+	0xda, 0x96, 0xed, 0x28, 0xe2, 0x05, 0x78, 0x30, 0x9e, 0x0c, 0xe5, 0x3a, 0x44, 0x85, 0xed, 0x9c,
+	0xf3, 0xb3, 0xec, 0xe3, 0x7b, 0x61, 0xdd, 0xd4, 0xe1, 0x6d, 0x6f, 0x6b, 0xdf, 0x6c, 0x9d, 0xb7,
+	0xd1, 0x62, 0x76, 0x8c, 0xde, 0xed, 0x37, 0x5f, 0x0c, 0xfe, 0x57, 0x3f, 0xe8, 0xb9, 0x36, 0xaa,
+	0xc5, 0x6b, 0x58, 0x3a, 0x1b, 0x74, 0xd4, 0xd6, 0x08, 0x56, 0xb0, 0x32, 0x93, 0x93, 0x47, 0x84,
+	0xf9, 0xbb, 0x36, 0x8d, 0xf8, 0x57, 0xb0, 0x32, 0x97, 0xa4, 0xf1, 0x06, 0xf2, 0x60, 0x3b, 0x7f,
+	0x50, 0xaf, 0xba, 0x12, 0x33, 0x02, 0xcb, 0x14, 0xec, 0x2a, 0xbc, 0x82, 0x45, 0xfc, 0x70, 0x84,
+	0xe6, 0x84, 0xf8, 0x60, 0x77, 0x15, 0x5e, 0x02, 0xef, 0xb5, 0x69, 0x6c, 0x2f, 0xb2, 0x94, 0x27,
+	0x87, 0xb7, 0x00, 0x5e, 0x05, 0xdb, 0x76, 0xf4, 0x3e, 0x27, 0x76, 0x92, 0x0c, 0x0d, 0x5c, 0x6b,
+	0xa3, 0x58, 0xa4, 0x06, 0x83, 0xde, 0x7c, 0x32, 0xc8, 0xa7, 0x4f, 0x0c, 0x27, 0x4c, 0x7d, 0x54,
+	0xd4, 0x3d, 0x97, 0xa4, 0xf1, 0x1c, 0x32, 0xdb, 0x1b, 0xe5, 0xc7, 0xe2, 0xc9, 0x60, 0x01, 0xab,
+	0x46, 0x85, 0x83, 0xd7, 0x8e, 0x1e, 0x4b, 0xdd, 0x4f, 0x23, 0xbc, 0x03, 0xee, 0x86, 0xa1, 0x04,
+	0x31, 0x2f, 0x66, 0xe5, 0xea, 0xfe, 0x62, 0x4b, 0x63, 0xdb, 0xfe, 0x1e, 0x99, 0x1c, 0x0f, 0x6d,
+	0x1e, 0x61, 0x3d, 0x11, 0xa9, 0x42, 0xd7, 0x46, 0x2c, 0x81, 0x7b, 0x52, 0x82, 0xd1, 0x0d, 0x67,
+	0x7f, 0x6f, 0x90, 0x23, 0x7f, 0x5a, 0xbc, 0xa4, 0x9d, 0xec, 0x39, 0x6d, 0xe8, 0xe1, 0x7b, 0x00,
+	0x57, 0x02, 0x54, 0x9b, 0xb4, 0x01, 0x00, 0x00,
+}
@@ -2,33 +2,6 @@
 // source: data.proto
 // DO NOT EDIT!
 
-/*
-Package mtrpb is a generated protocol buffer package.
-
-It is generated from these files:
-	data.proto
-	field.proto
-	tag.proto
-
-It has these top-level messages:
-	DataLatencySummary
-	DataLatencySummaryResult
-	DataSite
-	DataSiteResult
-	DataLatencyTag
-	DataLatencyTagResult
-	DataLatencyThreshold
-	DataLatencyThresholdResult
-	FieldMetricSummary
-	FieldMetricSummaryResult
-	FieldMetricTag
-	FieldMetricTagResult
-	FieldMetricThreshold
-	FieldMetricThresholdResult
-	Tag
-	TagResult
-	TagSearchResult
-*/
 package mtrpb
 
 import proto "github.com/golang/protobuf/proto"
@@ -40,10 +13,6 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the proto package it is being compiled against.
-const _ = proto.ProtoPackageIsVersion1
-
 // DataLatencySummary is a summary of data latency metrics for each site.
 // mean should not be 0.  fifty and ninety may be unknown (0).
 // If upper == lower == 0 then no threshold has been set on the metric.
@@ -64,12 +33,21 @@ type DataLatencySummary struct {
 	Upper int32 `protobuf:"varint,7,opt,name=upper" json:"upper,omitempty"`
 	// The lower threshold for the metric to be good.
 	Lower int32 `protobuf:"varint,8,opt,name=lower" json:"lower,omitempty"`
+	// The status for the latency; one of good, unknown, impacted, late, or bad.
+	// A bad or late latency is impacted when a device linked to the site is bad or late.
+	Status string `protobuf:"bytes,9,opt,name=status" json:"status,omitempty"`
+	// For impacted latencies, the upstream deviceIDs that are the cause.
+	RootCause []string `protobuf:"bytes,10,rep,name=root_cause,json=rootCause" json:"root_cause,omitempty"`
+	// True if the robust z-score for the mean against the baseline for the hour of day is over the limit.
+	Anomalous bool `protobuf:"varint,11,opt,name=anomalous" json:"anomalous,omitempty"`
+	// The robust z-score for the mean.  0 if there is no baseline.
+	Score float64 `protobuf:"fixed64,12,opt,name=score" json:"score,omitempty"`
 }
 
 func (m *DataLatencySummary) Reset()                    { *m = DataLatencySummary{} }
 func (m *DataLatencySummary) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencySummary) ProtoMessage()               {}
-func (*DataLatencySummary) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*DataLatencySummary) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
 
 type DataLatencySummaryResult struct {
 	Result []*DataLatencySummary `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -78,7 +56,7 @@ type DataLatencySummaryResult struct {
 func (m *DataLatencySummaryResult) Reset()                    { *m = DataLatencySummaryResult{} }
 func (m *DataLatencySummaryResult) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencySummaryResult) ProtoMessage()               {}
-func (*DataLatencySummaryResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*DataLatencySummaryResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
 
 func (m *DataLatencySummaryResult) GetResult() []*DataLatencySummary {
 	if m != nil {
@@ -94,12 +72,27 @@ type DataSite struct {
 	Latitude float64 `protobuf:"fixed64,2,opt,name=latitude" json:"latitude,omitempty"`
 	// The site longitude - not usually accurate enough for meta data
 	Longitude float64 `protobuf:"fixed64,3,opt,name=longitude" json:"longitude,omitempty"`
+	// Distance (km) from the query point.  0 if the query did not use a point.
+	Distance float64 `protobuf:"fixed64,4,opt,name=distance" json:"distance,omitempty"`
+	// The worst status of the latency metrics for the site; one of good, unknown, late, or bad.
+	Status string `protobuf:"bytes,5,opt,name=status" json:"status,omitempty"`
+	// The latest latency summary for each type at the site.
+	Latency []*DataLatencySummary `protobuf:"bytes,6,rep,name=latency" json:"latency,omitempty"`
+	// The lifecycle state for the site; one of planned, active, maintenance, or decommissioned.
+	State string `protobuf:"bytes,7,opt,name=state" json:"state,omitempty"`
 }
 
 func (m *DataSite) Reset()                    { *m = DataSite{} }
 func (m *DataSite) String() string            { return proto.CompactTextString(m) }
 func (*DataSite) ProtoMessage()               {}
-func (*DataSite) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*DataSite) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+
+func (m *DataSite) GetLatency() []*DataLatencySummary {
+	if m != nil {
+		return m.Latency
+	}
+	return nil
+}
 
 type DataSiteResult struct {
 	Result []*DataSite `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -108,7 +101,7 @@ type DataSiteResult struct {
 func (m *DataSiteResult) Reset()                    { *m = DataSiteResult{} }
 func (m *DataSiteResult) String() string            { return proto.CompactTextString(m) }
 func (*DataSiteResult) ProtoMessage()               {}
-func (*DataSiteResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*DataSiteResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
 
 func (m *DataSiteResult) GetResult() []*DataSite {
 	if m != nil {
@@ -129,7 +122,7 @@ type DataLatencyTag struct {
 func (m *DataLatencyTag) Reset()                    { *m = DataLatencyTag{} }
 func (m *DataLatencyTag) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencyTag) ProtoMessage()               {}
-func (*DataLatencyTag) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*DataLatencyTag) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
 
 type DataLatencyTagResult struct {
 	Result []*DataLatencyTag `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -138,7 +131,7 @@ type DataLatencyTagResult struct {
 func (m *DataLatencyTagResult) Reset()                    { *m = DataLatencyTagResult{} }
 func (m *DataLatencyTagResult) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencyTagResult) ProtoMessage()               {}
-func (*DataLatencyTagResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*DataLatencyTagResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{5} }
 
 func (m *DataLatencyTagResult) GetResult() []*DataLatencyTag {
 	if m != nil {
@@ -161,7 +154,7 @@ type DataLatencyThreshold struct {
 func (m *DataLatencyThreshold) Reset()                    { *m = DataLatencyThreshold{} }
 func (m *DataLatencyThreshold) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencyThreshold) ProtoMessage()               {}
-func (*DataLatencyThreshold) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*DataLatencyThreshold) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{6} }
 
 type DataLatencyThresholdResult struct {
 	Result []*DataLatencyThreshold `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -170,7 +163,7 @@ type DataLatencyThresholdResult struct {
 func (m *DataLatencyThresholdResult) Reset()                    { *m = DataLatencyThresholdResult{} }
 func (m *DataLatencyThresholdResult) String() string            { return proto.CompactTextString(m) }
 func (*DataLatencyThresholdResult) ProtoMessage()               {}
-func (*DataLatencyThresholdResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*DataLatencyThresholdResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{7} }
 
 func (m *DataLatencyThresholdResult) GetResult() []*DataLatencyThreshold {
 	if m != nil {
@@ -179,6 +172,41 @@ func (m *DataLatencyThresholdResult) GetResult() []*DataLatencyThreshold {
 	return nil
 }
 
+// DataLatencyNetwork is the distribution of a type of latency across sites for a time bucket.
+type DataLatencyNetwork struct {
+	// The typeID for the latency e.g., latency.strong
+	TypeID string `protobuf:"bytes,1,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// Unix time in seconds for the start of the bucket.
+	Seconds int64 `protobuf:"varint,2,opt,name=seconds" json:"seconds,omitempty"`
+	// The median of the site mean latencies.
+	Mean float64 `protobuf:"fixed64,3,opt,name=mean" json:"mean,omitempty"`
+	// The ninetieth percentile of the site ninety latencies.
+	Ninety float64 `protobuf:"fixed64,4,opt,name=ninety" json:"ninety,omitempty"`
+	// The number of sites reporting in the bucket.
+	Sites int32 `protobuf:"varint,5,opt,name=sites" json:"sites,omitempty"`
+}
+
+func (m *DataLatencyNetwork) Reset()                    { *m = DataLatencyNetwork{} }
+func (m *DataLatencyNetwork) String() string            { return proto.CompactTextString(m) }
+func (*DataLatencyNetwork) ProtoMessage()               {}
+func (*DataLatencyNetwork) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{8} }
+
+type DataLatencyNetworkResult struct {
+	Result []*DataLatencyNetwork `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *DataLatencyNetworkResult) Reset()                    { *m = DataLatencyNetworkResult{} }
+func (m *DataLatencyNetworkResult) String() string            { return proto.CompactTextString(m) }
+func (*DataLatencyNetworkResult) ProtoMessage()               {}
+func (*DataLatencyNetworkResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{9} }
+
+func (m *DataLatencyNetworkResult) GetResult() []*DataLatencyNetwork {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*DataLatencySummary)(nil), "mtrpb.DataLatencySummary")
 	proto.RegisterType((*DataLatencySummaryResult)(nil), "mtrpb.DataLatencySummaryResult")
@@ -188,31 +216,43 @@ func init() {
 	proto.RegisterType((*DataLatencyTagResult)(nil), "mtrpb.DataLatencyTagResult")
 	proto.RegisterType((*DataLatencyThreshold)(nil), "mtrpb.DataLatencyThreshold")
 	proto.RegisterType((*DataLatencyThresholdResult)(nil), "mtrpb.DataLatencyThresholdResult")
+	proto.RegisterType((*DataLatencyNetwork)(nil), "mtrpb.DataLatencyNetwork")
+	proto.RegisterType((*DataLatencyNetworkResult)(nil), "mtrpb.DataLatencyNetworkResult")
 }
 
-var fileDescriptor0 = []byte{
-	// 366 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x53, 0x3d, 0x4f, 0xc3, 0x30,
-	0x10, 0x55, 0x48, 0x93, 0xb6, 0x87, 0x04, 0xc8, 0x2a, 0x60, 0x0a, 0x03, 0xca, 0x42, 0x17, 0x2a,
-	0x41, 0x27, 0x56, 0x54, 0x06, 0x24, 0x18, 0x48, 0x3b, 0x21, 0x21, 0xe4, 0x36, 0x6e, 0x1b, 0x29,
-	0x5f, 0x4a, 0x1d, 0xa1, 0xfc, 0x49, 0x7e, 0x13, 0xf6, 0xd9, 0xe9, 0x67, 0x58, 0xba, 0xf9, 0xbd,
-	0x77, 0xe7, 0xbc, 0x77, 0x17, 0x03, 0x04, 0x4c, 0xb0, 0x7e, 0x96, 0xa7, 0x22, 0x25, 0x4e, 0x2c,
-	0xf2, 0x6c, 0xe2, 0xfd, 0x5a, 0x40, 0x86, 0x92, 0x7d, 0x63, 0x82, 0x27, 0xd3, 0x72, 0x54, 0xc4,
-	0x31, 0xcb, 0x4b, 0x72, 0x09, 0xcd, 0x65, 0x28, 0xf8, 0x77, 0x38, 0xa4, 0xd6, 0xad, 0xd5, 0x6b,
-	0xfb, 0xae, 0x82, 0xaf, 0x43, 0x25, 0x88, 0x32, 0x43, 0xe1, 0x48, 0x0b, 0x0a, 0x4a, 0x81, 0xca,
-	0x0e, 0x3e, 0x4d, 0x93, 0x60, 0x49, 0x6d, 0x29, 0xd8, 0x7e, 0x05, 0x09, 0x81, 0x46, 0xcc, 0x59,
-	0x42, 0x1b, 0x92, 0x76, 0x7c, 0x3c, 0x93, 0x0e, 0x38, 0xb3, 0x70, 0x26, 0x4a, 0xea, 0x20, 0xa9,
-	0x01, 0xb9, 0x00, 0x37, 0x09, 0x13, 0x2e, 0x69, 0x17, 0x69, 0x83, 0x54, 0x75, 0x91, 0x65, 0x3c,
-	0xa7, 0x4d, 0x5d, 0x8d, 0x40, 0xb1, 0x51, 0xfa, 0x23, 0xd9, 0x96, 0x66, 0x11, 0x78, 0xef, 0x40,
-	0xf7, 0xf3, 0xf8, 0x7c, 0x59, 0x44, 0x82, 0x3c, 0x80, 0x9b, 0xe3, 0x49, 0x86, 0xb2, 0x7b, 0xc7,
-	0x8f, 0x57, 0x7d, 0x1c, 0x42, 0xbf, 0xa6, 0xc1, 0x14, 0x7a, 0x5f, 0xd0, 0x52, 0xea, 0x48, 0xa6,
-	0xff, 0x7f, 0x28, 0x5d, 0x68, 0x45, 0x4c, 0x84, 0xa2, 0x08, 0x38, 0x4e, 0xc5, 0xf2, 0x57, 0x98,
-	0xdc, 0x40, 0x3b, 0x4a, 0x93, 0xb9, 0x16, 0x6d, 0x14, 0xd7, 0x84, 0xf7, 0x04, 0x27, 0xd5, 0xf5,
-	0xc6, 0xe3, 0xdd, 0x8e, 0xc7, 0xd3, 0x0d, 0x8f, 0x58, 0x56, 0x39, 0x1b, 0xeb, 0x56, 0xe3, 0x7b,
-	0xcc, 0xe6, 0x07, 0x2c, 0xed, 0x0c, 0x6c, 0xc1, 0xe6, 0x68, 0xab, 0xed, 0xab, 0xa3, 0xf7, 0x02,
-	0x9d, 0xed, 0x5b, 0x8d, 0xad, 0xfb, 0x1d, 0x5b, 0xe7, 0xfb, 0xa3, 0x53, 0xc5, 0x95, 0x39, 0xb1,
-	0x7d, 0xcd, 0x42, 0xd2, 0x8b, 0x34, 0x0a, 0x0e, 0xb0, 0xb8, 0xda, 0xb2, 0xbd, 0xb1, 0xe5, 0xf5,
-	0x1f, 0xd1, 0xd8, 0xf8, 0x23, 0xbc, 0x0f, 0xe8, 0xd6, 0x7d, 0xd5, 0x44, 0x18, 0xec, 0x44, 0xb8,
-	0xae, 0x89, 0xb0, 0x6a, 0x31, 0xa5, 0xcf, 0xcd, 0x4f, 0xfd, 0x50, 0x26, 0x2e, 0x3e, 0x9b, 0xc1,
-	0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe0, 0x1f, 0xd4, 0xda, 0x44, 0x03, 0x00, 0x00,
+var fileDescriptor1 = []byte{
+	// 521 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0xc1, 0x6e, 0xd4, 0x30,
+	0x10, 0x95, 0x9b, 0xdd, 0xec, 0x66, 0x8a, 0x0a, 0xb2, 0x0a, 0x98, 0x02, 0x52, 0x94, 0x0b, 0xb9,
+	0xb0, 0x12, 0xec, 0x89, 0x2b, 0x2c, 0x87, 0x4a, 0x80, 0x84, 0xdb, 0x13, 0x97, 0xca, 0xdd, 0xb8,
+	0xdb, 0x88, 0x24, 0x8e, 0xec, 0x89, 0xaa, 0x7c, 0x02, 0x9f, 0xc4, 0xbf, 0xf0, 0x31, 0xc8, 0x76,
+	0x92, 0x66, 0xb7, 0x5b, 0x81, 0x7a, 0xf3, 0x9b, 0x19, 0xdb, 0xef, 0x3d, 0xcf, 0x18, 0x20, 0x13,
+	0x28, 0x16, 0xb5, 0x56, 0xa8, 0xe8, 0xb4, 0x44, 0x5d, 0x5f, 0x26, 0xbf, 0x0f, 0x80, 0xae, 0x04,
+	0x8a, 0x2f, 0x02, 0x65, 0xb5, 0x6e, 0xcf, 0x9a, 0xb2, 0x14, 0xba, 0xa5, 0xcf, 0x61, 0x66, 0x72,
+	0x94, 0x17, 0xf9, 0x8a, 0x91, 0x98, 0xa4, 0x11, 0x0f, 0x2d, 0x3c, 0x5d, 0xd9, 0x04, 0xb6, 0xb5,
+	0x4b, 0x1c, 0xf8, 0x84, 0x85, 0xa7, 0x2b, 0xca, 0x60, 0x66, 0xe4, 0x5a, 0x55, 0x99, 0x61, 0x41,
+	0x4c, 0xd2, 0x80, 0xf7, 0x90, 0x52, 0x98, 0x94, 0x52, 0x54, 0x6c, 0x12, 0x93, 0x74, 0xca, 0xdd,
+	0x9a, 0x1e, 0xc3, 0xf4, 0x2a, 0xbf, 0xc2, 0x96, 0x4d, 0x5d, 0xd0, 0x03, 0xfa, 0x0c, 0xc2, 0x2a,
+	0xaf, 0x24, 0xb6, 0x2c, 0x74, 0xe1, 0x0e, 0xd9, 0xea, 0xa6, 0xae, 0xa5, 0x66, 0x33, 0x5f, 0xed,
+	0x80, 0x8d, 0x16, 0xea, 0x46, 0x6a, 0x36, 0xf7, 0x51, 0x07, 0xec, 0x19, 0x06, 0x05, 0x36, 0x86,
+	0x45, 0x1d, 0x71, 0x87, 0xe8, 0x6b, 0x00, 0xad, 0x14, 0x5e, 0xac, 0x45, 0x63, 0x24, 0x83, 0x38,
+	0x48, 0x23, 0x1e, 0xd9, 0xc8, 0x27, 0x1b, 0xa0, 0xaf, 0x20, 0x12, 0x95, 0x2a, 0x45, 0xa1, 0x1a,
+	0xc3, 0x0e, 0x63, 0x92, 0xce, 0xf9, 0x6d, 0xc0, 0x5e, 0x65, 0xd6, 0x4a, 0x4b, 0xf6, 0x28, 0x26,
+	0x29, 0xe1, 0x1e, 0x24, 0x5f, 0x81, 0xdd, 0xb5, 0x8e, 0x4b, 0xd3, 0x14, 0x48, 0xdf, 0x41, 0xa8,
+	0xdd, 0x8a, 0x91, 0x38, 0x48, 0x0f, 0xdf, 0xbf, 0x58, 0x38, 0xbf, 0x17, 0x7b, 0x36, 0x74, 0x85,
+	0xc9, 0x1f, 0x02, 0x73, 0x9b, 0x3e, 0xcb, 0x51, 0xde, 0xff, 0x00, 0x27, 0x30, 0x2f, 0x04, 0xe6,
+	0xd8, 0x64, 0xd2, 0xbd, 0x00, 0xe1, 0x03, 0xb6, 0x22, 0x0a, 0x55, 0x6d, 0x7c, 0x32, 0x70, 0xc9,
+	0xdb, 0x80, 0xdd, 0x99, 0xe5, 0x06, 0x45, 0xb5, 0x96, 0xee, 0x2d, 0x08, 0x1f, 0xf0, 0xc8, 0xb5,
+	0xe9, 0x96, 0x6b, 0x4b, 0x98, 0x15, 0x9e, 0x2d, 0x0b, 0xff, 0xa5, 0xa3, 0xaf, 0x74, 0x6e, 0xa1,
+	0x40, 0xe9, 0x9e, 0x2b, 0xe2, 0x1e, 0x24, 0x1f, 0xe0, 0xa8, 0x57, 0xd7, 0x79, 0xf4, 0x66, 0xc7,
+	0xa3, 0xc7, 0xa3, 0xb3, 0x5d, 0x59, 0xef, 0xcc, 0x39, 0x1c, 0x8d, 0xee, 0x3b, 0x17, 0x9b, 0x07,
+	0xf4, 0xe7, 0x13, 0x08, 0x50, 0x6c, 0x9c, 0x2b, 0x11, 0xb7, 0xcb, 0xe4, 0x33, 0x1c, 0x6f, 0x9f,
+	0xda, 0xd1, 0x7a, 0xbb, 0x43, 0xeb, 0xe9, 0x5d, 0xc9, 0xb6, 0xb8, 0x27, 0x87, 0xdb, 0xc7, 0x5c,
+	0x6b, 0x69, 0xae, 0x55, 0x91, 0x3d, 0x80, 0xe2, 0xd0, 0xd0, 0xc1, 0xb8, 0xa1, 0x87, 0xe6, 0x9f,
+	0x8c, 0x9a, 0x3f, 0xf9, 0x0e, 0x27, 0xfb, 0x6e, 0xed, 0x24, 0x2c, 0x77, 0x24, 0xbc, 0xdc, 0x23,
+	0x61, 0xd8, 0xd2, 0x0b, 0xf9, 0x45, 0xb6, 0xbe, 0x82, 0x6f, 0x12, 0x6f, 0x94, 0xfe, 0x39, 0xa6,
+	0x4b, 0xee, 0x9b, 0xf8, 0x83, 0xfd, 0x13, 0xef, 0x5b, 0xd0, 0xad, 0x47, 0xb3, 0xed, 0x7b, 0x6f,
+	0x34, 0xdb, 0xd6, 0x17, 0xd3, 0xff, 0x04, 0x0e, 0xec, 0x8c, 0x56, 0x47, 0xe5, 0xff, 0x47, 0xab,
+	0xdf, 0xd0, 0x15, 0x7e, 0x9c, 0xfd, 0xf0, 0xdf, 0xdd, 0x65, 0xe8, 0x3e, 0xbf, 0xe5, 0xdf, 0x01,
+	0x00, 0xa5, 0x4b, 0x07, 0x4e, 0x0a, 0x05, 0x00, 0x00,
 }
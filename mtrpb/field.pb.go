@@ -30,12 +30,21 @@ type FieldMetricSummary struct {
 	Lower int32 `protobuf:"varint,6,opt,name=lower" json:"lower,omitempty"`
 	// the modelID for the device e.g., "Trimble NetR9"
 	ModelID string `protobuf:"bytes,7,opt,name=model_iD,json=modelID" json:"model_iD,omitempty"`
+	// The status for the metric; one of good, unknown, impacted, late, or bad.
+	// A bad or late metric is impacted when a device it depends on is bad or late.
+	Status string `protobuf:"bytes,8,opt,name=status" json:"status,omitempty"`
+	// For impacted metrics, the upstream deviceIDs that are the cause.
+	RootCause []string `protobuf:"bytes,9,rep,name=root_cause,json=rootCause" json:"root_cause,omitempty"`
+	// True if the robust z-score for the value against the baseline for the hour of day is over the limit.
+	Anomalous bool `protobuf:"varint,10,opt,name=anomalous" json:"anomalous,omitempty"`
+	// The robust z-score for the value.  0 if there is no baseline.
+	Score float64 `protobuf:"fixed64,11,opt,name=score" json:"score,omitempty"`
 }
 
 func (m *FieldMetricSummary) Reset()                    { *m = FieldMetricSummary{} }
 func (m *FieldMetricSummary) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricSummary) ProtoMessage()               {}
-func (*FieldMetricSummary) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+func (*FieldMetricSummary) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
 
 type FieldMetricSummaryResult struct {
 	Result []*FieldMetricSummary `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -44,7 +53,7 @@ type FieldMetricSummaryResult struct {
 func (m *FieldMetricSummaryResult) Reset()                    { *m = FieldMetricSummaryResult{} }
 func (m *FieldMetricSummaryResult) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricSummaryResult) ProtoMessage()               {}
-func (*FieldMetricSummaryResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+func (*FieldMetricSummaryResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
 
 func (m *FieldMetricSummaryResult) GetResult() []*FieldMetricSummary {
 	if m != nil {
@@ -65,7 +74,7 @@ type FieldMetricTag struct {
 func (m *FieldMetricTag) Reset()                    { *m = FieldMetricTag{} }
 func (m *FieldMetricTag) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricTag) ProtoMessage()               {}
-func (*FieldMetricTag) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+func (*FieldMetricTag) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
 
 type FieldMetricTagResult struct {
 	Result []*FieldMetricTag `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -74,7 +83,7 @@ type FieldMetricTagResult struct {
 func (m *FieldMetricTagResult) Reset()                    { *m = FieldMetricTagResult{} }
 func (m *FieldMetricTagResult) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricTagResult) ProtoMessage()               {}
-func (*FieldMetricTagResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+func (*FieldMetricTagResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
 
 func (m *FieldMetricTagResult) GetResult() []*FieldMetricTag {
 	if m != nil {
@@ -97,7 +106,7 @@ type FieldMetricThreshold struct {
 func (m *FieldMetricThreshold) Reset()                    { *m = FieldMetricThreshold{} }
 func (m *FieldMetricThreshold) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricThreshold) ProtoMessage()               {}
-func (*FieldMetricThreshold) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+func (*FieldMetricThreshold) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{4} }
 
 type FieldMetricThresholdResult struct {
 	Result []*FieldMetricThreshold `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -106,7 +115,7 @@ type FieldMetricThresholdResult struct {
 func (m *FieldMetricThresholdResult) Reset()                    { *m = FieldMetricThresholdResult{} }
 func (m *FieldMetricThresholdResult) String() string            { return proto.CompactTextString(m) }
 func (*FieldMetricThresholdResult) ProtoMessage()               {}
-func (*FieldMetricThresholdResult) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{5} }
+func (*FieldMetricThresholdResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{5} }
 
 func (m *FieldMetricThresholdResult) GetResult() []*FieldMetricThreshold {
 	if m != nil {
@@ -115,6 +124,291 @@ func (m *FieldMetricThresholdResult) GetResult() []*FieldMetricThreshold {
 	return nil
 }
 
+// FieldDevice is a field device with the latest summary for each of its metrics.
+type FieldDevice struct {
+	// The deviceID e.g., idu-birchfarm
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// the modelID for the device e.g., "Trimble NetR9"
+	ModelID   string  `protobuf:"bytes,2,opt,name=model_iD,json=modelID" json:"model_iD,omitempty"`
+	Latitude  float64 `protobuf:"fixed64,3,opt,name=latitude" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,4,opt,name=longitude" json:"longitude,omitempty"`
+	// Distance (km) from the query point.  0 if the query did not use a point.
+	Distance float64 `protobuf:"fixed64,5,opt,name=distance" json:"distance,omitempty"`
+	// The worst status of the metrics for the device; one of good, unknown, late, or bad.
+	Status string `protobuf:"bytes,6,opt,name=status" json:"status,omitempty"`
+	// The latest summary for each metric for the device.
+	Metrics []*FieldMetricSummary `protobuf:"bytes,7,rep,name=metrics" json:"metrics,omitempty"`
+	// Attributes for the device e.g., serial, firmware.
+	Attributes []*FieldAttribute `protobuf:"bytes,8,rep,name=attributes" json:"attributes,omitempty"`
+	// The lifecycle state for the device; one of planned, active, maintenance, or decommissioned.
+	State string `protobuf:"bytes,9,opt,name=state" json:"state,omitempty"`
+}
+
+func (m *FieldDevice) Reset()                    { *m = FieldDevice{} }
+func (m *FieldDevice) String() string            { return proto.CompactTextString(m) }
+func (*FieldDevice) ProtoMessage()               {}
+func (*FieldDevice) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{6} }
+
+func (m *FieldDevice) GetMetrics() []*FieldMetricSummary {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+func (m *FieldDevice) GetAttributes() []*FieldAttribute {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+type FieldDeviceResult struct {
+	Result []*FieldDevice `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldDeviceResult) Reset()                    { *m = FieldDeviceResult{} }
+func (m *FieldDeviceResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldDeviceResult) ProtoMessage()               {}
+func (*FieldDeviceResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{7} }
+
+func (m *FieldDeviceResult) GetResult() []*FieldDevice {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldAttribute is a key value attribute for a field device or model.
+type FieldAttribute struct {
+	// The attribute key e.g., serial
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *FieldAttribute) Reset()                    { *m = FieldAttribute{} }
+func (m *FieldAttribute) String() string            { return proto.CompactTextString(m) }
+func (*FieldAttribute) ProtoMessage()               {}
+func (*FieldAttribute) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{8} }
+
+type FieldAttributeResult struct {
+	Result []*FieldAttribute `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldAttributeResult) Reset()                    { *m = FieldAttributeResult{} }
+func (m *FieldAttributeResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldAttributeResult) ProtoMessage()               {}
+func (*FieldAttributeResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{9} }
+
+func (m *FieldAttributeResult) GetResult() []*FieldAttribute {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldModel is a field device model.
+type FieldModel struct {
+	// The modelID e.g., "Trimble NetR9"
+	ModelID string `protobuf:"bytes,1,opt,name=model_iD,json=modelID" json:"model_iD,omitempty"`
+	// Attributes for the model e.g., manufacturer.
+	Attributes []*FieldAttribute `protobuf:"bytes,2,rep,name=attributes" json:"attributes,omitempty"`
+}
+
+func (m *FieldModel) Reset()                    { *m = FieldModel{} }
+func (m *FieldModel) String() string            { return proto.CompactTextString(m) }
+func (*FieldModel) ProtoMessage()               {}
+func (*FieldModel) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{10} }
+
+func (m *FieldModel) GetAttributes() []*FieldAttribute {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+type FieldModelResult struct {
+	Result []*FieldModel `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldModelResult) Reset()                    { *m = FieldModelResult{} }
+func (m *FieldModelResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldModelResult) ProtoMessage()               {}
+func (*FieldModelResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{11} }
+
+func (m *FieldModelResult) GetResult() []*FieldModel {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldDependency is a dependency between field devices.  The device depends on the parent
+// e.g., a datalogger depends on the radio repeater it communicates through.
+type FieldDependency struct {
+	// The deviceID e.g., gps-taupoairport
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// The deviceID of the parent e.g., rad-taupo
+	ParentID string `protobuf:"bytes,2,opt,name=parent_iD,json=parentID" json:"parent_iD,omitempty"`
+}
+
+func (m *FieldDependency) Reset()                    { *m = FieldDependency{} }
+func (m *FieldDependency) String() string            { return proto.CompactTextString(m) }
+func (*FieldDependency) ProtoMessage()               {}
+func (*FieldDependency) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{12} }
+
+type FieldDependencyResult struct {
+	Result []*FieldDependency `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldDependencyResult) Reset()                    { *m = FieldDependencyResult{} }
+func (m *FieldDependencyResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldDependencyResult) ProtoMessage()               {}
+func (*FieldDependencyResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{13} }
+
+func (m *FieldDependencyResult) GetResult() []*FieldDependency {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldMetricInterval is the expected reporting interval for a field metric.  An empty device_iD
+// is the default for all devices with the type_iD.
+type FieldMetricInterval struct {
+	// The deviceID for the metric e.g., idu-birchfarm
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// The typeID for the metric e.g., conn
+	TypeID string `protobuf:"bytes,2,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// The expected interval between values in seconds.
+	Seconds int32 `protobuf:"varint,3,opt,name=seconds" json:"seconds,omitempty"`
+}
+
+func (m *FieldMetricInterval) Reset()                    { *m = FieldMetricInterval{} }
+func (m *FieldMetricInterval) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricInterval) ProtoMessage()               {}
+func (*FieldMetricInterval) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{14} }
+
+type FieldMetricIntervalResult struct {
+	Result []*FieldMetricInterval `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldMetricIntervalResult) Reset()                    { *m = FieldMetricIntervalResult{} }
+func (m *FieldMetricIntervalResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricIntervalResult) ProtoMessage()               {}
+func (*FieldMetricIntervalResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{15} }
+
+func (m *FieldMetricIntervalResult) GetResult() []*FieldMetricInterval {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldGap is a period with no values for a field metric.
+type FieldGap struct {
+	// Unix time in seconds for the last value before the gap or the start of the window.
+	Start int64 `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	// Unix time in seconds for the first value after the gap or the end of the window.
+	End int64 `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+	// The number of values expected during the gap.
+	Missing int64 `protobuf:"varint,3,opt,name=missing" json:"missing,omitempty"`
+}
+
+func (m *FieldGap) Reset()                    { *m = FieldGap{} }
+func (m *FieldGap) String() string            { return proto.CompactTextString(m) }
+func (*FieldGap) ProtoMessage()               {}
+func (*FieldGap) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{16} }
+
+// FieldMetricCompleteness is the completeness of a field metric over a window.
+type FieldMetricCompleteness struct {
+	// The deviceID for the metric e.g., idu-birchfarm
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// The typeID for the metric e.g., conn
+	TypeID string `protobuf:"bytes,2,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// The expected interval between values in seconds.
+	Interval int32 `protobuf:"varint,3,opt,name=interval" json:"interval,omitempty"`
+	// The number of values expected in the window.
+	Expected int64 `protobuf:"varint,4,opt,name=expected" json:"expected,omitempty"`
+	// The number of values received in the window.
+	Received int64 `protobuf:"varint,5,opt,name=received" json:"received,omitempty"`
+	// received as a percentage of expected, at most 100.
+	Completeness float64 `protobuf:"fixed64,6,opt,name=completeness" json:"completeness,omitempty"`
+	// The gaps in the window.
+	Gaps []*FieldGap `protobuf:"bytes,7,rep,name=gaps" json:"gaps,omitempty"`
+}
+
+func (m *FieldMetricCompleteness) Reset()                    { *m = FieldMetricCompleteness{} }
+func (m *FieldMetricCompleteness) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricCompleteness) ProtoMessage()               {}
+func (*FieldMetricCompleteness) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{17} }
+
+func (m *FieldMetricCompleteness) GetGaps() []*FieldGap {
+	if m != nil {
+		return m.Gaps
+	}
+	return nil
+}
+
+type FieldMetricCompletenessResult struct {
+	Result []*FieldMetricCompleteness `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldMetricCompletenessResult) Reset()                    { *m = FieldMetricCompletenessResult{} }
+func (m *FieldMetricCompletenessResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricCompletenessResult) ProtoMessage()               {}
+func (*FieldMetricCompletenessResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{18} }
+
+func (m *FieldMetricCompletenessResult) GetResult() []*FieldMetricCompleteness {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// FieldMetricForecast is the trend for a field metric and when it is forecast to reach a threshold.
+type FieldMetricForecast struct {
+	// The deviceID for the metric e.g., idu-birchfarm
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// The typeID for the metric e.g., disk.hd1
+	TypeID string `protobuf:"bytes,2,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// The change in value per day from a robust (Theil-Sen) regression over the history window.
+	Slope float64 `protobuf:"fixed64,3,opt,name=slope" json:"slope,omitempty"`
+	// The value for the trend line now.
+	Value float64 `protobuf:"fixed64,4,opt,name=value" json:"value,omitempty"`
+	// The upper threshold for the metric to be good.
+	Upper int32 `protobuf:"varint,5,opt,name=upper" json:"upper,omitempty"`
+	// The lower threshold for the metric to be good.
+	Lower int32 `protobuf:"varint,6,opt,name=lower" json:"lower,omitempty"`
+	// True if the trend reaches the threshold.
+	Breach bool `protobuf:"varint,7,opt,name=breach" json:"breach,omitempty"`
+	// Unix time in seconds when the trend reaches the threshold.  Now if the trend is already past it.
+	Seconds int64 `protobuf:"varint,8,opt,name=seconds" json:"seconds,omitempty"`
+	// Days from now until the trend reaches the threshold.
+	Days float64 `protobuf:"fixed64,9,opt,name=days" json:"days,omitempty"`
+}
+
+func (m *FieldMetricForecast) Reset()                    { *m = FieldMetricForecast{} }
+func (m *FieldMetricForecast) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricForecast) ProtoMessage()               {}
+func (*FieldMetricForecast) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{19} }
+
+type FieldMetricForecastResult struct {
+	Result []*FieldMetricForecast `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *FieldMetricForecastResult) Reset()                    { *m = FieldMetricForecastResult{} }
+func (m *FieldMetricForecastResult) String() string            { return proto.CompactTextString(m) }
+func (*FieldMetricForecastResult) ProtoMessage()               {}
+func (*FieldMetricForecastResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{20} }
+
+func (m *FieldMetricForecastResult) GetResult() []*FieldMetricForecast {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*FieldMetricSummary)(nil), "mtrpb.FieldMetricSummary")
 	proto.RegisterType((*FieldMetricSummaryResult)(nil), "mtrpb.FieldMetricSummaryResult")
@@ -122,28 +416,76 @@ func init() {
 	proto.RegisterType((*FieldMetricTagResult)(nil), "mtrpb.FieldMetricTagResult")
 	proto.RegisterType((*FieldMetricThreshold)(nil), "mtrpb.FieldMetricThreshold")
 	proto.RegisterType((*FieldMetricThresholdResult)(nil), "mtrpb.FieldMetricThresholdResult")
+	proto.RegisterType((*FieldDevice)(nil), "mtrpb.FieldDevice")
+	proto.RegisterType((*FieldDeviceResult)(nil), "mtrpb.FieldDeviceResult")
+	proto.RegisterType((*FieldAttribute)(nil), "mtrpb.FieldAttribute")
+	proto.RegisterType((*FieldAttributeResult)(nil), "mtrpb.FieldAttributeResult")
+	proto.RegisterType((*FieldModel)(nil), "mtrpb.FieldModel")
+	proto.RegisterType((*FieldModelResult)(nil), "mtrpb.FieldModelResult")
+	proto.RegisterType((*FieldDependency)(nil), "mtrpb.FieldDependency")
+	proto.RegisterType((*FieldDependencyResult)(nil), "mtrpb.FieldDependencyResult")
+	proto.RegisterType((*FieldMetricInterval)(nil), "mtrpb.FieldMetricInterval")
+	proto.RegisterType((*FieldMetricIntervalResult)(nil), "mtrpb.FieldMetricIntervalResult")
+	proto.RegisterType((*FieldGap)(nil), "mtrpb.FieldGap")
+	proto.RegisterType((*FieldMetricCompleteness)(nil), "mtrpb.FieldMetricCompleteness")
+	proto.RegisterType((*FieldMetricCompletenessResult)(nil), "mtrpb.FieldMetricCompletenessResult")
+	proto.RegisterType((*FieldMetricForecast)(nil), "mtrpb.FieldMetricForecast")
+	proto.RegisterType((*FieldMetricForecastResult)(nil), "mtrpb.FieldMetricForecastResult")
 }
 
-var fileDescriptor1 = []byte{
-	// 314 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x52, 0xcd, 0x4e, 0xb3, 0x40,
-	0x14, 0x0d, 0x1f, 0x05, 0xca, 0x6d, 0xf2, 0xc5, 0x4c, 0x6a, 0x9c, 0xda, 0x8d, 0x61, 0xd5, 0x8d,
-	0x24, 0xda, 0x37, 0x30, 0x68, 0xd2, 0x45, 0x17, 0xa2, 0x0b, 0xe3, 0xc6, 0x50, 0x18, 0x5b, 0x92,
-	0xc1, 0x21, 0xc3, 0x50, 0xed, 0xe3, 0xf9, 0x66, 0xce, 0x0f, 0x25, 0xb4, 0x65, 0xd5, 0xdd, 0x9c,
-	0x73, 0xee, 0xcc, 0x3d, 0xe7, 0xce, 0x85, 0xd1, 0x67, 0x4e, 0x68, 0x16, 0x96, 0x9c, 0x09, 0x86,
-	0x9c, 0x42, 0xf0, 0x72, 0x15, 0xfc, 0x5a, 0x80, 0x9e, 0x14, 0xbd, 0x24, 0x82, 0xe7, 0xe9, 0x4b,
-	0x5d, 0x14, 0x09, 0xdf, 0xa1, 0x29, 0xf8, 0x19, 0xd9, 0xe6, 0x29, 0xf9, 0xc8, 0x23, 0x6c, 0xdd,
-	0x58, 0x33, 0x3f, 0x1e, 0x1a, 0x62, 0x11, 0xa1, 0x2b, 0xf0, 0xc4, 0xae, 0xd4, 0xd2, 0x3f, 0x2d,
-	0xb9, 0x0a, 0x4a, 0x01, 0x83, 0x57, 0x91, 0x94, 0x7d, 0x65, 0x15, 0xb6, 0xa5, 0x60, 0xc7, 0x7b,
-	0x88, 0xc6, 0xe0, 0x6c, 0x13, 0x5a, 0x13, 0x3c, 0x90, 0xbc, 0x13, 0x1b, 0xa0, 0xd8, 0xba, 0x2c,
-	0x09, 0xc7, 0x8e, 0x61, 0x35, 0x50, 0x2c, 0x65, 0xdf, 0x92, 0x75, 0x0d, 0xab, 0x01, 0x9a, 0xc0,
-	0xb0, 0x60, 0x19, 0xa1, 0xaa, 0xab, 0xa7, 0xbb, 0x7a, 0x1a, 0x2f, 0xa2, 0x60, 0x09, 0xf8, 0x34,
-	0x42, 0x4c, 0xaa, 0x9a, 0x0a, 0x74, 0x07, 0x2e, 0xd7, 0x27, 0x99, 0xc2, 0x9e, 0x8d, 0xee, 0x27,
-	0xa1, 0xce, 0x1d, 0xf6, 0x5c, 0x68, 0x0a, 0x83, 0x37, 0xf8, 0xdf, 0x51, 0x5f, 0x93, 0xf5, 0x99,
-	0xd3, 0xb8, 0x00, 0x5b, 0x24, 0x6b, 0x3d, 0x09, 0x3f, 0x56, 0xc7, 0xe0, 0x11, 0xc6, 0x87, 0x2f,
-	0x37, 0x26, 0x6f, 0x8f, 0x4c, 0x5e, 0x9e, 0x9a, 0x54, 0xc5, 0x7b, 0x83, 0x3f, 0x87, 0xcf, 0x6c,
-	0x24, 0xbd, 0x61, 0x34, 0x3b, 0xd3, 0x66, 0x3b, 0x6e, 0xbb, 0x3b, 0xee, 0xf6, 0x6b, 0x06, 0x9d,
-	0xaf, 0x09, 0x9e, 0xe1, 0xba, 0xaf, 0x73, 0x13, 0x63, 0x7e, 0x14, 0x63, 0xda, 0x13, 0xa3, 0xbd,
-	0xd2, 0x94, 0x3e, 0x78, 0xef, 0x66, 0x13, 0x57, 0xae, 0xde, 0xcb, 0xf9, 0x5f, 0x00, 0x00, 0x00,
-	0xff, 0xff, 0x60, 0x89, 0x39, 0x1b, 0xa6, 0x02, 0x00, 0x00,
+var fileDescriptor2 = []byte{
+	// 848 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xdb, 0x8e, 0xe3, 0x44,
+	0x10, 0x55, 0xc7, 0xb9, 0xd8, 0x35, 0x68, 0x2f, 0xcd, 0xee, 0xac, 0x67, 0x86, 0x45, 0x91, 0x79,
+	0x09, 0x48, 0x8c, 0xc4, 0x8e, 0x40, 0xbc, 0x20, 0x04, 0x1b, 0x76, 0x14, 0xc1, 0x0a, 0xd1, 0x20,
+	0x81, 0x78, 0x60, 0xd5, 0x63, 0x17, 0x19, 0x0b, 0xdf, 0xd4, 0xdd, 0x0e, 0x9b, 0x7f, 0xe2, 0x95,
+	0x8f, 0x42, 0xe2, 0x23, 0x50, 0x5f, 0xec, 0xd8, 0x4e, 0x60, 0x47, 0x11, 0x6f, 0x7d, 0xaa, 0xaa,
+	0xbb, 0x4f, 0x55, 0x1d, 0x97, 0x1b, 0x4e, 0x7e, 0x4d, 0x31, 0x4b, 0x2e, 0x2b, 0x51, 0xaa, 0x92,
+	0x4e, 0x72, 0x25, 0xaa, 0x9b, 0xe8, 0x8f, 0x11, 0xd0, 0x17, 0xda, 0xfc, 0x12, 0x95, 0x48, 0xe3,
+	0xef, 0xeb, 0x3c, 0xe7, 0x62, 0x4b, 0x2f, 0x20, 0x48, 0x70, 0x93, 0xc6, 0xf8, 0x2a, 0x5d, 0x86,
+	0x64, 0x4e, 0x16, 0x01, 0xf3, 0xad, 0x61, 0xb5, 0xa4, 0x4f, 0x60, 0xa6, 0xb6, 0x95, 0x71, 0x8d,
+	0x8c, 0x6b, 0xaa, 0xe1, 0x6a, 0x49, 0x43, 0x98, 0x49, 0x8c, 0xcb, 0x22, 0x91, 0xa1, 0x37, 0x27,
+	0x0b, 0x8f, 0x35, 0x90, 0x3e, 0x82, 0xc9, 0x86, 0x67, 0x35, 0x86, 0xe3, 0x39, 0x59, 0x4c, 0x98,
+	0x05, 0xda, 0x5a, 0x57, 0x15, 0x8a, 0x70, 0x62, 0xad, 0x06, 0x68, 0x6b, 0x56, 0xfe, 0x8e, 0x22,
+	0x9c, 0x5a, 0xab, 0x01, 0xf4, 0x0c, 0xfc, 0xbc, 0x4c, 0x30, 0xd3, 0xb7, 0xce, 0xcc, 0xad, 0x33,
+	0x83, 0x57, 0x4b, 0x7a, 0x0a, 0x53, 0xa9, 0xb8, 0xaa, 0x65, 0xe8, 0x5b, 0x3a, 0x16, 0xd1, 0xa7,
+	0x00, 0xa2, 0x2c, 0xd5, 0xab, 0x98, 0xd7, 0x12, 0xc3, 0x60, 0xee, 0x2d, 0x02, 0x16, 0x68, 0xcb,
+	0x73, 0x6d, 0xa0, 0xef, 0x40, 0xc0, 0x8b, 0x32, 0xe7, 0x59, 0x59, 0xcb, 0x10, 0xe6, 0x64, 0xe1,
+	0xb3, 0x9d, 0x41, 0xb3, 0x90, 0x71, 0x29, 0x30, 0x3c, 0x99, 0x93, 0x05, 0x61, 0x16, 0x44, 0x2f,
+	0x21, 0xdc, 0xaf, 0x16, 0x43, 0x59, 0x67, 0x8a, 0x7e, 0x04, 0x53, 0x61, 0x56, 0x21, 0x99, 0x7b,
+	0x8b, 0x93, 0x67, 0x67, 0x97, 0xa6, 0xc4, 0x97, 0x07, 0x36, 0xb8, 0xc0, 0xe8, 0x27, 0xb8, 0xd7,
+	0xf1, 0xfe, 0xc0, 0xd7, 0x47, 0x16, 0xfe, 0x01, 0x78, 0x8a, 0xaf, 0x4d, 0xd1, 0x03, 0xa6, 0x97,
+	0xd1, 0x57, 0xf0, 0xa8, 0x7f, 0xb2, 0x23, 0xf9, 0xe1, 0x80, 0xe4, 0xe3, 0x7d, 0x92, 0x3a, 0xb8,
+	0x21, 0xf8, 0xba, 0x7f, 0xcc, 0xad, 0x40, 0x79, 0x5b, 0x66, 0xc9, 0x91, 0x34, 0xdb, 0xce, 0x7a,
+	0xdd, 0xce, 0xb6, 0x2a, 0x18, 0x77, 0x54, 0x10, 0x7d, 0x07, 0xe7, 0x87, 0x6e, 0x76, 0x69, 0x5c,
+	0x0d, 0xd2, 0xb8, 0x38, 0x90, 0x46, 0xbb, 0xa5, 0x49, 0xe6, 0xcf, 0x11, 0x9c, 0x98, 0x80, 0xa5,
+	0x61, 0xfa, 0xdf, 0x49, 0x74, 0xf5, 0x36, 0xea, 0xeb, 0xed, 0x1c, 0xfc, 0x8c, 0xab, 0x54, 0xd5,
+	0x09, 0x9a, 0x4c, 0x08, 0x6b, 0xb1, 0x16, 0x55, 0x56, 0x16, 0x6b, 0xeb, 0x1c, 0x1b, 0xe7, 0xce,
+	0xa0, 0x77, 0x26, 0xa9, 0x54, 0xbc, 0x88, 0xd1, 0x68, 0x9e, 0xb0, 0x16, 0x77, 0x54, 0x3c, 0xed,
+	0xa9, 0xf8, 0x0a, 0x66, 0xb9, 0x49, 0x48, 0x86, 0xb3, 0x37, 0xe9, 0xaa, 0x89, 0xa4, 0x1f, 0x03,
+	0x70, 0xa5, 0x44, 0x7a, 0x53, 0x2b, 0xd4, 0x9f, 0xc5, 0x5e, 0xab, 0xbf, 0x68, 0xbc, 0xac, 0x13,
+	0x68, 0x44, 0xaf, 0xb8, 0xd2, 0x1f, 0x8b, 0xa6, 0x60, 0x41, 0xf4, 0x39, 0x3c, 0xec, 0x94, 0xcd,
+	0x75, 0xe0, 0x83, 0x41, 0x07, 0x68, 0xf7, 0x74, 0x17, 0xd9, 0x14, 0xfe, 0x53, 0xb8, 0xd7, 0xbf,
+	0x54, 0x0b, 0xf6, 0x37, 0xdc, 0xba, 0xa2, 0xeb, 0xe5, 0x6e, 0x42, 0xd8, 0x62, 0x5b, 0xd0, 0xca,
+	0x78, 0x47, 0xf7, 0x0e, 0x32, 0xde, 0x05, 0x37, 0x04, 0x7e, 0x01, 0xb0, 0xd5, 0xd2, 0x1d, 0xec,
+	0xb5, 0x96, 0xf4, 0x5b, 0xdb, 0xaf, 0xdb, 0xe8, 0x8e, 0x75, 0x8b, 0x3e, 0x83, 0x07, 0xbb, 0xf3,
+	0x1d, 0xc5, 0xf7, 0x07, 0x14, 0x1f, 0xf6, 0xda, 0x66, 0x02, 0x1b, 0x7a, 0x5f, 0xc3, 0x7d, 0x57,
+	0xb6, 0x0a, 0x8b, 0x04, 0x8b, 0xf8, 0x0d, 0x03, 0xf8, 0x02, 0x82, 0x8a, 0x0b, 0x2c, 0xd4, 0x4e,
+	0x9c, 0xbe, 0x35, 0xac, 0x96, 0xd1, 0x35, 0x3c, 0x1e, 0x1c, 0xe6, 0x08, 0x5d, 0x0e, 0x08, 0x9d,
+	0xf6, 0x3b, 0xd6, 0x46, 0x37, 0xac, 0x10, 0xde, 0xee, 0x48, 0x6c, 0x55, 0x28, 0x14, 0x1b, 0x9e,
+	0xfd, 0x3f, 0xbf, 0x86, 0x49, 0xfb, 0x6b, 0x88, 0xbe, 0x85, 0xb3, 0x03, 0xd7, 0x38, 0xce, 0xcf,
+	0x06, 0x9c, 0xcf, 0xf7, 0xb5, 0xdf, 0xee, 0x68, 0x78, 0x7f, 0x03, 0xbe, 0x71, 0x5f, 0xf3, 0xca,
+	0x09, 0x5a, 0x28, 0x43, 0xd4, 0x63, 0x16, 0x68, 0xf5, 0x61, 0x91, 0x18, 0x86, 0x1e, 0xd3, 0x4b,
+	0x4d, 0x2f, 0x4f, 0xa5, 0x4c, 0x8b, 0x75, 0xf3, 0xe7, 0x72, 0x30, 0xfa, 0x9b, 0xc0, 0x93, 0xce,
+	0x6d, 0xcf, 0xcb, 0xbc, 0xca, 0x50, 0x61, 0x81, 0x52, 0x1e, 0x59, 0x8a, 0x73, 0xf0, 0x53, 0xc7,
+	0xd9, 0xd5, 0xa2, 0xc5, 0xda, 0x87, 0xaf, 0x2b, 0x8c, 0x15, 0x26, 0x66, 0x7a, 0x78, 0xac, 0xc5,
+	0xda, 0x27, 0x30, 0xc6, 0x74, 0x83, 0x89, 0x19, 0x1e, 0x1e, 0x6b, 0x31, 0x8d, 0xe0, 0xad, 0xb8,
+	0xc3, 0xcc, 0x8c, 0x10, 0xc2, 0x7a, 0x36, 0xfa, 0x1e, 0x8c, 0xd7, 0xbc, 0x6a, 0xa6, 0xc8, 0xfd,
+	0x6e, 0x25, 0xaf, 0x79, 0xc5, 0x8c, 0x33, 0xfa, 0x11, 0x9e, 0xfe, 0x4b, 0xb6, 0xae, 0x23, 0x9f,
+	0x0c, 0x3a, 0xf2, 0xee, 0x7e, 0x47, 0x7a, 0xbb, 0x9a, 0xae, 0xfc, 0x45, 0x7a, 0x72, 0x7a, 0x51,
+	0x0a, 0x8c, 0xb9, 0x54, 0xc7, 0xff, 0x49, 0x64, 0x56, 0x56, 0xcd, 0xfc, 0xb5, 0xa0, 0xff, 0xca,
+	0x20, 0xc7, 0xbc, 0x32, 0x4e, 0x61, 0x7a, 0x23, 0x90, 0xc7, 0xb7, 0xe6, 0x8d, 0xe1, 0x33, 0x87,
+	0xba, 0xf2, 0xf5, 0xfb, 0x2f, 0x1b, 0x0a, 0xe3, 0x84, 0x6f, 0xa5, 0x99, 0x98, 0x84, 0x99, 0xf5,
+	0x40, 0xd2, 0x4d, 0xaa, 0x77, 0x97, 0x74, 0xbb, 0xc3, 0x45, 0x7e, 0x39, 0xfb, 0xd9, 0x3e, 0xd7,
+	0x6e, 0xa6, 0xe6, 0xf1, 0x76, 0xf5, 0xcf, 0x00, 0x17, 0x3f, 0xfc, 0xe9, 0xcb, 0x09, 0x00, 0x00,
 }
@@ -0,0 +1,85 @@
+// Code generated by protoc-gen-go.
+// source: incident.proto
+// DO NOT EDIT!
+
+package mtrpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// Incident is a contiguous period when a field metric or data latency is bad or late.
+type Incident struct {
+	// The deviceID for a field metric incident e.g., gps-taupoairport
+	DeviceID string `protobuf:"bytes,1,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// The siteID for a data latency incident e.g., TAUP
+	SiteID string `protobuf:"bytes,2,opt,name=site_iD,json=siteID" json:"site_iD,omitempty"`
+	// The typeID for the metric e.g., voltage
+	TypeID string `protobuf:"bytes,3,opt,name=type_iD,json=typeID" json:"type_iD,omitempty"`
+	// Unix time in seconds for the start of the incident.
+	Start int64 `protobuf:"varint,4,opt,name=start" json:"start,omitempty"`
+	// Unix time in seconds for the end of the incident.  0 if the incident is ongoing.
+	End int64 `protobuf:"varint,5,opt,name=end" json:"end,omitempty"`
+	// The duration of the incident in seconds.  Up to now if the incident is ongoing.
+	Duration int64 `protobuf:"varint,6,opt,name=duration" json:"duration,omitempty"`
+	// The value furthest outside the threshold or, if the incident is only late,
+	// the last value before the incident.
+	Worst int32 `protobuf:"varint,7,opt,name=worst" json:"worst,omitempty"`
+	// The worst status during the incident; bad or late.
+	Status string `protobuf:"bytes,8,opt,name=status" json:"status,omitempty"`
+	// Incidents with the same group overlap in time and share a device, site, or tag.
+	Group int32 `protobuf:"varint,9,opt,name=group" json:"group,omitempty"`
+	// The tags for the metric.
+	Tags []string `protobuf:"bytes,10,rep,name=tags" json:"tags,omitempty"`
+}
+
+func (m *Incident) Reset()                    { *m = Incident{} }
+func (m *Incident) String() string            { return proto.CompactTextString(m) }
+func (*Incident) ProtoMessage()               {}
+func (*Incident) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{0} }
+
+type IncidentResult struct {
+	Result []*Incident `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *IncidentResult) Reset()                    { *m = IncidentResult{} }
+func (m *IncidentResult) String() string            { return proto.CompactTextString(m) }
+func (*IncidentResult) ProtoMessage()               {}
+func (*IncidentResult) Descriptor() ([]byte, []int) { return fileDescriptor3, []int{1} }
+
+func (m *IncidentResult) GetResult() []*Incident {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Incident)(nil), "mtrpb.Incident")
+	proto.RegisterType((*IncidentResult)(nil), "mtrpb.IncidentResult")
+}
+
+var fileDescriptor3 = []byte{
+	// 242 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x3c, 0x90, 0xb1, 0x4b, 0xc4, 0x30,
+	0x14, 0xc6, 0x89, 0xbd, 0xf6, 0xda, 0x27, 0x9c, 0xf2, 0x10, 0x0d, 0xba, 0x84, 0x5b, 0xcc, 0xd4,
+	0x41, 0x27, 0x57, 0xe9, 0xd2, 0x35, 0xa3, 0x8b, 0xf4, 0xae, 0xe1, 0x08, 0x68, 0x53, 0x92, 0x57,
+	0xc5, 0x3f, 0xdc, 0x5d, 0xf2, 0x52, 0x6f, 0x7b, 0xbf, 0xef, 0xf7, 0x51, 0x9a, 0x0f, 0x76, 0x6e,
+	0x3a, 0xba, 0xd1, 0x4e, 0xd4, 0xce, 0xc1, 0x93, 0xc7, 0xf2, 0x93, 0xc2, 0x7c, 0xd8, 0xff, 0x0a,
+	0xa8, 0xfb, 0xd5, 0xe0, 0x03, 0x34, 0xa3, 0xfd, 0x72, 0x47, 0xfb, 0xee, 0x3a, 0x29, 0x94, 0xd0,
+	0x8d, 0xa9, 0x73, 0xd0, 0x77, 0x78, 0x07, 0xdb, 0xe8, 0x88, 0xd5, 0x05, 0xab, 0x2a, 0x61, 0x16,
+	0xf4, 0x33, 0xb3, 0x28, 0xb2, 0x48, 0xd8, 0x77, 0x78, 0x03, 0x65, 0xa4, 0x21, 0x90, 0xdc, 0x28,
+	0xa1, 0x0b, 0x93, 0x01, 0xaf, 0xa1, 0xb0, 0xd3, 0x28, 0x4b, 0xce, 0xd2, 0x89, 0xf7, 0x50, 0x8f,
+	0x4b, 0x18, 0xc8, 0xf9, 0x49, 0x56, 0x1c, 0x9f, 0x39, 0x7d, 0xe3, 0xdb, 0x87, 0x48, 0x72, 0xab,
+	0x84, 0x2e, 0x4d, 0x06, 0xbc, 0x85, 0x2a, 0xd2, 0x40, 0x4b, 0x94, 0xf5, 0xfa, 0x2b, 0x4c, 0xa9,
+	0x7d, 0x0a, 0x7e, 0x99, 0x65, 0x93, 0xdb, 0x0c, 0x88, 0xb0, 0xa1, 0xe1, 0x14, 0x25, 0xa8, 0x42,
+	0x37, 0x86, 0xef, 0xfd, 0x0b, 0xec, 0xfe, 0x9f, 0x6d, 0x6c, 0x5c, 0x3e, 0x08, 0x1f, 0xa1, 0x0a,
+	0x7c, 0x49, 0xa1, 0x0a, 0x7d, 0xf9, 0x74, 0xd5, 0xf2, 0x42, 0xed, 0xb9, 0xb6, 0xea, 0xd7, 0xed,
+	0x5b, 0xde, 0xee, 0x50, 0xf1, 0x92, 0xcf, 0x7f, 0x03, 0x00, 0x70, 0xb8, 0x1a, 0x7d, 0x5b, 0x01,
+	0x00, 0x00,
+}
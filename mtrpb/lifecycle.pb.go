@@ -0,0 +1,140 @@
+// Code generated by protoc-gen-go.
+// source: lifecycle.proto
+// DO NOT EDIT!
+
+package mtrpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// LifecycleState is a change of lifecycle state for a field device or data site.
+type LifecycleState struct {
+	// The state; one of planned, active, maintenance, or decommissioned.
+	State string `protobuf:"bytes,1,opt,name=state" json:"state,omitempty"`
+	// Unix time in seconds for the change.
+	Seconds int64 `protobuf:"varint,2,opt,name=seconds" json:"seconds,omitempty"`
+	// The user that made the change.
+	ChangedBy string `protobuf:"bytes,3,opt,name=changed_by,json=changedBy" json:"changed_by,omitempty"`
+}
+
+func (m *LifecycleState) Reset()                    { *m = LifecycleState{} }
+func (m *LifecycleState) String() string            { return proto.CompactTextString(m) }
+func (*LifecycleState) ProtoMessage()               {}
+func (*LifecycleState) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{0} }
+
+type LifecycleStateResult struct {
+	Result []*LifecycleState `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *LifecycleStateResult) Reset()                    { *m = LifecycleStateResult{} }
+func (m *LifecycleStateResult) String() string            { return proto.CompactTextString(m) }
+func (*LifecycleStateResult) ProtoMessage()               {}
+func (*LifecycleStateResult) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{1} }
+
+func (m *LifecycleStateResult) GetResult() []*LifecycleState {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// Rename is a change of deviceID or siteID.
+type Rename struct {
+	// The ID before the rename e.g., gps-taupoairport
+	OldID string `protobuf:"bytes,1,opt,name=old_iD,json=oldID" json:"old_iD,omitempty"`
+	// The ID after the rename.
+	NewID string `protobuf:"bytes,2,opt,name=new_iD,json=newID" json:"new_iD,omitempty"`
+	// Unix time in seconds for the rename.
+	Seconds int64 `protobuf:"varint,3,opt,name=seconds" json:"seconds,omitempty"`
+	// The user that made the change.
+	ChangedBy string `protobuf:"bytes,4,opt,name=changed_by,json=changedBy" json:"changed_by,omitempty"`
+}
+
+func (m *Rename) Reset()                    { *m = Rename{} }
+func (m *Rename) String() string            { return proto.CompactTextString(m) }
+func (*Rename) ProtoMessage()               {}
+func (*Rename) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{2} }
+
+type RenameResult struct {
+	Result []*Rename `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *RenameResult) Reset()                    { *m = RenameResult{} }
+func (m *RenameResult) String() string            { return proto.CompactTextString(m) }
+func (*RenameResult) ProtoMessage()               {}
+func (*RenameResult) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{3} }
+
+func (m *RenameResult) GetResult() []*Rename {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// Location is the location of a device or site from a time.
+type Location struct {
+	Latitude  float64 `protobuf:"fixed64,1,opt,name=latitude" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,2,opt,name=longitude" json:"longitude,omitempty"`
+	// Unix time in seconds from when the device or site was at the location.
+	Seconds int64 `protobuf:"varint,3,opt,name=seconds" json:"seconds,omitempty"`
+	// The user that recorded the move.  Empty for the location recorded when the device or site was saved.
+	ChangedBy string `protobuf:"bytes,4,opt,name=changed_by,json=changedBy" json:"changed_by,omitempty"`
+}
+
+func (m *Location) Reset()                    { *m = Location{} }
+func (m *Location) String() string            { return proto.CompactTextString(m) }
+func (*Location) ProtoMessage()               {}
+func (*Location) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{4} }
+
+type LocationResult struct {
+	Result []*Location `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *LocationResult) Reset()                    { *m = LocationResult{} }
+func (m *LocationResult) String() string            { return proto.CompactTextString(m) }
+func (*LocationResult) ProtoMessage()               {}
+func (*LocationResult) Descriptor() ([]byte, []int) { return fileDescriptor4, []int{5} }
+
+func (m *LocationResult) GetResult() []*Location {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*LifecycleState)(nil), "mtrpb.LifecycleState")
+	proto.RegisterType((*LifecycleStateResult)(nil), "mtrpb.LifecycleStateResult")
+	proto.RegisterType((*Rename)(nil), "mtrpb.Rename")
+	proto.RegisterType((*RenameResult)(nil), "mtrpb.RenameResult")
+	proto.RegisterType((*Location)(nil), "mtrpb.Location")
+	proto.RegisterType((*LocationResult)(nil), "mtrpb.LocationResult")
+}
+
+var fileDescriptor4 = []byte{
+	// 288 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x92, 0xcb, 0x4b, 0xf3, 0x40,
+	0x14, 0xc5, 0x49, 0xf2, 0x35, 0x6d, 0xee, 0xa7, 0x2d, 0x0c, 0x2d, 0x04, 0x51, 0x08, 0x01, 0x31,
+	0x1b, 0xb3, 0x50, 0x5c, 0xb8, 0x2d, 0x71, 0x51, 0xe8, 0x2a, 0xee, 0xdc, 0x84, 0x24, 0x73, 0xad,
+	0x81, 0xe9, 0x4c, 0x49, 0xa6, 0x94, 0x2c, 0xfc, 0xdf, 0x25, 0xf3, 0xf0, 0x91, 0x85, 0x0b, 0x77,
+	0x73, 0xcf, 0x39, 0xc3, 0xf9, 0x71, 0xb9, 0xb0, 0x60, 0xcd, 0x2b, 0xd6, 0x7d, 0xcd, 0x30, 0x3d,
+	0xb4, 0x42, 0x0a, 0x32, 0xd9, 0xcb, 0xf6, 0x50, 0xc5, 0x05, 0xcc, 0xb7, 0xd6, 0x79, 0x96, 0xa5,
+	0x44, 0xb2, 0x84, 0x49, 0x37, 0x3c, 0x42, 0x27, 0x72, 0x92, 0x20, 0xd7, 0x03, 0x09, 0x61, 0xda,
+	0x61, 0x2d, 0x38, 0xed, 0x42, 0x37, 0x72, 0x12, 0x2f, 0xb7, 0x23, 0xb9, 0x02, 0xa8, 0xdf, 0x4a,
+	0xbe, 0x43, 0x5a, 0x54, 0x7d, 0xe8, 0xa9, 0x4f, 0x81, 0x51, 0xd6, 0x7d, 0xfc, 0x04, 0xcb, 0x9f,
+	0x05, 0x39, 0x76, 0x47, 0x26, 0xc9, 0x2d, 0xf8, 0xad, 0x7a, 0x85, 0x4e, 0xe4, 0x25, 0xff, 0xef,
+	0x56, 0xa9, 0x02, 0x4a, 0x47, 0x61, 0x13, 0x8a, 0x05, 0xf8, 0x39, 0xf2, 0x72, 0x8f, 0x64, 0x05,
+	0xbe, 0x60, 0xb4, 0x68, 0x32, 0x0b, 0x28, 0x18, 0xdd, 0x64, 0x83, 0xcc, 0xf1, 0x34, 0xc8, 0xae,
+	0x96, 0x39, 0x9e, 0x36, 0xd9, 0x77, 0x6e, 0xef, 0x37, 0xee, 0x7f, 0x63, 0xee, 0x07, 0x38, 0xd3,
+	0x85, 0x86, 0xf7, 0x7a, 0xc4, 0x7b, 0x6e, 0x78, 0x4d, 0xc8, 0x72, 0xbe, 0xc3, 0x6c, 0x2b, 0xea,
+	0x52, 0x36, 0x82, 0x93, 0x0b, 0x98, 0xb1, 0x52, 0x36, 0xf2, 0x48, 0xf5, 0x32, 0x9d, 0xfc, 0x73,
+	0x26, 0x97, 0x10, 0x30, 0xc1, 0x77, 0xda, 0x74, 0x95, 0xf9, 0x25, 0xfc, 0x9d, 0xfa, 0x11, 0xe6,
+	0xb6, 0xde, 0x70, 0xdf, 0x8c, 0xb8, 0x17, 0x76, 0xcf, 0x36, 0x66, 0xec, 0xf5, 0xf4, 0x45, 0x9f,
+	0x44, 0xe5, 0xab, 0x03, 0xb9, 0xff, 0x18, 0x00, 0x41, 0x3f, 0xa3, 0x36, 0x33, 0x02, 0x00, 0x00,
+}
@@ -0,0 +1,64 @@
+// Code generated by protoc-gen-go.
+// source: map.proto
+// DO NOT EDIT!
+
+package mtrpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// MapBbox is a named bounding box for drawing maps.
+type MapBbox struct {
+	// The name for the bbox e.g., NewZealand
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The title for display e.g., New Zealand
+	Title string `protobuf:"bytes,2,opt,name=title" json:"title,omitempty"`
+	// The bbox as lower left and upper right longitude latitude e.g., 165,-48,179,-34
+	// It may cross 180.
+	Bbox string `protobuf:"bytes,3,opt,name=bbox" json:"bbox,omitempty"`
+}
+
+func (m *MapBbox) Reset()                    { *m = MapBbox{} }
+func (m *MapBbox) String() string            { return proto.CompactTextString(m) }
+func (*MapBbox) ProtoMessage()               {}
+func (*MapBbox) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{0} }
+
+type MapBboxResult struct {
+	Result []*MapBbox `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *MapBboxResult) Reset()                    { *m = MapBboxResult{} }
+func (m *MapBboxResult) String() string            { return proto.CompactTextString(m) }
+func (*MapBboxResult) ProtoMessage()               {}
+func (*MapBboxResult) Descriptor() ([]byte, []int) { return fileDescriptor5, []int{1} }
+
+func (m *MapBboxResult) GetResult() []*MapBbox {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*MapBbox)(nil), "mtrpb.MapBbox")
+	proto.RegisterType((*MapBboxResult)(nil), "mtrpb.MapBboxResult")
+}
+
+var fileDescriptor5 = []byte{
+	// 138 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xcc, 0x4d, 0x2c, 0xd0,
+	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0xcd, 0x2d, 0x29, 0x2a, 0x48, 0x52, 0x72, 0xe7, 0x62,
+	0xf7, 0x4d, 0x2c, 0x70, 0x4a, 0xca, 0xaf, 0x10, 0x12, 0xe2, 0x62, 0xc9, 0x4b, 0xcc, 0x4d, 0x95,
+	0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x02, 0xb3, 0x85, 0x44, 0xb8, 0x58, 0x4b, 0x32, 0x4b, 0x72,
+	0x52, 0x25, 0x98, 0xc0, 0x82, 0x10, 0x0e, 0x48, 0x65, 0x52, 0x52, 0x7e, 0x85, 0x04, 0x33, 0x44,
+	0x25, 0x88, 0xad, 0x64, 0xce, 0xc5, 0x0b, 0x35, 0x28, 0x28, 0xb5, 0xb8, 0x34, 0xa7, 0x44, 0x48,
+	0x8d, 0x8b, 0xad, 0x08, 0xcc, 0x92, 0x60, 0x54, 0x60, 0xd6, 0xe0, 0x36, 0xe2, 0xd3, 0x03, 0xdb,
+	0xa8, 0x07, 0x53, 0x05, 0x95, 0x75, 0x62, 0x8f, 0x82, 0x38, 0x25, 0x89, 0x0d, 0xec, 0x30, 0x63,
+	0xc0, 0x00, 0xe1, 0xb0, 0x78, 0x46, 0xa5, 0x00, 0x00, 0x00,
+}
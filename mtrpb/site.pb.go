@@ -0,0 +1,106 @@
+// Code generated by protoc-gen-go.
+// source: site.proto
+// DO NOT EDIT!
+
+package mtrpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// SiteDevice links a field device to a data site.
+type SiteDevice struct {
+	// The siteID e.g., TAUP
+	SiteID string `protobuf:"bytes,1,opt,name=site_iD,json=siteID" json:"site_iD,omitempty"`
+	// The deviceID e.g., gps-taupoairport
+	DeviceID string `protobuf:"bytes,2,opt,name=device_iD,json=deviceID" json:"device_iD,omitempty"`
+	// What the device does for the site e.g., datalogger, comms, or power.
+	Role string `protobuf:"bytes,3,opt,name=role" json:"role,omitempty"`
+}
+
+func (m *SiteDevice) Reset()                    { *m = SiteDevice{} }
+func (m *SiteDevice) String() string            { return proto.CompactTextString(m) }
+func (*SiteDevice) ProtoMessage()               {}
+func (*SiteDevice) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{0} }
+
+type SiteDeviceResult struct {
+	Result []*SiteDevice `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *SiteDeviceResult) Reset()                    { *m = SiteDeviceResult{} }
+func (m *SiteDeviceResult) String() string            { return proto.CompactTextString(m) }
+func (*SiteDeviceResult) ProtoMessage()               {}
+func (*SiteDeviceResult) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{1} }
+
+func (m *SiteDeviceResult) GetResult() []*SiteDevice {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// Site is a data site with the field devices linked to it.
+type Site struct {
+	// The site with the latest latency summaries.
+	Site *DataSite `protobuf:"bytes,1,opt,name=site" json:"site,omitempty"`
+	// The links between the site and its devices.
+	Links []*SiteDevice `protobuf:"bytes,2,rep,name=links" json:"links,omitempty"`
+	// The linked devices with the latest summary for each metric.
+	Devices []*FieldDevice `protobuf:"bytes,3,rep,name=devices" json:"devices,omitempty"`
+}
+
+func (m *Site) Reset()                    { *m = Site{} }
+func (m *Site) String() string            { return proto.CompactTextString(m) }
+func (*Site) ProtoMessage()               {}
+func (*Site) Descriptor() ([]byte, []int) { return fileDescriptor6, []int{2} }
+
+func (m *Site) GetSite() *DataSite {
+	if m != nil {
+		return m.Site
+	}
+	return nil
+}
+
+func (m *Site) GetLinks() []*SiteDevice {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+func (m *Site) GetDevices() []*FieldDevice {
+	if m != nil {
+		return m.Devices
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*SiteDevice)(nil), "mtrpb.SiteDevice")
+	proto.RegisterType((*SiteDeviceResult)(nil), "mtrpb.SiteDeviceResult")
+	proto.RegisterType((*Site)(nil), "mtrpb.Site")
+}
+
+var fileDescriptor6 = []byte{
+	// 231 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x90, 0xb1, 0x4b, 0xc5, 0x30,
+	0x10, 0xc6, 0xc9, 0x6b, 0x5f, 0xeb, 0xbb, 0x0e, 0xea, 0x2d, 0x86, 0xe7, 0x52, 0xea, 0x60, 0x05,
+	0xe9, 0x50, 0x67, 0x17, 0x09, 0x42, 0xd7, 0x0a, 0x0e, 0x2e, 0x92, 0xda, 0x08, 0xc1, 0x68, 0x4b,
+	0x12, 0xfd, 0x03, 0xfc, 0xcb, 0x25, 0x97, 0x88, 0x2e, 0x6f, 0xbb, 0xfb, 0xbe, 0xdf, 0x1d, 0x1f,
+	0x1f, 0x80, 0xd3, 0x5e, 0x75, 0xab, 0x5d, 0xfc, 0x82, 0xdb, 0x77, 0x6f, 0xd7, 0x69, 0x0f, 0xb3,
+	0xf4, 0x32, 0x4a, 0xfb, 0xea, 0x55, 0x2b, 0x33, 0xc7, 0xa5, 0x79, 0x04, 0x78, 0xd0, 0x5e, 0x09,
+	0xf5, 0xa5, 0x5f, 0x14, 0x9e, 0x41, 0x19, 0x6e, 0x9f, 0xb5, 0xe0, 0xac, 0x66, 0xed, 0x6e, 0x2c,
+	0xc2, 0x3a, 0x08, 0x3c, 0x87, 0xdd, 0x4c, 0x48, 0xb0, 0x36, 0x64, 0x1d, 0x45, 0x61, 0x10, 0x88,
+	0x90, 0xdb, 0xc5, 0x28, 0x9e, 0x91, 0x4e, 0x73, 0x73, 0x0b, 0x27, 0x7f, 0x7f, 0x47, 0xe5, 0x3e,
+	0x8d, 0xc7, 0x2b, 0x28, 0x2c, 0x4d, 0x9c, 0xd5, 0x59, 0x5b, 0xf5, 0xa7, 0x1d, 0x85, 0xeb, 0xfe,
+	0x81, 0x09, 0x68, 0xbe, 0x19, 0xe4, 0x41, 0xc6, 0x0b, 0xc8, 0x43, 0x04, 0x8a, 0x53, 0xf5, 0xc7,
+	0xe9, 0x42, 0x48, 0x2f, 0x83, 0x3d, 0x92, 0x89, 0x97, 0xb0, 0x35, 0xfa, 0xe3, 0xcd, 0xf1, 0xcd,
+	0xa1, 0xbf, 0xd1, 0xc7, 0x6b, 0x28, 0x63, 0x6a, 0xc7, 0x33, 0x42, 0x31, 0xa1, 0xf7, 0xa1, 0x92,
+	0xc4, 0xfe, 0x22, 0x77, 0xe5, 0x53, 0x6c, 0x6f, 0x2a, 0xa8, 0xab, 0x9b, 0x9f, 0x01, 0x00, 0x99,
+	0x01, 0x55, 0xf7, 0x59, 0x01, 0x00, 0x00,
+}
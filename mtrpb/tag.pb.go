@@ -13,14 +13,19 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+// Tag is a tag for metrics.  Tags can be namespaced as namespace:value e.g., site:TAUP
 type Tag struct {
 	Tag string `protobuf:"bytes,1,opt,name=tag" json:"tag,omitempty"`
+	// The namespace for the tag e.g., site.  Empty if the tag is not namespaced.
+	Namespace string `protobuf:"bytes,2,opt,name=namespace" json:"namespace,omitempty"`
+	// The tag without the namespace e.g., TAUP
+	Value string `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
 }
 
 func (m *Tag) Reset()                    { *m = Tag{} }
 func (m *Tag) String() string            { return proto.CompactTextString(m) }
 func (*Tag) ProtoMessage()               {}
-func (*Tag) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
+func (*Tag) Descriptor() ([]byte, []int) { return fileDescriptor7, []int{0} }
 
 type TagResult struct {
 	Result []*Tag `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
@@ -29,7 +34,7 @@ type TagResult struct {
 func (m *TagResult) Reset()                    { *m = TagResult{} }
 func (m *TagResult) String() string            { return proto.CompactTextString(m) }
 func (*TagResult) ProtoMessage()               {}
-func (*TagResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
+func (*TagResult) Descriptor() ([]byte, []int) { return fileDescriptor7, []int{1} }
 
 func (m *TagResult) GetResult() []*Tag {
 	if m != nil {
@@ -38,6 +43,33 @@ func (m *TagResult) GetResult() []*Tag {
 	return nil
 }
 
+// TagNamespace is a namespace for tags.
+type TagNamespace struct {
+	Namespace   string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *TagNamespace) Reset()                    { *m = TagNamespace{} }
+func (m *TagNamespace) String() string            { return proto.CompactTextString(m) }
+func (*TagNamespace) ProtoMessage()               {}
+func (*TagNamespace) Descriptor() ([]byte, []int) { return fileDescriptor7, []int{2} }
+
+type TagNamespaceResult struct {
+	Result []*TagNamespace `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *TagNamespaceResult) Reset()                    { *m = TagNamespaceResult{} }
+func (m *TagNamespaceResult) String() string            { return proto.CompactTextString(m) }
+func (*TagNamespaceResult) ProtoMessage()               {}
+func (*TagNamespaceResult) Descriptor() ([]byte, []int) { return fileDescriptor7, []int{3} }
+
+func (m *TagNamespaceResult) GetResult() []*TagNamespace {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
 type TagSearchResult struct {
 	FieldMetric []*FieldMetricSummary `protobuf:"bytes,1,rep,name=field_metric,json=fieldMetric" json:"field_metric,omitempty"`
 	DataLatency []*DataLatencySummary `protobuf:"bytes,2,rep,name=data_latency,json=dataLatency" json:"data_latency,omitempty"`
@@ -46,7 +78,7 @@ type TagSearchResult struct {
 func (m *TagSearchResult) Reset()                    { *m = TagSearchResult{} }
 func (m *TagSearchResult) String() string            { return proto.CompactTextString(m) }
 func (*TagSearchResult) ProtoMessage()               {}
-func (*TagSearchResult) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
+func (*TagSearchResult) Descriptor() ([]byte, []int) { return fileDescriptor7, []int{4} }
 
 func (m *TagSearchResult) GetFieldMetric() []*FieldMetricSummary {
 	if m != nil {
@@ -65,22 +97,29 @@ func (m *TagSearchResult) GetDataLatency() []*DataLatencySummary {
 func init() {
 	proto.RegisterType((*Tag)(nil), "mtrpb.Tag")
 	proto.RegisterType((*TagResult)(nil), "mtrpb.TagResult")
+	proto.RegisterType((*TagNamespace)(nil), "mtrpb.TagNamespace")
+	proto.RegisterType((*TagNamespaceResult)(nil), "mtrpb.TagNamespaceResult")
 	proto.RegisterType((*TagSearchResult)(nil), "mtrpb.TagSearchResult")
 }
 
-var fileDescriptor2 = []byte{
-	// 201 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2c, 0x49, 0x4c, 0xd7,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0xcd, 0x2d, 0x29, 0x2a, 0x48, 0x92, 0xe2, 0x4a, 0x49,
-	0x2c, 0x49, 0x84, 0x08, 0x49, 0x71, 0xa7, 0x65, 0xa6, 0xe6, 0xa4, 0x40, 0x38, 0x4a, 0xe2, 0x5c,
-	0xcc, 0x21, 0x89, 0xe9, 0x42, 0x02, 0x5c, 0xcc, 0x40, 0x3d, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0x9c,
-	0x41, 0x20, 0xa6, 0x92, 0x3e, 0x17, 0x27, 0x50, 0x22, 0x28, 0xb5, 0xb8, 0x34, 0xa7, 0x44, 0x48,
-	0x89, 0x8b, 0xad, 0x08, 0xcc, 0x02, 0xaa, 0x60, 0xd6, 0xe0, 0x36, 0xe2, 0xd2, 0x03, 0x1b, 0xab,
-	0x07, 0x52, 0x01, 0x95, 0x51, 0xea, 0x65, 0xe4, 0xe2, 0x07, 0xf2, 0x83, 0x53, 0x13, 0x8b, 0x92,
-	0x33, 0xa0, 0xfa, 0x6c, 0xb8, 0x78, 0xc0, 0x96, 0xc5, 0xe7, 0xa6, 0x96, 0x14, 0x65, 0x26, 0x43,
-	0x75, 0x4b, 0x42, 0x75, 0xbb, 0x81, 0xa4, 0x7c, 0xc1, 0x32, 0xc1, 0xa5, 0xb9, 0xb9, 0x89, 0x45,
-	0x95, 0x41, 0x10, 0xb7, 0x41, 0xc4, 0x40, 0xba, 0x41, 0xce, 0x8e, 0xcf, 0x49, 0x2c, 0x49, 0xcd,
-	0x4b, 0xae, 0x94, 0x60, 0x42, 0xd1, 0xed, 0x02, 0x94, 0xf2, 0x81, 0xc8, 0xc0, 0x75, 0xa7, 0x20,
-	0xc4, 0x9c, 0xd8, 0xa3, 0x20, 0x7e, 0x4f, 0x62, 0x03, 0xfb, 0xd4, 0x18, 0x10, 0x00, 0x00, 0xff,
-	0xff, 0x7c, 0xd1, 0x9c, 0x11, 0x16, 0x01, 0x00, 0x00,
+var fileDescriptor7 = []byte{
+	// 280 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x51, 0x4f, 0x4b, 0xfb, 0x40,
+	0x10, 0x25, 0x0d, 0xed, 0x8f, 0x4c, 0x0a, 0x3f, 0x59, 0x3d, 0xc4, 0xe2, 0x21, 0xe4, 0x54, 0x10,
+	0x22, 0xe8, 0xd5, 0x8b, 0x22, 0x5e, 0xd4, 0x1e, 0xd2, 0x9c, 0xbc, 0x94, 0x69, 0xb2, 0x5d, 0x03,
+	0xf9, 0xc7, 0x66, 0x22, 0xf4, 0x43, 0xf8, 0x9d, 0x65, 0xff, 0xd8, 0x6d, 0x7b, 0x9b, 0x79, 0x6f,
+	0xde, 0x9b, 0xc7, 0x0c, 0x04, 0x84, 0x22, 0xed, 0x65, 0x47, 0x1d, 0x9b, 0x36, 0x24, 0xfb, 0xed,
+	0x02, 0x4a, 0x24, 0x34, 0xd0, 0x22, 0xdc, 0x55, 0xbc, 0x2e, 0x4d, 0x93, 0xbc, 0x81, 0x9f, 0xa3,
+	0x60, 0x17, 0xe0, 0x13, 0x8a, 0xc8, 0x8b, 0xbd, 0x65, 0x90, 0xa9, 0x92, 0xdd, 0x40, 0xd0, 0x62,
+	0xc3, 0x87, 0x1e, 0x0b, 0x1e, 0x4d, 0x34, 0xee, 0x00, 0x76, 0x05, 0xd3, 0x6f, 0xac, 0x47, 0x1e,
+	0xf9, 0x9a, 0x31, 0x4d, 0x72, 0x07, 0x41, 0x8e, 0x22, 0xe3, 0xc3, 0x58, 0x13, 0x4b, 0x60, 0x26,
+	0x75, 0x15, 0x79, 0xb1, 0xbf, 0x0c, 0xef, 0x21, 0xd5, 0x51, 0x52, 0x35, 0x61, 0x99, 0x64, 0x05,
+	0xf3, 0x1c, 0xc5, 0xea, 0x60, 0x7b, 0xb2, 0xd4, 0x3b, 0x5f, 0x1a, 0x43, 0x58, 0xf2, 0xa1, 0x90,
+	0x55, 0x4f, 0x55, 0xd7, 0xda, 0x50, 0xc7, 0x50, 0xf2, 0x04, 0xec, 0xd8, 0xcf, 0x26, 0xb9, 0x3d,
+	0x4b, 0x72, 0xe9, 0x92, 0xb8, 0xd1, 0xbf, 0x48, 0x3f, 0x1e, 0xfc, 0xcf, 0x51, 0xac, 0x39, 0xca,
+	0xe2, 0xcb, 0x1a, 0x3c, 0xc2, 0x5c, 0xdf, 0x6c, 0xd3, 0x70, 0x92, 0x55, 0x61, 0x6d, 0xae, 0xad,
+	0xcd, 0xab, 0xa2, 0x3e, 0x34, 0xb3, 0x1e, 0x9b, 0x06, 0xe5, 0x3e, 0x0b, 0x77, 0x0e, 0x53, 0x6a,
+	0x75, 0xfd, 0x4d, 0x8d, 0xc4, 0xdb, 0x62, 0x1f, 0x4d, 0x4e, 0xd4, 0x2f, 0x48, 0xf8, 0x6e, 0x98,
+	0x83, 0xba, 0x74, 0xd8, 0xf3, 0xbf, 0x4f, 0xf3, 0xc2, 0xed, 0x4c, 0x3f, 0xec, 0xe1, 0x77, 0x00,
+	0x32, 0x2f, 0x79, 0x1a, 0xdd, 0x01, 0x00, 0x00,
 }
@@ -41,6 +41,12 @@ type plt struct {
 	xShift                        int
 	Labels                        []Label
 	ShowLatest                    bool
+	Events                        []Event
+	EventPts                      []pt // x and label for events in the x axis range
+	Gap                           time.Duration
+	Gaps                          []gap  // shaded regions for gaps in the data
+	Markers                       []data // points drawn as markers without a line
+	Projections                   []data // points drawn as a dashed line
 }
 
 type plotKey struct {
@@ -72,6 +78,13 @@ type line struct {
 	X, Y, XX, YY int
 }
 
+/*
+gap is a region with no data in SVG space.
+*/
+type gap struct {
+	X, W int
+}
+
 type threshold struct {
 	Min, Max float64
 	H        int // height in px for the threshold rect
@@ -98,9 +111,16 @@ func (l Labels) Less(i, j int) bool { return l[i].Label < l[j].Label
 }
 func (l Labels) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 
+// Event is a labelled time drawn as a vertical marker on a plot e.g., a device move.
+type Event struct {
+	DateTime time.Time
+	Label    string
+}
+
 type data struct {
-	Series Series
-	Pts    pts
+	Series   Series
+	Pts      pts
+	Segments []pts // Pts split at gaps
 }
 
 func (p *Plot) SetTitle(title string) {
@@ -159,6 +179,29 @@ func (p *Plot) SetLatest(pt Point, colour string) {
 	p.plt.LatestColour = colour
 }
 
+// AddMarkers adds points that are drawn as markers in s.Colour without a line e.g., to
+// highlight points in another series.  Markers do not change the axes ranges.
+func (p *Plot) AddMarkers(s Series) {
+	p.plt.Markers = append(p.plt.Markers, data{Series: s})
+}
+
+// AddProjection adds a series that is drawn as a dashed line in s.Colour e.g., a forecast.
+// Projections extend the y axis when it is autoranged but do not change the x axis.
+func (p *Plot) AddProjection(s Series) {
+	p.plt.Projections = append(p.plt.Projections, data{Series: s})
+}
+
+// SetGap sets the longest time between points that are joined by a line.  Points further
+// apart are not joined and the gap is shaded.  Points are always joined if gap is not set.
+func (p *Plot) SetGap(gap time.Duration) {
+	p.plt.Gap = gap
+}
+
+// AddEvent adds a vertical marker with a label at t.  Events outside the x axis are not drawn.
+func (p *Plot) AddEvent(t time.Time, label string) {
+	p.plt.Events = append(p.plt.Events, Event{DateTime: t, Label: label})
+}
+
 func (p *Plot) SetLabels(l Labels) {
 	//sort.Sort(l)
 	p.plt.Labels = l
@@ -218,18 +261,49 @@ func (p *Plot) scaleData() {
 			p.plt.YMin = 0.0
 		}
 
+		for _, d := range p.plt.Projections {
+			for _, point := range d.Series.Points {
+				p.plt.YMin = math.Min(p.plt.YMin, point.Value)
+				p.plt.YMax = math.Max(p.plt.YMax, point.Value)
+			}
+		}
+
 		p.plt.dy = float64(p.plt.height) / math.Abs(p.plt.YMax-p.plt.YMin)
 	}
 
+	p.plt.Gaps = nil
 	for i := range p.plt.Data {
 		p.plt.Data[i].Pts = make([]pt, len(p.plt.Data[i].Series.Points))
+		p.plt.Data[i].Segments = nil
+
+		var start int
 
 		for j := range p.plt.Data[i].Series.Points {
 			p.plt.Data[i].Pts[j] = pt{
 				X: int((p.plt.Data[i].Series.Points[j].DateTime.Sub(p.plt.First.DateTime).Seconds()*p.plt.dx)+0.5) + p.plt.xShift,
 				Y: p.plt.height - int(((p.plt.Data[i].Series.Points[j].Value-p.plt.YMin)*p.plt.dy)+0.5),
 			}
+
+			if j > 0 && p.plt.Gap > 0 &&
+				p.plt.Data[i].Series.Points[j].DateTime.Sub(p.plt.Data[i].Series.Points[j-1].DateTime) > p.plt.Gap {
+				p.plt.Data[i].Segments = append(p.plt.Data[i].Segments, p.plt.Data[i].Pts[start:j])
+				p.plt.Gaps = append(p.plt.Gaps, gap{
+					X: p.plt.Data[i].Pts[j-1].X,
+					W: p.plt.Data[i].Pts[j].X - p.plt.Data[i].Pts[j-1].X,
+				})
+				start = j
+			}
 		}
+
+		p.plt.Data[i].Segments = append(p.plt.Data[i].Segments, p.plt.Data[i].Pts[start:])
+	}
+
+	for i := range p.plt.Markers {
+		p.plt.Markers[i].Pts = p.scalePoints(p.plt.Markers[i].Series.Points)
+	}
+
+	for i := range p.plt.Projections {
+		p.plt.Projections[i].Pts = p.scalePoints(p.plt.Projections[i].Series.Points)
 	}
 
 	p.plt.MinPt = pt{
@@ -262,6 +336,17 @@ func (p *Plot) scaleData() {
 		p.plt.RangeAlert = true
 	}
 
+	p.plt.EventPts = nil
+	for _, e := range p.plt.Events {
+		if e.DateTime.Before(p.plt.XMin) || e.DateTime.After(p.plt.XMax) {
+			continue
+		}
+		p.plt.EventPts = append(p.plt.EventPts, pt{
+			X: int((e.DateTime.Sub(p.plt.XMin).Seconds() * p.plt.dx) + 0.5),
+			L: e.Label,
+		})
+	}
+
 	if p.plt.Threshold.Show {
 		p.plt.Threshold.H = int(((p.plt.Threshold.Max - p.plt.Threshold.Min) * p.plt.dy) + 0.5)
 		p.plt.Threshold.Y = p.plt.height - int(((p.plt.Threshold.Max-p.plt.YMin)*p.plt.dy)+0.5)
@@ -270,6 +355,20 @@ func (p *Plot) scaleData() {
 	return
 }
 
+// scalePoints returns points in SVG space.  scaleData must have set the scales first.
+func (p *Plot) scalePoints(points []Point) pts {
+	s := make(pts, len(points))
+
+	for i := range points {
+		s[i] = pt{
+			X: int((points[i].DateTime.Sub(p.plt.First.DateTime).Seconds()*p.plt.dx)+0.5) + p.plt.xShift,
+			Y: p.plt.height - int(((points[i].Value-p.plt.YMin)*p.plt.dy)+0.5),
+		}
+	}
+
+	return s
+}
+
 /*
 setAxes builds x and y grids.  Major ticks are labelled, minor ticks are not.
 scaleData() should be called before setAxes()
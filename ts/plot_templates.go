@@ -123,7 +123,24 @@ const plotBaseTemplate = `<?xml version="1.0"?>
 {{end}}
 {{end}}
 
+{{range .Gaps}}
+<rect x="{{.X}}" y="0" width="{{.W}}" height="210" fill="lightgray" fill-opacity="0.5"/>
+{{end}}
+
+{{range .EventPts}}
+<polyline fill="none" stroke="darkslategray" stroke-width="1" stroke-dasharray="4,4" points="{{.X}},0 {{.X}},210"/>
+<text x="{{.X}}" y="-4" text-anchor="middle" font-size="10px" fill="darkslategray">{{.L}}</text>
+{{end}}
+
 {{template "data" .}}
+{{range .Projections}}
+<polyline style="stroke: {{.Series.Colour}}; fill: none; stroke-width: 2px; stroke-dasharray: 6,4" points="{{range .Pts}}{{.X}},{{.Y}} {{end}}" />
+{{end}}
+{{range .Markers}}
+<g style="stroke: {{.Series.Colour}}; fill: {{.Series.Colour}}">
+{{range .Pts}}<circle cx="{{.X}}" cy="{{.Y}}" r="3" />{{end}}
+</g>
+{{end}}
 {{if .ShowLatest}}
 <g style="stroke: {{.LatestColour}}; fill: none">
 <circle cx="{{.LatestPt.X}}" cy="{{.LatestPt.Y}}" r="3" />
@@ -171,8 +188,10 @@ const plotAppMetricsTemplate = `<?xml version="1.0"?>
 
 const plotLineTemplate = `
 {{define "data"}}
-{{range .Data}}
-<polyline style="stroke: {{.Series.Colour}}; fill: none; stroke-width: 2px; stroke-linecap: round; stroke-linejoin: round" points="{{range .Pts}}{{.X}},{{.Y}} {{end}}" />
+{{range .Data}}{{$colour := .Series.Colour}}
+{{range .Segments}}
+<polyline style="stroke: {{$colour}}; fill: none; stroke-width: 2px; stroke-linecap: round; stroke-linejoin: round" points="{{range .}}{{.X}},{{.Y}} {{end}}" />
+{{end}}
 {{end}}
 {{end}}`
 
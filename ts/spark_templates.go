@@ -37,5 +37,5 @@ const sparkBaseTemplate = `<?xml version="1.0"?>
 `
 const sparkLineTemplate = `
 {{define "data"}}
-{{range .Data}}<polyline style="stroke: deepskyblue; fill: none; stroke-width: 1.0" points="{{range .Pts}}{{.X}},{{.Y}} {{end}}" />{{end}}
+{{range .Data}}{{range .Segments}}<polyline style="stroke: deepskyblue; fill: none; stroke-width: 1.0" points="{{range .}}{{.X}},{{.Y}} {{end}}" />{{end}}{{end}}
 {{end}}`